@@ -0,0 +1,311 @@
+package appargocd
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tempestdx/sdk-go/app"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// refreshAnnotation asks the ArgoCD controller to refresh an Application.
+// The controller removes it once the refresh is done.
+// See: https://argo-cd.readthedocs.io/en/stable/user-guide/annotations-and-labels/
+const refreshAnnotation = "argocd.argoproj.io/refresh"
+
+// Refresh types accepted by the "type" input of the refresh action.
+const (
+	refreshTypeNormal = "normal" // Compare against the latest manifests, using cached ones where possible
+	refreshTypeHard   = "hard"   // Also invalidate the manifest cache and regenerate manifests
+)
+
+var (
+	// Embed JSON schemas for the actions that can be run against an application
+	//go:embed schema/refresh.json
+	refreshSchema []byte
+
+	//go:embed schema/sync.json
+	syncSchema []byte
+
+	//go:embed schema/rollback.json
+	rollbackSchema []byte
+
+	// All actions report the status of the Application once they are done
+	//go:embed schema/action_output.json
+	actionOutputSchema []byte
+)
+
+// actionClient returns a dynamic client for the cluster configured in the environment,
+// and the name of the Application the action runs against.
+func actionClient(req *app.ActionRequest) (dynamic.Interface, string, error) {
+	_, name, err := parseExternalID(req.Resource.ExternalID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	config, err := getConfigFromEnv(req.Environment)
+	if err != nil {
+		return nil, "", err
+	}
+
+	dynamicClient, err := newDynamicClient(config)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	return dynamicClient, name, nil
+}
+
+// refreshAction makes ArgoCD compare the Application against its sources again,
+// without syncing it. A hard refresh also regenerates the manifests.
+func refreshAction(ctx context.Context, req *app.ActionRequest) (*app.ActionResponse, error) {
+	refreshType := stringInput(req.Input, "type")
+	switch refreshType {
+	case "":
+		refreshType = refreshTypeNormal
+	case refreshTypeNormal, refreshTypeHard:
+	default:
+		return nil, fmt.Errorf("unsupported refresh type %q", refreshType)
+	}
+
+	syncTimeout, err := getSyncTimeoutFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, name, err := actionClient(req)
+	if err != nil {
+		return nil, err
+	}
+
+	patch := map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]any{refreshAnnotation: refreshType},
+		},
+	}
+	if err := patchApplication(ctx, dynamicClient, name, patch); err != nil {
+		return nil, err
+	}
+
+	// The refresh is done once the controller removes the annotation
+	obj, err := waitForApplication(ctx, dynamicClient, "argocd", name, waitOptions{
+		Timeout:  syncTimeout,
+		Progress: logProgress,
+		Condition: func(obj *unstructured.Unstructured, _ applicationStatus) (bool, error) {
+			_, pending := obj.GetAnnotations()[refreshAnnotation]
+			return !pending, nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &app.ActionResponse{Output: actionOutput(obj)}, nil
+}
+
+// syncAction starts a sync of the Application, optionally to a specific revision,
+// and waits for it to complete and for the Application to become Healthy.
+func syncAction(ctx context.Context, req *app.ActionRequest) (*app.ActionResponse, error) {
+	syncTimeout, err := getSyncTimeoutFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, name, err := actionClient(req)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := getIdleApplication(ctx, dynamicClient, name)
+	if err != nil {
+		return nil, err
+	}
+
+	sources, err := sourcesFromApplication(obj)
+	if err != nil {
+		return nil, err
+	}
+	syncPolicy, err := syncPolicyFromApplication(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	sync := map[string]any{
+		"prune": boolInput(req.Input, "prune"),
+	}
+
+	// Like the ArgoCD API, refuse to sync to another revision than the target revision
+	// while automated sync is enabled, as the controller would immediately sync it back.
+	if revision := stringInput(req.Input, "revision"); revision != "" {
+		if len(sources) != 1 {
+			return nil, errors.New("revision is not supported for multi-source applications")
+		}
+		if syncPolicy.Automated != nil && revision != sources[0].TargetRevision {
+			return nil, fmt.Errorf("cannot sync to %s: auto-sync currently set to %s", revision, sources[0].TargetRevision)
+		}
+		sync["revision"] = revision
+	}
+
+	if err := startOperation(ctx, dynamicClient, name, initiatorFromMetadata(req.Metadata), sync); err != nil {
+		return nil, err
+	}
+
+	return waitForOperation(ctx, dynamicClient, obj, syncTimeout)
+}
+
+// rollbackAction syncs the Application to the revision and sources of a previous
+// deployment, as recorded in status.history.
+func rollbackAction(ctx context.Context, req *app.ActionRequest) (*app.ActionResponse, error) {
+	syncTimeout, err := getSyncTimeoutFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, name, err := actionClient(req)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := getIdleApplication(ctx, dynamicClient, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Like the ArgoCD API, refuse to roll back while automated sync is enabled,
+	// as the controller would immediately sync the Application back to its target revision.
+	syncPolicy, err := syncPolicyFromApplication(obj)
+	if err != nil {
+		return nil, err
+	}
+	if syncPolicy.Automated != nil {
+		return nil, errors.New("rollback cannot be initiated when auto-sync is enabled; set sync_mode to manual first")
+	}
+
+	// JSON numbers are decoded as float64
+	id, _ := req.Input["id"].(float64)
+	entry, err := findHistoryEntry(obj, int64(id))
+	if err != nil {
+		return nil, err
+	}
+
+	// Sync the deployed revision(s) and source(s) of the history entry
+	sync := map[string]any{
+		"prune": boolInput(req.Input, "prune"),
+	}
+	for _, key := range []string{"revision", "revisions", "source", "sources"} {
+		if v, ok := entry[key]; ok {
+			sync[key] = v
+		}
+	}
+
+	if err := startOperation(ctx, dynamicClient, name, initiatorFromMetadata(req.Metadata), sync); err != nil {
+		return nil, err
+	}
+
+	return waitForOperation(ctx, dynamicClient, obj, syncTimeout)
+}
+
+// findHistoryEntry returns the status.history entry with the given deployment ID.
+// An ID of 0 selects the deployment before the current one.
+func findHistoryEntry(obj *unstructured.Unstructured, id int64) (map[string]any, error) {
+	history, _, _ := unstructured.NestedSlice(obj.Object, "status", "history")
+
+	if id == 0 {
+		if len(history) < 2 {
+			return nil, errors.New("application has no previous deployment to roll back to")
+		}
+		entry, ok := history[len(history)-2].(map[string]any)
+		if !ok {
+			return nil, errors.New("invalid status.history entry")
+		}
+		return entry, nil
+	}
+
+	for _, h := range history {
+		entry, ok := h.(map[string]any)
+		if !ok {
+			continue
+		}
+		if entryID, _, _ := unstructured.NestedInt64(entry, "id"); entryID == id {
+			return entry, nil
+		}
+	}
+	return nil, fmt.Errorf("application has no deployment with ID %d", id)
+}
+
+// getIdleApplication fetches the Application and makes sure no operation is in progress,
+// since ArgoCD only runs one operation at a time.
+func getIdleApplication(ctx context.Context, dc dynamic.Interface, name string) (*unstructured.Unstructured, error) {
+	obj, err := dc.Resource(applicationGVR).Namespace("argocd").Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if parseApplicationStatus(obj).Pending {
+		return nil, fmt.Errorf("application %s already has an operation in progress", name)
+	}
+	return obj, nil
+}
+
+// startOperation requests a sync by writing the operation field of the Application,
+// which is what the ArgoCD API and CLI do under the hood. ArgoCD records initiator
+// as the user who initiated the deployment in status.history.
+// See: https://argo-cd.readthedocs.io/en/stable/user-guide/sync-kubectl/
+func startOperation(ctx context.Context, dc dynamic.Interface, name, initiator string, sync map[string]any) error {
+	patch := map[string]any{
+		"operation": map[string]any{
+			"initiatedBy": map[string]any{"username": initiator},
+			"sync":        sync,
+		},
+	}
+	return patchApplication(ctx, dc, name, patch)
+}
+
+// waitForOperation waits for the operation requested on the Application, which was
+// idle as before, to succeed and for the Application to become Healthy, then reports
+// its status.
+func waitForOperation(ctx context.Context, dc dynamic.Interface, before *unstructured.Unstructured, timeout time.Duration) (*app.ActionResponse, error) {
+	name := before.GetName()
+	obj, err := waitForApplication(ctx, dc, "argocd", name, waitOptions{
+		Timeout:   timeout,
+		Progress:  logProgress,
+		Condition: evaluateOperation("argocd", name, parseApplicationStatus(before).StartedAt),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &app.ActionResponse{Output: actionOutput(obj)}, nil
+}
+
+// patchApplication applies a JSON merge patch to the Application.
+func patchApplication(ctx context.Context, dc dynamic.Interface, name string, patch map[string]any) error {
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	_, err = dc.Resource(applicationGVR).Namespace("argocd").Patch(ctx, name, types.MergePatchType, data, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to patch application %s: %w", name, err)
+	}
+	return nil
+}
+
+// actionOutput reports the status of the Application, matching the action_output.json schema.
+func actionOutput(obj *unstructured.Unstructured) map[string]any {
+	s := parseApplicationStatus(obj)
+	return map[string]any{
+		"sync_status":     s.Sync,
+		"health_status":   s.Health,
+		"revision":        s.Revision,
+		"operation_phase": s.Phase,
+		"message":         s.Message,
+	}
+}
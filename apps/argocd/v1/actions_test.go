@@ -0,0 +1,72 @@
+package appargocd
+
+import (
+	"testing"
+
+	"github.com/tempestdx/sdk-go/app"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestRollbackAction(t *testing.T) {
+	f := newFakeArgoCD(t)
+	created := createTestApplication(t, f, map[string]any{"target_revision": "v1.0.0"})
+
+	// Deploy v1.1.0, then switch to manual sync, which rollback requires
+	for _, input := range []map[string]any{
+		{"target_revision": "v1.1.0"},
+		{"sync_mode": "manual"},
+	} {
+		if _, err := updateFn(t.Context(), &app.OperationRequest{
+			Metadata:    testMetadata(),
+			Environment: f.env(),
+			Resource:    created,
+			Input:       input,
+		}); err != nil {
+			t.Fatalf("updateFn: %v", err)
+		}
+	}
+
+	// ArgoCD leaves the Application OutOfSync, since v1.0.0 isn't its target revision
+	res, err := rollbackAction(t.Context(), &app.ActionRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Resource:    created,
+		Input:       map[string]any{"id": float64(0), "prune": false},
+	})
+	if err != nil {
+		t.Fatalf("rollbackAction: %v", err)
+	}
+
+	for key, want := range map[string]any{
+		"sync_status":     "OutOfSync",
+		"health_status":   "Healthy",
+		"operation_phase": "Succeeded",
+	} {
+		if got := res.Output[key]; got != want {
+			t.Errorf("output %s = %v, want %v", key, got, want)
+		}
+	}
+
+	// Syncing another revision than the target revision leaves it OutOfSync too
+	res, err = syncAction(t.Context(), &app.ActionRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Resource:    created,
+		Input:       map[string]any{"revision": "v0.9.0", "prune": false},
+	})
+	if err != nil {
+		t.Fatalf("syncAction: %v", err)
+	}
+	if got := res.Output["sync_status"]; got != "OutOfSync" {
+		t.Errorf("output sync_status = %v, want OutOfSync", got)
+	}
+
+	obj := f.get(t, applicationGVR, "guestbook")
+	history, _, _ := unstructured.NestedSlice(obj.Object, "status", "history")
+	for i, want := range []string{"v1.0.0", "v0.9.0"} {
+		entry, _ := history[len(history)-2+i].(map[string]any)
+		if revision := entry["revision"]; revision != want {
+			t.Errorf("deployment %d revision = %v, want %s", len(history)-2+i, revision, want)
+		}
+	}
+}
@@ -15,30 +15,21 @@ https://docs.tempestdx.com/developer/private-apps/overview
 package appargocd
 
 import (
-	"bytes"
 	"context"
 	"embed"
 	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"hash/fnv"
-	"io/fs"
-	"os"
 	"strings"
-	"text/template"
 	"time"
 
-	"github.com/cenkalti/backoff/v4"
+	"github.com/tempestdx/examples/deps/kube"
 	"github.com/tempestdx/sdk-go/app"
-	"gopkg.in/yaml.v3"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
 )
 
 var (
@@ -48,19 +39,6 @@ var (
 	//go:embed schema/properties.json
 	propertiesSchema []byte
 
-	// ResourceDefinition tells Tempest:
-	// - What this resource is called ("application")
-	// - How to display it in the UI ("Application")
-	// - What lifecycle stage it belongs to (Deploy)
-	// - What properties it exposes (via JSON schema)
-	application = app.ResourceDefinition{
-		Type:             "application",                                            // Unique identifier for this resource type
-		DisplayName:      "Application",                                            // Human-readable name shown in Tempest UI
-		Description:      "Manages an ArgoCD Application in a Kubernetes cluster.", // Description for users
-		LifecycleStage:   app.LifecycleStageDeploy,                                 // This is a deployment-stage resource
-		PropertiesSchema: app.MustParseJSONSchema(propertiesSchema),                // Schema for resource properties
-	}
-
 	// Embed JSON schemas for create and update operations
 	// These schemas validate the input when users create or update applications
 	//go:embed schema/create.json
@@ -71,88 +49,59 @@ var (
 
 	// Embed the templates directory containing Kubernetes manifest templates
 	// Templates are processed with Go's text/template package to generate actual manifests
+	// ARGOCD_TEMPLATES_DIR can override them, see loadTemplate
 	//go:embed templates
 	templatesFS embed.FS
 )
 
+// newApplicationDefinition returns the ResourceDefinition that tells Tempest:
+// - What this resource is called ("application")
+// - How to display it in the UI ("Application")
+// - What lifecycle stage it belongs to (Deploy)
+// - What properties it exposes (via JSON schema)
+func newApplicationDefinition() app.ResourceDefinition {
+	return app.ResourceDefinition{
+		Type:             "application",                                            // Unique identifier for this resource type
+		DisplayName:      "Application",                                            // Human-readable name shown in Tempest UI
+		Description:      "Manages an ArgoCD Application in a Kubernetes cluster.", // Description for users
+		LifecycleStage:   app.LifecycleStageDeploy,                                 // This is a deployment-stage resource
+		PropertiesSchema: app.MustParseJSONSchema(propertiesSchema),                // Schema for resource properties
+	}
+}
+
 // ApplicationTemplateInput defines the data structure passed to Go templates
 // when generating ArgoCD Application manifests. This struct maps the user input
 // from Tempest to the template variables used in application.yaml.tmpl
 type ApplicationTemplateInput struct {
-	Name           string // Name of the ArgoCD Application
-	Namespace      string // Target namespace for deployed resources
-	SourcePath     string // Path within the Git repository
-	RepoURL        string // Git repository URL
-	Image          string // Container image to deploy
-	TargetRevision string // Git branch/tag/commit to deploy
+	Name        string              // Name of the ArgoCD Application
+	Namespace   string              // Target namespace for deployed resources
+	Server      string              // Target cluster's API server, defaults to the cluster ArgoCD runs in
+	Project     string              // ArgoCD AppProject of the Application, defaults to "default"
+	Destination string              // Name of a cluster registered with ArgoCD, replaces Server when set
+	Labels      map[string]string   // Labels of the Application, including the tempest.dev/project label
+	Annotations map[string]string   // Annotations of the Application, e.g. ArgoCD Notifications subscriptions
+	Sources     []ApplicationSource // Where to get manifests from; more than one renders spec.sources
+	SyncPolicy  SyncPolicy          // When and how ArgoCD syncs the Application
 }
 
 // secretTemplateInput defines the data structure for generating ArgoCD repository secrets
 // ArgoCD needs authentication credentials to access private Git repositories
+// Credentials are only set for the selected authentication mode (see credentials.go)
+// A repository Secret is shared by every Application using the repository, so it
+// only holds repository-level fields, never anything about the Application
+// Values are plain text, the template base64-encodes them with b64enc
 type secretTemplateInput struct {
 	GitHubAppID          string // GitHub App ID for authentication
 	GitHubInstallationID string // GitHub App Installation ID
-	DeployKey            string // SSH private key for Git access
-	Project              string // ArgoCD project name
-	Name                 string // Application name
-	RepoURL              string // Git repository URL
+	GitHubAppPrivateKey  string // GitHub App private key
+	SSHPrivateKey        string // SSH private key for Git access
+	Username             string // HTTPS username
+	Password             string // HTTPS password or token
+	RepoURL              string // Git repository URL, or URL prefix for credential templates
 	SecretName           string // Kubernetes secret name
+	SecretType           string // "repository" or "repo-creds"
 	Type                 string // Repository type (git)
-}
-
-// getConfigFromEnv creates a Kubernetes client configuration from environment variables
-// Tempest Private Apps receive configuration through environment variables passed
-// from the Tempest platform. This is how the app connects to the target Kubernetes cluster.
-func getConfigFromEnv(env map[string]app.EnvironmentVariable) (*rest.Config, error) {
-	kubeconfig, ok := env["KUBECONFIG"]
-	if !ok || kubeconfig.Value == "" {
-		return nil, errors.New("KUBECONFIG not found in environment")
-	}
-
-	// Build Kubernetes client config from the kubeconfig file path
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig.Value)
-	if err != nil {
-		return nil, fmt.Errorf("failed to build kubeconfig: %w", err)
-	}
-
-	return config, nil
-}
-
-// getDeployKeyFileFromEnv reads an SSH private key from a file specified in environment variables
-// This key is used by ArgoCD to authenticate with private Git repositories
-func getDeployKeyFileFromEnv(env map[string]app.EnvironmentVariable) (string, error) {
-	deployKeyFile, ok := env["DEPLOY_KEY_FILE"]
-	if !ok || deployKeyFile.Value == "" {
-		return "", errors.New("DEPLOY_KEY_FILE not found in environment")
-	}
-	content, err := os.ReadFile(deployKeyFile.Value)
-	if err != nil {
-		return "", fmt.Errorf("failed to read SSH private key file: %w", err)
-	}
-
-	return strings.TrimSpace(string(content)), nil
-}
-
-// getGitHubAppIDFromEnv retrieves the GitHub App ID from environment variables
-// Used for GitHub App-based authentication with ArgoCD
-func getGitHubAppIDFromEnv(env map[string]app.EnvironmentVariable) (string, error) {
-	githubAppID, ok := env["GITHUB_APP_ID"]
-	if !ok || githubAppID.Value == "" {
-		return "", errors.New("GITHUB_APP_ID not found in environment")
-	}
-
-	return githubAppID.Value, nil
-}
-
-// getGitHubInstallationIDFromEnv retrieves the GitHub App Installation ID from environment variables
-// This ID specifies which GitHub organization/repository the app is installed for
-func getGitHubInstallationIDFromEnv(env map[string]app.EnvironmentVariable) (string, error) {
-	githubInstallationID, ok := env["GITHUB_INSTALLATION_ID"]
-	if !ok || githubInstallationID.Value == "" {
-		return "", errors.New("GITHUB_INSTALLATION_ID not found in environment")
-	}
-
-	return githubInstallationID.Value, nil
+	Hash                 string // Content hash, used to detect changes
 }
 
 // createFn implements the CREATE operation for the application resource type
@@ -171,107 +120,157 @@ func createFn(ctx context.Context, req *app.OperationRequest) (*app.OperationRes
 		return nil, err
 	}
 
+	// Make sure the cluster is reachable and runs ArgoCD before applying anything
+	if err := validateCluster(ctx, config); err != nil {
+		return nil, err
+	}
+
 	// Create a dynamic Kubernetes client for applying manifests
 	// Dynamic clients can work with any Kubernetes resource type
-	dynamicClient, err := dynamic.NewForConfig(config)
+	dynamicClient, err := newDynamicClient(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
 	}
 
-	// Step 2: Extract GitHub authentication credentials from environment
-	githubAppID, err := getGitHubAppIDFromEnv(req.Environment)
+	syncTimeout, err := getSyncTimeoutFromEnv(req.Environment)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get GitHub app ID: %w", err)
+		return nil, err
 	}
 
-	githubInstallationID, err := getGitHubInstallationIDFromEnv(req.Environment)
+	// ARGOCD_CONFLICT_STRATEGY decides how changes made outside Tempest are handled
+	applyOpts, err := getApplyOptionsFromEnv(req.Environment)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get GitHub installation ID: %w", err)
+		return nil, err
 	}
 
-	// Step 3: Load and parse Go templates from embedded filesystem
-	// Templates are embedded at compile time for easy distribution
-	templates, err := fs.Sub(templatesFS, "templates")
+	// ARGOCD_URL, when set, links the Application to the ArgoCD UI
+	links, err := applicationLinks(req.Environment, req.Input["name"].(string))
 	if err != nil {
 		return nil, err
 	}
 
-	tmpl, err := template.ParseFS(templates, "application.yaml.tmpl")
+	// Step 2: Extract Git repository credentials from environment
+	// ARGOCD_REPO_AUTH selects GitHub App, SSH key, HTTPS token or no authentication
+	repoCreds, err := getRepoCredentialsFromEnv(req.Environment)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get repository credentials: %w", err)
 	}
 
-	// Step 4: Prepare template input from user-provided data
+	// Step 3: Prepare template input from user-provided data
 	// req.Input contains the validated user input matching create.json schema
-	applicationInput := ApplicationTemplateInput{
-		Name:           req.Input["name"].(string),
-		Namespace:      req.Input["namespace"].(string),
-		SourcePath:     req.Input["source_path"].(string),
-		RepoURL:        req.Input["repo_url"].(string),
-		Image:          req.Input["image"].(string),
-		TargetRevision: req.Input["target_revision"].(string),
+	// The "source_type" input selects between Kustomize, Helm, plain directory
+	// and multi-source Applications (see source.go)
+	sources, err := sourcesFromInput(req.Input["name"].(string), req.Input)
+	if err != nil {
+		return nil, err
 	}
 
-	// Step 5: Execute template to generate ArgoCD Application manifest
-	var applicationManifest bytes.Buffer
-	err = tmpl.Execute(&applicationManifest, applicationInput)
+	// The "sync_*" inputs select manual or automated sync (see syncpolicy.go)
+	syncPolicy, err := syncPolicyFromInput(req.Input)
 	if err != nil {
 		return nil, err
 	}
 
-	// Step 6: Prepare ArgoCD repository secret for Git authentication
-	deployKey, err := getDeployKeyFileFromEnv(req.Environment)
+	// Every Application is labeled with its Tempest project, so listFn can find it (see labels.go)
+	projectID, err := projectIDFromMetadata(req.Metadata)
 	if err != nil {
 		return nil, err
 	}
 
-	// Generate a deterministic secret name based on repository URL
-	// This ensures one secret per repository to avoid ArgoCD conflicts
-	h := fnv.New32a()
-	_, _ = h.Write([]byte(req.Input["repo_url"].(string)))
-	secretName := fmt.Sprintf("repo-%v", h.Sum32())
+	labels, err := labelsFromInput(req.Input, projectID)
+	if err != nil {
+		return nil, err
+	}
 
-	tmpl, err = template.ParseFS(templates, "argocd_secret.yaml.tmpl")
+	annotations, err := annotationsFromInput(req.Input)
 	if err != nil {
 		return nil, err
 	}
 
-	// ArgoCD secrets store authentication data as base64-encoded values
-	secretInput := secretTemplateInput{
-		DeployKey:            toBase64(deployKey),
-		GitHubAppID:          toBase64(githubAppID),
-		GitHubInstallationID: toBase64(githubInstallationID),
-		Project:              toBase64("default"),
-		Name:                 toBase64(req.Input["name"].(string)),
-		RepoURL:              toBase64(req.Input["repo_url"].(string)),
-		SecretName:           secretName,
-		Type:                 toBase64("git"),
+	// Record who created the Application, since ArgoCD only knows that automated sync deployed it (see history.go)
+	stampDeployment(annotations, req.Metadata, time.Now())
+
+	applicationInput := ApplicationTemplateInput{
+		Name:        req.Input["name"].(string),
+		Namespace:   req.Input["namespace"].(string),
+		Project:     stringInput(req.Input, "argocd_project"),
+		Destination: stringInput(req.Input, "destination_cluster"),
+		Labels:      labels,
+		Annotations: annotations,
+		Sources:     sources,
+		SyncPolicy:  syncPolicy,
+	}
+
+	// Clusters registered with the cluster resource are referenced by name (see cluster.go)
+	if err := checkDestinationCluster(ctx, dynamicClient, applicationInput.Destination); err != nil {
+		return nil, err
 	}
 
-	var secretManifest bytes.Buffer
-	err = tmpl.Execute(&secretManifest, secretInput)
+	// Step 4: Render the ArgoCD Application manifest
+	// Templates are embedded at compile time for easy distribution, and can be
+	// replaced by templates from ARGOCD_TEMPLATES_DIR (see render.go)
+	applicationManifest, err := renderTemplate(req.Environment, "application.yaml.tmpl", applicationInput)
 	if err != nil {
 		return nil, err
 	}
 
-	// Step 7: Apply the secret first (ArgoCD needs repository access)
-	uid, err := apply(ctx, dynamicClient, secretManifest.Bytes())
+	// Catch mistakes before anything is applied, rather than when the API server
+	// rejects the Application or ArgoCD fails to sync it (see validate.go)
+	applicationObj, err := decodeManifest(applicationManifest)
 	if err != nil {
 		return nil, err
 	}
-
-	if uid == "" {
-		return nil, fmt.Errorf("failed to apply secret manifest")
+	if err := validateApplication(ctx, dynamicClient, applicationObj); err != nil {
+		return nil, err
 	}
 
-	// Step 8: Apply the ArgoCD Application manifest
-	uid, err = apply(ctx, dynamicClient, applicationManifest.Bytes())
+	// Step 5: Prepare ArgoCD repository secrets for Git authentication
+	secretTmpl, err := loadTemplate(req.Environment, "argocd_secret.yaml.tmpl")
 	if err != nil {
 		return nil, err
 	}
 
+	// Step 6: Apply the secrets first (ArgoCD needs repository access)
+	// Each Git repository used by the Application gets its own secret,
+	// Helm chart repositories don't use these credentials
+	// From here on, the transaction records the objects this create adds to the cluster,
+	// and deletes them again if a later step fails, so nothing is left behind
+	tx := newTransaction(dynamicClient, applyOpts)
+	if err := applyRepositorySecrets(ctx, tx.apply, secretTmpl, sources, repoCreds); err != nil {
+		return nil, tx.rollback(ctx, err)
+	}
+
+	// Step 7: Apply the ArgoCD Application manifest
+	uid, err := tx.apply(ctx, applicationManifest)
+	if err != nil {
+		return nil, tx.rollback(ctx, err)
+	}
+
 	if uid == "" {
-		return nil, fmt.Errorf("failed to apply application manifest")
+		return nil, tx.rollback(ctx, fmt.Errorf("failed to apply application manifest"))
+	}
+
+	// Step 8: Wait for the ArgoCD Application to become Synced and Healthy
+	// This demonstrates how to wait for resources to reach desired state
+	// Manually synced Applications stay OutOfSync until someone syncs them, so there is nothing to wait for
+	if syncPolicy.Automated != nil {
+		if _, err := waitForApplication(ctx, dynamicClient, "argocd", applicationInput.Name, waitOptions{
+			Timeout:  syncTimeout,
+			Progress: logProgress,
+		}); err != nil {
+			return nil, tx.rollback(ctx, err)
+		}
+	}
+
+	// Report the status ArgoCD has reached, e.g. OutOfSync for manually synced Applications
+	live, err := getApplication(ctx, dynamicClient, applicationInput.Name)
+	if err != nil {
+		return nil, tx.rollback(ctx, err)
+	}
+
+	properties, err := applicationProperties(applicationInput, config.Host, live)
+	if err != nil {
+		return nil, tx.rollback(ctx, err)
 	}
 
 	// Step 9: Return resource metadata to Tempest
@@ -283,14 +282,8 @@ func createFn(ctx context.Context, req *app.OperationRequest) (*app.OperationRes
 		Resource: &app.Resource{
 			ExternalID:  strings.Join([]string{applicationInput.Namespace, applicationInput.Name, uid}, "/"),
 			DisplayName: applicationInput.Name,
-			Properties: map[string]any{
-				"name":        applicationInput.Name,
-				"namespace":   applicationInput.Namespace,
-				"repo_url":    applicationInput.RepoURL,
-				"source_path": applicationInput.SourcePath,
-				"image":       applicationInput.Image,
-				"cluster":     config.Host,
-			},
+			Links:       links,
+			Properties:  properties,
 		},
 	}, nil
 }
@@ -305,48 +298,105 @@ func updateFn(ctx context.Context, req *app.OperationRequest) (*app.OperationRes
 		return nil, err
 	}
 
-	dynamicClient, err := dynamic.NewForConfig(config)
+	if err := validateCluster(ctx, config); err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := newDynamicClient(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
 	}
 
-	// Parse the ExternalID to extract namespace, name, and UID
-	// ExternalID format: "namespace/name/uid"
-	id := strings.Split(req.Resource.ExternalID, "/")
-	if len(id) != 3 {
-		return nil, errors.New("invalid external ID")
+	syncTimeout, err := getSyncTimeoutFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
 	}
 
-	// Load and prepare templates
-	templates, err := fs.Sub(templatesFS, "templates")
+	// ARGOCD_CONFLICT_STRATEGY decides how changes made outside Tempest are handled
+	applyOpts, err := getApplyOptionsFromEnv(req.Environment)
 	if err != nil {
 		return nil, err
 	}
 
-	tmpl, err := template.ParseFS(templates, "application.yaml.tmpl")
+	// Parse the ExternalID to extract namespace and name
+	// ExternalID format: "namespace/name/uid"
+	namespace, name, err := parseExternalID(req.Resource.ExternalID)
+	if err != nil {
+		return nil, err
+	}
+
+	links, err := applicationLinks(req.Environment, name)
 	if err != nil {
 		return nil, err
 	}
 
+	repoCreds, err := getRepoCredentialsFromEnv(req.Environment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository credentials: %w", err)
+	}
+
 	// Prepare template input using existing resource metadata and new input
 	// For updates, we preserve the namespace and name from the existing resource
-	in := ApplicationTemplateInput{
-		Namespace:      id[0], // Preserve original namespace
-		Name:           id[1], // Preserve original name
-		SourcePath:     req.Input["source_path"].(string),
-		RepoURL:        req.Input["repo_url"].(string),
-		Image:          req.Input["image"].(string),
-		TargetRevision: req.Input["target_revision"].(string),
+	projectID, err := projectIDFromMetadata(req.Metadata)
+	if err != nil {
+		return nil, err
 	}
 
-	// Generate and apply the updated manifest
-	var manifest bytes.Buffer
-	err = tmpl.Execute(&manifest, in)
+	// Inputs left out of the update keep their value in the live Application,
+	// whose status is also remembered to wait for ArgoCD to report on the update
+	current, err := getApplication(ctx, dynamicClient, name)
 	if err != nil {
 		return nil, err
 	}
+	if err := checkProjectOwner("application", current, projectID); err != nil {
+		return nil, err
+	}
 
-	uid, err := apply(ctx, dynamicClient, manifest.Bytes())
+	input, err := updateInput(req.Input, current)
+	if err != nil {
+		return nil, err
+	}
+
+	in, err := updateTemplateInput(namespace, name, projectID, input)
+	if err != nil {
+		return nil, err
+	}
+	stampDeployment(in.Annotations, req.Metadata, time.Now())
+	keepTempestAnnotations(in.Annotations, current)
+
+	if err := checkDestinationCluster(ctx, dynamicClient, in.Destination); err != nil {
+		return nil, err
+	}
+
+	// Generate and validate the updated manifest before changing anything
+	manifest, err := renderTemplate(req.Environment, "application.yaml.tmpl", in)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := decodeManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateApplication(ctx, dynamicClient, obj); err != nil {
+		return nil, err
+	}
+
+	// Apply repository secrets for the new sources, and pick up rotated credentials
+	secretTmpl, err := loadTemplate(req.Environment, "argocd_secret.yaml.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	applyFn := func(ctx context.Context, manifest []byte) (string, error) {
+		return apply(ctx, dynamicClient, manifest, applyOpts)
+	}
+	if err := applyRepositorySecrets(ctx, applyFn, secretTmpl, in.Sources, repoCreds); err != nil {
+		return nil, err
+	}
+
+	// Apply the updated manifest
+	uid, err := apply(ctx, dynamicClient, manifest, applyOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -355,23 +405,131 @@ func updateFn(ctx context.Context, req *app.OperationRequest) (*app.OperationRes
 		return nil, fmt.Errorf("failed to update application manifest")
 	}
 
+	live, err := getApplication(ctx, dynamicClient, in.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Wait for ArgoCD to roll out the change, unless the Application is manually synced.
+	// An update that only changed labels or annotations leaves the generation as it
+	// was, and has nothing to roll out.
+	if in.SyncPolicy.Automated != nil && live.GetGeneration() != current.GetGeneration() {
+		if live, err = waitForApplication(ctx, dynamicClient, "argocd", in.Name, waitOptions{
+			Timeout:   syncTimeout,
+			Progress:  logProgress,
+			Condition: evaluateUpdate("argocd", in.Name, markReconcile(current)),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	properties, err := applicationProperties(in, config.Host, live)
+	if err != nil {
+		return nil, err
+	}
+
 	// Return updated resource metadata
 	return &app.OperationResponse{
 		Resource: &app.Resource{
 			ExternalID:  req.Resource.ExternalID, // Keep the same ExternalID
 			DisplayName: in.Name,
-			Properties: map[string]any{
-				"name":        in.Name,
-				"namespace":   in.Namespace,
-				"repo_url":    in.RepoURL,
-				"source_path": in.SourcePath,
-				"image":       in.Image,
-				"cluster":     config.Host,
-			},
+			Links:       links,
+			Properties:  properties,
 		},
 	}, nil
 }
 
+// updateTemplateInput builds the template input of an existing Application from update input
+// The namespace and name come from the ExternalID, since they can't be changed
+func updateTemplateInput(namespace, name, projectID string, input map[string]any) (ApplicationTemplateInput, error) {
+	sources, err := sourcesFromInput(name, input)
+	if err != nil {
+		return ApplicationTemplateInput{}, err
+	}
+
+	syncPolicy, err := syncPolicyFromInput(input)
+	if err != nil {
+		return ApplicationTemplateInput{}, err
+	}
+
+	labels, err := labelsFromInput(input, projectID)
+	if err != nil {
+		return ApplicationTemplateInput{}, err
+	}
+
+	annotations, err := annotationsFromInput(input)
+	if err != nil {
+		return ApplicationTemplateInput{}, err
+	}
+
+	return ApplicationTemplateInput{
+		Namespace:   namespace, // Preserve original namespace
+		Name:        name,      // Preserve original name
+		Project:     stringInput(input, "argocd_project"),
+		Destination: stringInput(input, "destination_cluster"),
+		Labels:      labels,
+		Annotations: annotations,
+		Sources:     sources,
+		SyncPolicy:  syncPolicy,
+	}, nil
+}
+
+// updateInput returns the update input, with the ArgoCD project, destination cluster,
+// source, sync, label and annotation inputs left out filled from the live Application,
+// so an update only changes the inputs it sets.
+func updateInput(input map[string]any, live *unstructured.Unstructured) (map[string]any, error) {
+	sources, err := sourcesFromApplication(live)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := sourceProperties(sources)
+	if err != nil {
+		return nil, err
+	}
+	if len(sources) > 0 && sources[0].Helm != nil {
+		current["helm_release_name"] = sources[0].Helm.ReleaseName
+	}
+
+	// The image input replaces the image named after the Application, so the live
+	// images are only kept when neither image nor images is set
+	_, hasImage := input["image"]
+	_, hasImages := input["images"]
+	if !hasImage && !hasImages {
+		current["images"] = toAnySlice(imageStrings(sourceImageOverrides(sources)))
+	}
+
+	syncPolicy, err := syncPolicyFromApplication(live)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range syncPolicyProperties(syncPolicy) {
+		// JSON numbers are decoded as float64, which syncPolicyFromInput expects
+		if n, ok := v.(int64); ok {
+			v = float64(n)
+		}
+		current[k] = v
+	}
+
+	// Labels and annotations set by users, rather than by the app or ArgoCD
+	current["labels"] = toAnySlice(keyValueStrings(userLabels(live)))
+	current["annotations"] = toAnySlice(keyValueStrings(userAnnotations(live)))
+
+	current["argocd_project"], _, _ = unstructured.NestedString(live.Object, "spec", "project")
+	// Other clusters are referenced by name, while the cluster ArgoCD runs in is
+	// referenced by server URL, which an empty destination_cluster renders
+	current["destination_cluster"], _, _ = unstructured.NestedString(live.Object, "spec", "destination", "name")
+
+	merged := make(map[string]any, len(input)+len(current))
+	for k, v := range current {
+		merged[k] = v
+	}
+	for k, v := range input {
+		merged[k] = v
+	}
+	return merged, nil
+}
+
 // readFn implements the READ operation for the application resource type
 // This function is called when Tempest needs to fetch current state of a resource
 // It demonstrates how to query Kubernetes resources and extract relevant data
@@ -382,160 +540,215 @@ func readFn(ctx context.Context, req *app.OperationRequest) (*app.OperationRespo
 		return nil, err
 	}
 
-	dynamicClient, err := dynamic.NewForConfig(config)
+	dynamicClient, err := newDynamicClient(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
 	}
 
 	// Parse ExternalID to get resource identifiers
-	id := strings.Split(req.Resource.ExternalID, "/")
-	if len(id) != 3 {
-		return nil, errors.New("invalid external ID")
-	}
-
-	// Define the GroupVersionResource for ArgoCD Applications
-	// This tells Kubernetes API which resource type we want to query
-	gvr := schema.GroupVersionResource{
-		Group:    "argoproj.io",  // ArgoCD API group
-		Version:  "v1alpha1",     // ArgoCD API version
-		Resource: "applications", // Resource type plural name
+	_, name, err := parseExternalID(req.Resource.ExternalID)
+	if err != nil {
+		return nil, err
 	}
 
 	// Fetch the ArgoCD Application from Kubernetes
 	// ArgoCD Applications are typically deployed in the "argocd" namespace
-	obj, err := dynamicClient.Resource(gvr).Namespace("argocd").Get(ctx, id[1], metav1.GetOptions{})
+	// applicationGVR tells the Kubernetes API which resource type we want to query
+	obj, err := dynamicClient.Resource(applicationGVR).Namespace("argocd").Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
 		return nil, err
 	}
 
-	// Extract fields directly from the unstructured object
-	// This avoids needing to deserialize to a typed ArgoCD Application
-	name := obj.GetName()
-	namespace := obj.GetNamespace()
-	
-	// Extract spec.source fields using unstructured helpers
-	repoURL, _, _ := unstructured.NestedString(obj.Object, "spec", "source", "repoURL")
-	sourcePath, _, _ := unstructured.NestedString(obj.Object, "spec", "source", "path")
-	
-	// Extract the current image from Kustomize configuration
-	var image string
-	images, found, _ := unstructured.NestedStringSlice(obj.Object, "spec", "source", "kustomize", "images")
-	if found && len(images) > 0 {
-		image = images[0]
+	// The tempest.dev/project label tells which Tempest project manages the Application.
+	// Applications without it, such as ones created before it was stamped, are still read.
+	if project, ok := obj.GetLabels()[projectLabel]; ok && req.Metadata != nil && req.Metadata.ProjectID != "" && project != req.Metadata.ProjectID {
+		return nil, fmt.Errorf("application %s is managed by another Tempest project (%s)", name, project)
+	}
+
+	resource, err := applicationResource(obj, req.Resource.ExternalID, config.Host, req.Environment)
+	if err != nil {
+		return nil, err
 	}
 
 	// Return current resource state to Tempest
-	return &app.OperationResponse{
-		Resource: &app.Resource{
-			ExternalID:  req.Resource.ExternalID,
-			DisplayName: name,
-			Properties: map[string]any{
-				"name":        name,
-				"namespace":   namespace,
-				"repo_url":    repoURL,
-				"source_path": sourcePath,
-				"image":       image,
-				"cluster":     config.Host,
-			},
-		},
-	}, nil
+	return &app.OperationResponse{Resource: resource}, nil
 }
 
-// apply is a helper function that applies Kubernetes manifests to the cluster
-// It handles both ArgoCD Applications and Kubernetes Secrets with appropriate logic
-// This function demonstrates the "apply" pattern used by kubectl and other tools
-func apply(ctx context.Context, dc *dynamic.DynamicClient, manifest []byte) (string, error) {
-	// Parse the YAML manifest and convert to JSON for unstructured object
-	// We use gopkg.in/yaml.v3 which is compatible with the rest of our dependencies
-	var yamlObj interface{}
-	err := yaml.Unmarshal(manifest, &yamlObj)
+// listFn implements the LIST operation for the application resource type
+// It finds the Applications of the requesting Tempest project by their tempest.dev/project label,
+// a page at a time. List requests carry no Tempest environment, so the cluster is configured
+// from the environment the app runs in (see processEnvironment).
+func listFn(ctx context.Context, req *app.ListRequest) (*app.ListResponse, error) {
+	env := processEnvironment()
+	config, err := getConfigFromEnv(env)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse YAML manifest: %w", err)
+		return nil, err
 	}
-	
-	// Convert to JSON
-	jsonBytes, err := json.Marshal(yamlObj)
+
+	dynamicClient, err := newDynamicClient(config)
 	if err != nil {
-		return "", fmt.Errorf("failed to convert YAML to JSON: %w", err)
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
 	}
-	
-	// Parse into unstructured object
-	obj := &unstructured.Unstructured{}
-	err = obj.UnmarshalJSON(jsonBytes)
+
+	projectID, err := projectIDFromMetadata(req.Metadata)
 	if err != nil {
-		return "", fmt.Errorf("failed to decode manifest: %w", err)
+		return nil, err
 	}
 
-	var res *unstructured.Unstructured
+	// Tempest passes back the Next token we returned, which is the Kubernetes continue token
+	list, err := dynamicClient.Resource(applicationGVR).Namespace("argocd").List(ctx, metav1.ListOptions{
+		LabelSelector: projectLabel + "=" + projectID,
+		Limit:         listPageSize,
+		Continue:      req.Next,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applications: %w", err)
+	}
 
-	// Handle different resource types with specific logic
-	switch obj.GetKind() {
-	case "Application":
-		// Define GroupVersionResource for ArgoCD Applications
-		gvr := schema.GroupVersionResource{
-			Group:    "argoproj.io",
-			Version:  "v1alpha1",
-			Resource: "applications",
-		}
+	resources := make([]*app.Resource, 0, len(list.Items))
+	for i := range list.Items {
+		obj := &list.Items[i]
+
+		// The ExternalID is built like createFn builds it, from the destination namespace
+		namespace, _, _ := unstructured.NestedString(obj.Object, "spec", "destination", "namespace")
+		externalID := strings.Join([]string{namespace, obj.GetName(), string(obj.GetUID())}, "/")
 
-		// Apply the Application manifest using server-side apply
-		// FieldManager identifies this app as the owner of applied fields
-		res, err = dc.Resource(gvr).Namespace(obj.GetNamespace()).Apply(ctx, obj.GetName(), obj, metav1.ApplyOptions{
-			FieldManager: "tempest", // Important: This identifies our app as the field manager
-		})
+		resource, err := applicationResource(obj, externalID, config.Host, env)
 		if err != nil {
-			return "", fmt.Errorf("failed to apply application manifest: %w", err)
+			return nil, fmt.Errorf("application %s: %w", obj.GetName(), err)
 		}
+		resources = append(resources, resource)
+	}
 
-		// Wait for the ArgoCD Application to become healthy
-		// This demonstrates how to wait for resources to reach desired state
-		if err := backoff.Retry(func() error {
-			var err error
+	return &app.ListResponse{
+		Resources: resources,
+		Next:      list.GetContinue(),
+	}, nil
+}
 
-			// Fetch the resource to check its status
-			app, err := dc.Resource(gvr).Namespace(obj.GetNamespace()).Get(ctx, obj.GetName(), metav1.GetOptions{})
-			if err != nil {
-				return fmt.Errorf("failed to fetch application: %w", err)
-			}
+// applicationResource builds the Tempest resource of a live Application, as reported by read and list
+func applicationResource(obj *unstructured.Unstructured, externalID, cluster string, env map[string]app.EnvironmentVariable) (*app.Resource, error) {
+	// Extract fields directly from the unstructured object
+	// This avoids needing to deserialize to a typed ArgoCD Application
+	// The namespace property is the destination namespace, like createFn reports it,
+	// not the namespace the Application itself lives in
+	name := obj.GetName()
+	namespace, _, _ := unstructured.NestedString(obj.Object, "spec", "destination", "namespace")
+
+	// Extract spec.source, or spec.sources for multi-source Applications
+	sources, err := sourcesFromApplication(obj)
+	if err != nil {
+		return nil, err
+	}
 
-			// Extract status fields from the ArgoCD Application
-			// ArgoCD Applications have sync status and health status
-			status, found, _ := unstructured.NestedString(app.Object, "status", "sync", "status")
-			health, foundHealth, _ := unstructured.NestedString(app.Object, "status", "health", "status")
+	syncPolicy, err := syncPolicyFromApplication(obj)
+	if err != nil {
+		return nil, err
+	}
 
-			if !found || !foundHealth {
-				return fmt.Errorf("status fields not found in application")
-			}
+	properties, err := applicationProperties(ApplicationTemplateInput{
+		Name:       name,
+		Namespace:  namespace,
+		Sources:    sources,
+		SyncPolicy: syncPolicy,
+	}, cluster, obj)
+	if err != nil {
+		return nil, err
+	}
 
-			// Check if the Application has reached the desired state
-			// "Synced" means ArgoCD has applied all manifests
-			// "Healthy" means all resources are running correctly
-			if status == "Synced" && health == "Healthy" {
-				fmt.Println("Application is Synced and Healthy!")
-				return nil
-			}
+	links, err := applicationLinks(env, name)
+	if err != nil {
+		return nil, err
+	}
 
-			return fmt.Errorf("application is not yet Synced and Healthy (sync: %s, health: %s)", status, health)
-		}, backoff.NewExponentialBackOff(
-			backoff.WithMaxElapsedTime(10*time.Minute), // Wait up to 10 minutes
-		)); err != nil {
-			return "", err
-		}
-	case "Secret":
-		// Define GroupVersionResource for Kubernetes Secrets
-		gvr := schema.GroupVersionResource{
-			Group:    "",        // Core Kubernetes API group (empty string)
-			Version:  "v1",      // Kubernetes API version
-			Resource: "secrets", // Resource type plural name
-		}
+	return &app.Resource{
+		ExternalID:  externalID,
+		DisplayName: name,
+		Links:       links,
+		Properties:  properties,
+	}, nil
+}
 
+// applicationProperties builds the properties exposed in the Tempest catalog,
+// matching the properties.json schema. obj is the live Application, whose status is reported.
+func applicationProperties(in ApplicationTemplateInput, cluster string, obj *unstructured.Unstructured) (map[string]any, error) {
+	properties, err := sourceProperties(in.Sources)
+	if err != nil {
+		return nil, err
+	}
+
+	// The deployed image is read back from the Kustomize image overrides
+	for k, v := range imageProperties(sourceImageOverrides(in.Sources)) {
+		properties[k] = v
+	}
+
+	for k, v := range syncPolicyProperties(in.SyncPolicy) {
+		properties[k] = v
+	}
+
+	// Sync and health status, the synced revision and managed resources come from ArgoCD
+	for k, v := range statusProperties(obj) {
+		properties[k] = v
+	}
+
+	// Lineage of the images, if they were promoted from another Application
+	for k, v := range promotionProperties(obj) {
+		properties[k] = v
+	}
+
+	// Deployment history from ArgoCD, and who last changed the Application through Tempest
+	for k, v := range historyProperties(obj) {
+		properties[k] = v
+	}
+
+	for k, v := range metadataProperties(obj) {
+		properties[k] = v
+	}
+
+	properties["name"] = in.Name
+	properties["namespace"] = in.Namespace
+	properties["argocd_project"], _, _ = unstructured.NestedString(obj.Object, "spec", "project")
+	properties["destination_cluster"] = destinationCluster(obj)
+	properties["cluster"] = cluster
+	return properties, nil
+}
+
+// destinationCluster returns the name of the cluster an Application deploys to,
+// or its API server URL when it is referenced by URL.
+func destinationCluster(obj *unstructured.Unstructured) string {
+	if name, _, _ := unstructured.NestedString(obj.Object, "spec", "destination", "name"); name != "" {
+		return name
+	}
+	server, _, _ := unstructured.NestedString(obj.Object, "spec", "destination", "server")
+	return server
+}
+
+// apply is a helper function that applies Kubernetes manifests to the cluster
+// It handles any kind restMapper knows, resolved through kube.Applier; Secrets get special handling so ArgoCD only ever sees one per repository
+// Applying an Application does not wait for it to sync; see waitForApplication
+// Conflicts with other field managers are handled according to opts (see conflict.go)
+// This function demonstrates the "apply" pattern used by kubectl and other tools
+func apply(ctx context.Context, dc dynamic.Interface, manifest []byte, opts applyOptions) (string, error) {
+	obj, err := decodeManifest(manifest)
+	if err != nil {
+		return "", err
+	}
+
+	client, _, err := newApplier(dc).ResourceClient(obj)
+	if err != nil {
+		return "", err
+	}
+
+	var res *unstructured.Unstructured
+
+	// Handle different resource types with specific logic
+	switch obj.GetKind() {
+	case "Secret":
 		// Check if a secret with the same name already exists
 		// ArgoCD does not support multiple secrets of the same type for a single repository
 		// Although Kubernetes allows multiple secret objects, ArgoCD will only recognize the first one
 		// To prevent conflicts, we ensure that only one secret object is maintained for each repository
-		//
-		// TODO: More advanced logic can be added here to detect changes and update the secret accordingly
-		res, err = dc.Resource(gvr).Namespace(obj.GetNamespace()).Get(ctx, obj.GetName(), metav1.GetOptions{})
+		res, err = client.Get(ctx, obj.GetName(), metav1.GetOptions{})
 
 		// Check if an error occurred and it's not a "not found" error
 		if err != nil {
@@ -544,25 +757,41 @@ func apply(ctx context.Context, dc *dynamic.DynamicClient, manifest []byte) (str
 				return "", fmt.Errorf("failed to check if secret exists: %w", err)
 			}
 			// If it's a "not found" error, continue to apply the secret
-		} else {
-			// If no error occurred, check if the response is valid
-			if res != nil {
-				// Secret already exists, return its UID
+		} else if res != nil {
+			// Secret already exists, leave it alone unless its content changed
+			// The content hash annotation lets us detect changes without comparing credentials
+			hash := obj.GetAnnotations()[credentialsHashAnnotation]
+			if hash != "" && res.GetAnnotations()[credentialsHashAnnotation] == hash {
 				return string(res.GetUID()), nil
 			}
 		}
 
-		// Continue to apply the secret if it was not found
-		res, err = dc.Resource(gvr).Namespace(obj.GetNamespace()).Apply(ctx, obj.GetName(), obj, metav1.ApplyOptions{
+		// Apply the secret if it was not found or its content changed
+		// Force takes ownership of fields changed by hand, e.g. credentials edited with kubectl,
+		// and server-side apply removes keys we no longer set, e.g. after switching auth mode
+		applyOpts := metav1.ApplyOptions{
 			FieldManager: "tempest", // Field manager for server-side apply
-		})
+			Force:        true,
+		}
+		if opts.DryRun {
+			applyOpts.DryRun = []string{metav1.DryRunAll}
+		}
+		res, err = client.Apply(ctx, obj.GetName(), obj, applyOpts)
 		if err != nil {
 			return "", fmt.Errorf("failed to apply secret manifest: %w", err)
 		}
-	}
 
-	if res == nil {
-		return "", fmt.Errorf("failed to apply resource")
+	default:
+		// Apply the manifest using server-side apply
+		// The "tempest" field manager identifies this app as the owner of applied fields
+		res, err = applyWithStrategy(ctx, client, obj, opts)
+		if err != nil {
+			var conflictErr *ConflictError
+			if errors.As(err, &conflictErr) {
+				return "", conflictErr
+			}
+			return "", fmt.Errorf("failed to apply %s manifest: %w", strings.ToLower(obj.GetKind()), err)
+		}
 	}
 
 	// Return the UID of the applied resource
@@ -570,7 +799,55 @@ func apply(ctx context.Context, dc *dynamic.DynamicClient, manifest []byte) (str
 	return string(res.GetUID()), nil
 }
 
-// toBase64 is a helper function to encode strings as base64
+// decodeManifest parses a YAML manifest holding a single object into an unstructured object
+// Rendering a template yields one object; manifests of several objects are applied with deps/kube directly
+func decodeManifest(manifest []byte) (*unstructured.Unstructured, error) {
+	objs, err := kube.Decode(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse YAML manifest: %w", err)
+	}
+	if len(objs) != 1 {
+		return nil, fmt.Errorf("expected a manifest of one object, got %d", len(objs))
+	}
+	return objs[0], nil
+}
+
+// restMapper resolves the kinds this app applies to the resources the dynamic client uses
+// It is static because the app only applies ArgoCD's own kinds and built-in kinds such as Secrets, which saves a discovery round trip
+// Apps applying arbitrary manifests use kube.New, which resolves kinds through discovery instead
+var restMapper = newRESTMapper()
+
+func newRESTMapper() meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(applicationGVR.GroupVersion().WithKind("Application"), meta.RESTScopeNamespace)
+	mapper.Add(applicationSetGVR.GroupVersion().WithKind("ApplicationSet"), meta.RESTScopeNamespace)
+	mapper.Add(appProjectGVR.GroupVersion().WithKind("AppProject"), meta.RESTScopeNamespace)
+	mapper.Add(secretGVR.GroupVersion().WithKind("Secret"), meta.RESTScopeNamespace)
+	mapper.Add(namespaceGVR.GroupVersion().WithKind("Namespace"), meta.RESTScopeRoot)
+	mapper.Add(resourceQuotaGVR.GroupVersion().WithKind("ResourceQuota"), meta.RESTScopeNamespace)
+	mapper.Add(limitRangeGVR.GroupVersion().WithKind("LimitRange"), meta.RESTScopeNamespace)
+	mapper.Add(networkPolicyGVR.GroupVersion().WithKind("NetworkPolicy"), meta.RESTScopeNamespace)
+	mapper.Add(roleBindingGVR.GroupVersion().WithKind("RoleBinding"), meta.RESTScopeNamespace)
+	return mapper
+}
+
+// newApplier returns an Applier resolving the kinds this app applies through restMapper
+// Objects without a namespace are ArgoCD's own, so they default to the argocd namespace
+func newApplier(dc dynamic.Interface) *kube.Applier {
+	return kube.NewForClients(dc, restMapper, kube.WithDefaultNamespace("argocd"))
+}
+
+// parseExternalID splits an ExternalID of the form "namespace/name/uid"
+// into the destination namespace and the Application name.
+func parseExternalID(externalID string) (namespace, name string, err error) {
+	id := strings.Split(externalID, "/")
+	if len(id) != 3 {
+		return "", "", errors.New("invalid external ID")
+	}
+	return id[0], id[1], nil
+}
+
+// toBase64 is a helper function to encode strings as base64, available to templates as b64enc
 // ArgoCD secrets require base64-encoded values for authentication data
 func toBase64(input string) string {
 	return base64.StdEncoding.EncodeToString([]byte(input))
@@ -584,6 +861,13 @@ func toBase64(input string) string {
 //
 // The returned app.App instance is what gets registered with Tempest
 func App() *app.App {
+	// Each App gets its own resource definitions, with the operations added below
+	application := newApplicationDefinition()
+	applicationSet := newApplicationSetDefinition()
+	appProject := newAppProjectDefinition()
+	cluster := newClusterDefinition()
+	namespaceDefinition := newNamespaceDefinition()
+
 	// Configure the CREATE operation with input validation schema
 	// When users create applications through Tempest, their input will be validated
 	// against the create.json schema before calling createFn
@@ -603,6 +887,60 @@ func App() *app.App {
 	// This allows Tempest to fetch current resource state
 	application.ReadFn(readFn)
 
+	// Configure the LIST operation, which lets Tempest discover the Applications
+	// labeled with the requesting project
+	application.ListFn(listFn)
+
+	// Configure operations that can be run against an existing application
+	// Each action validates its input against its own schema, and reports the
+	// resulting Application status using the shared action_output.json schema
+	application.AddActionDefinition(app.ActionDefinition{
+		Name:         "refresh",
+		DisplayName:  "Refresh",
+		Description:  "Compare the Application against its source again, without syncing it.",
+		InputSchema:  app.MustParseJSONSchema(refreshSchema),
+		OutputSchema: app.MustParseJSONSchema(actionOutputSchema),
+		Handler:      refreshAction,
+	})
+
+	application.AddActionDefinition(app.ActionDefinition{
+		Name:         "sync",
+		DisplayName:  "Sync",
+		Description:  "Sync the Application, optionally to a specific revision, and wait for it to become healthy.",
+		InputSchema:  app.MustParseJSONSchema(syncSchema),
+		OutputSchema: app.MustParseJSONSchema(actionOutputSchema),
+		Handler:      syncAction,
+	})
+
+	application.AddActionDefinition(app.ActionDefinition{
+		Name:         "rollback",
+		DisplayName:  "Rollback",
+		Description:  "Roll the Application back to a previous deployment from its history.",
+		InputSchema:  app.MustParseJSONSchema(rollbackSchema),
+		OutputSchema: app.MustParseJSONSchema(actionOutputSchema),
+		Handler:      rollbackAction,
+	})
+
+	// Preview an update without changing anything, using the same input as update
+	application.AddActionDefinition(app.ActionDefinition{
+		Name:         "preview_update",
+		DisplayName:  "Preview Update",
+		Description:  "Show how an update would change the Application, using a server-side dry run.",
+		InputSchema:  app.MustParseJSONSchema(updateSchema),
+		OutputSchema: app.MustParseJSONSchema(previewOutputSchema),
+		Handler:      previewAction,
+	})
+
+	// Promote the images of another Application, e.g. from staging to production
+	application.AddActionDefinition(app.ActionDefinition{
+		Name:         "promote",
+		DisplayName:  "Promote",
+		Description:  "Deploy the images of another Synced and Healthy Application, e.g. of the same service in a previous environment.",
+		InputSchema:  app.MustParseJSONSchema(promoteSchema),
+		OutputSchema: app.MustParseJSONSchema(actionOutputSchema),
+		Handler:      promoteAction,
+	})
+
 	// Configure a health check for this Tempest Private App
 	// Tempest calls this periodically to ensure the app is functioning
 	// Health checks help with monitoring and troubleshooting
@@ -613,9 +951,69 @@ func App() *app.App {
 		}, nil
 	})
 
+	// Configure the "applicationset" resource type, which generates Applications
+	// from the same source and sync inputs as the "application" resource
+	applicationSet.CreateFn(
+		createApplicationSetFn,
+		app.MustParseJSONSchema(applicationSetCreateSchema),
+	)
+	applicationSet.UpdateFn(
+		updateApplicationSetFn,
+		app.MustParseJSONSchema(applicationSetUpdateSchema),
+	)
+	applicationSet.DeleteFn(deleteApplicationSetFn)
+	applicationSet.ReadFn(readApplicationSetFn)
+	applicationSet.ListFn(listApplicationSetsFn)
+
+	// Configure the "appproject" resource type, which teams are onboarded with
+	// before their Applications can use it
+	appProject.CreateFn(
+		createAppProjectFn,
+		app.MustParseJSONSchema(appProjectCreateSchema),
+	)
+	appProject.UpdateFn(
+		updateAppProjectFn,
+		app.MustParseJSONSchema(appProjectUpdateSchema),
+	)
+	appProject.DeleteFn(deleteAppProjectFn)
+	appProject.ReadFn(readAppProjectFn)
+	appProject.ListFn(listAppProjectsFn)
+
+	// Configure the "cluster" resource type, which registers the clusters
+	// Applications deploy to by name
+	cluster.CreateFn(
+		createClusterFn,
+		app.MustParseJSONSchema(clusterCreateSchema),
+	)
+	cluster.UpdateFn(
+		updateClusterFn,
+		app.MustParseJSONSchema(clusterUpdateSchema),
+	)
+	cluster.DeleteFn(deleteClusterFn)
+	cluster.ReadFn(readClusterFn)
+	cluster.ListFn(listClustersFn)
+
+	// Configure the "namespace" resource type, which provisions the namespaces
+	// Applications deploy to with a quota, default limits and access for the team
+	namespaceDefinition.CreateFn(
+		createNamespaceFn,
+		app.MustParseJSONSchema(namespaceCreateSchema),
+	)
+	namespaceDefinition.UpdateFn(
+		updateNamespaceFn,
+		app.MustParseJSONSchema(namespaceUpdateSchema),
+	)
+	namespaceDefinition.DeleteFn(deleteNamespaceFn)
+	namespaceDefinition.ReadFn(readNamespaceFn)
+	namespaceDefinition.ListFn(listNamespacesFn)
+
 	// Create and return the Tempest Private App instance
-	// This app can manage "application" resources with full CRUD operations
+	// This app can manage "application", "applicationset", "appproject", "cluster" and "namespace" resources with full CRUD operations
 	return app.New(
 		app.WithResourceDefinition(application),
+		app.WithResourceDefinition(applicationSet),
+		app.WithResourceDefinition(appProject),
+		app.WithResourceDefinition(cluster),
+		app.WithResourceDefinition(namespaceDefinition),
 	)
 }
@@ -0,0 +1,509 @@
+package appargocd
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tempestdx/sdk-go/app"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestCreateFn(t *testing.T) {
+	f := newFakeArgoCD(t)
+
+	res, err := createFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Input:       applicationInput(nil),
+	})
+	if err != nil {
+		t.Fatalf("createFn: %v", err)
+	}
+
+	namespace, name, err := parseExternalID(res.Resource.ExternalID)
+	if err != nil || namespace != "default" || name != "guestbook" {
+		t.Errorf("ExternalID = %q, want default/guestbook/<uid>", res.Resource.ExternalID)
+	}
+	for key, want := range map[string]any{
+		"name":            "guestbook",
+		"namespace":       "default",
+		"argocd_project":  "default",
+		"sync_status":     "Synced",
+		"health_status":   "Healthy",
+		"synced_revision": "HEAD",
+		"cluster":         f.server.URL,
+	} {
+		if got := res.Resource.Properties[key]; got != want {
+			t.Errorf("property %s = %v, want %v", key, got, want)
+		}
+	}
+
+	obj := f.get(t, applicationGVR, "guestbook")
+	if got := obj.GetLabels()[projectLabel]; got != testProjectID {
+		t.Errorf("label %s = %q, want %q", projectLabel, got, testProjectID)
+	}
+	if path, _, _ := unstructured.NestedString(obj.Object, "spec", "source", "path"); path != "applications/guestbook" {
+		t.Errorf("spec.source.path = %q, want applications/guestbook", path)
+	}
+}
+
+func TestCreateFnDegraded(t *testing.T) {
+	f := newFakeArgoCD(t)
+	f.setHealth("guestbook", "Degraded")
+
+	_, err := createFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Input:       applicationInput(nil),
+	})
+
+	var rollbackErr *RollbackError
+	if !errors.As(err, &rollbackErr) {
+		t.Fatalf("createFn error = %v, want a RollbackError", err)
+	}
+	var syncErr *SyncError
+	if !errors.As(err, &syncErr) || syncErr.Health != "Degraded" {
+		t.Fatalf("createFn error = %v, want a SyncError for a Degraded Application", err)
+	}
+	if !strings.Contains(err.Error(), "unhealthy resources: default/Deployment/guestbook (Degraded: Deployment guestbook has exceeded its progress deadline)") {
+		t.Errorf("createFn error = %v, want the unhealthy Deployment", err)
+	}
+
+	// The Application is rolled back, so a retry starts from scratch
+	if f.exists(applicationGVR, "guestbook") {
+		t.Error("degraded Application was not deleted")
+	}
+}
+
+func TestCreateFnManualSync(t *testing.T) {
+	f := newFakeArgoCD(t)
+	f.setHealth("guestbook", "Missing")
+
+	// Manually synced Applications aren't waited for, so their status is only reported
+	res, err := createFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Input:       applicationInput(map[string]any{"sync_mode": "manual"}),
+	})
+	if err != nil {
+		t.Fatalf("createFn: %v", err)
+	}
+	if got := res.Resource.Properties["sync_mode"]; got != "manual" {
+		t.Errorf("property sync_mode = %v, want manual", got)
+	}
+}
+
+func TestCreateFnEnvironment(t *testing.T) {
+	f := newFakeArgoCD(t)
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{
+			name: "no cluster configured",
+			env:  map[string]string{},
+			want: "KUBECONFIG not found in environment",
+		},
+		{
+			name: "missing token",
+			env:  map[string]string{"KUBE_SERVER": f.server.URL},
+			want: "KUBE_TOKEN",
+		},
+		{
+			name: "invalid sync timeout",
+			env:  map[string]string{"KUBE_SERVER": f.server.URL, "KUBE_TOKEN": "token", "ARGOCD_SYNC_TIMEOUT": "soon"},
+			want: "invalid ARGOCD_SYNC_TIMEOUT",
+		},
+		{
+			name: "incomplete GitHub App credentials",
+			env:  map[string]string{"KUBE_SERVER": f.server.URL, "KUBE_TOKEN": "token", "GITHUB_APP_ID": "1"},
+			want: "GITHUB_INSTALLATION_ID",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := createFn(t.Context(), &app.OperationRequest{
+				Metadata:    testMetadata(),
+				Environment: testEnv(tt.env),
+				Input:       applicationInput(nil),
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("createFn error = %v, want it to mention %q", err, tt.want)
+			}
+			if f.exists(applicationGVR, "guestbook") {
+				t.Error("Application was created despite the invalid environment")
+			}
+		})
+	}
+}
+
+func TestCreateFnArgoCDNotInstalled(t *testing.T) {
+	f := newFakeArgoCD(t)
+	env := f.env()
+	env["KUBE_SERVER"] = app.EnvironmentVariable{Key: "KUBE_SERVER", Value: f.server.URL + "/no-argocd"}
+
+	_, err := createFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: env,
+		Input:       applicationInput(nil),
+	})
+	if err == nil || !strings.Contains(err.Error(), "ArgoCD is not installed") {
+		t.Fatalf("createFn error = %v, want ArgoCD is not installed", err)
+	}
+}
+
+func TestUpdateFn(t *testing.T) {
+	f := newFakeArgoCD(t)
+
+	created, err := createFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Input:       applicationInput(nil),
+	})
+	if err != nil {
+		t.Fatalf("createFn: %v", err)
+	}
+
+	res, err := updateFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Resource:    created.Resource,
+		Input:       applicationInput(map[string]any{"target_revision": "v1.2.0"}),
+	})
+	if err != nil {
+		t.Fatalf("updateFn: %v", err)
+	}
+
+	if res.Resource.ExternalID != created.Resource.ExternalID {
+		t.Errorf("ExternalID = %q, want it unchanged (%q)", res.Resource.ExternalID, created.Resource.ExternalID)
+	}
+	if got := res.Resource.Properties["synced_revision"]; got != "v1.2.0" {
+		t.Errorf("property synced_revision = %v, want v1.2.0", got)
+	}
+
+	obj := f.get(t, applicationGVR, "guestbook")
+	if revision, _, _ := unstructured.NestedString(obj.Object, "spec", "source", "targetRevision"); revision != "v1.2.0" {
+		t.Errorf("spec.source.targetRevision = %q, want v1.2.0", revision)
+	}
+	if string(obj.GetUID()) != strings.Split(created.Resource.ExternalID, "/")[2] {
+		t.Errorf("Application was replaced instead of updated")
+	}
+}
+
+func TestUpdateFnDegraded(t *testing.T) {
+	f := newFakeArgoCD(t)
+
+	created, err := createFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Input:       applicationInput(nil),
+	})
+	if err != nil {
+		t.Fatalf("createFn: %v", err)
+	}
+
+	// The new revision fails to roll out
+	f.setHealth("guestbook", "Degraded")
+	_, err = updateFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Resource:    created.Resource,
+		Input:       applicationInput(map[string]any{"target_revision": "v2.0.0"}),
+	})
+	var syncErr *SyncError
+	if !errors.As(err, &syncErr) || syncErr.Health != "Degraded" {
+		t.Fatalf("updateFn error = %v, want a SyncError for a Degraded Application", err)
+	}
+
+	// Unlike create, a failed update leaves the Application for ArgoCD to retry or roll back
+	if !f.exists(applicationGVR, "guestbook") {
+		t.Error("Application was deleted after a failed update")
+	}
+}
+
+func TestUpdateOtherProject(t *testing.T) {
+	f := newFakeArgoCD(t)
+
+	// Each resource is created by another Tempest project
+	other := testMetadata()
+	other.ProjectID = "proj-2"
+	create := func(fn app.OperationFunc, input map[string]any) *app.Resource {
+		t.Helper()
+		res, err := fn(t.Context(), &app.OperationRequest{Metadata: other, Environment: f.execEnv("argocd-k8s-auth"), Input: input})
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		return res.Resource
+	}
+	application := create(createFn, applicationInput(nil))
+	appProject := create(createAppProjectFn, map[string]any{
+		"name":         "payments",
+		"source_repos": []any{"https://github.com/tempestdx/*"},
+		"destinations": []any{"https://kubernetes.default.svc,payments-*"},
+	})
+	cluster := create(createClusterFn, map[string]any{
+		"name":         "production",
+		"server":       "https://production.invalid",
+		"auth_type":    "exec",
+		"exec_command": "argocd-k8s-auth",
+	})
+
+	tests := []struct {
+		name     string
+		fn       app.OperationFunc
+		resource *app.Resource
+		input    map[string]any
+	}{
+		{name: "application", fn: updateFn, resource: application, input: map[string]any{"target_revision": "v1.1.0"}},
+		{name: "appproject", fn: updateAppProjectFn, resource: appProject, input: map[string]any{
+			"source_repos": []any{"https://github.com/tempestdx/*"},
+			"destinations": []any{"https://kubernetes.default.svc,*"},
+		}},
+		{name: "cluster", fn: updateClusterFn, resource: cluster, input: map[string]any{"insecure": true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.fn(t.Context(), &app.OperationRequest{
+				Metadata:    testMetadata(),
+				Environment: f.env(),
+				Resource:    tt.resource,
+				Input:       tt.input,
+			})
+			if err == nil || !strings.Contains(err.Error(), "managed by another Tempest project (proj-2)") {
+				t.Errorf("update error = %v, want managed by another Tempest project", err)
+			}
+		})
+	}
+
+	if got := f.get(t, applicationGVR, "guestbook").GetLabels()[projectLabel]; got != "proj-2" {
+		t.Errorf("label %s = %q, want the Application kept by proj-2", projectLabel, got)
+	}
+}
+
+func TestUpdateFnKeepsOmittedInputs(t *testing.T) {
+	f := newFakeArgoCD(t, testAppProject("payments",
+		[]any{"https://github.com/tempestdx/*"},
+		[]any{map[string]any{"server": "https://kubernetes.default.svc", "namespace": "*"}},
+	))
+	created := createTestApplication(t, f, map[string]any{
+		"argocd_project":   "payments",
+		"target_revision":  "v1.0.0",
+		"image":            "registry.example.com/guestbook:1.0.0",
+		"sync_self_heal":   false,
+		"sync_retry_limit": float64(3),
+		"labels":           []any{"team=payments"},
+		"annotations":      []any{"notifications.argoproj.io/subscribe.on-sync-failed.slack=payments-alerts"},
+	})
+
+	// Without defaults in update.json, Tempest only sends the inputs that are set
+	_, err := updateFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Resource:    created,
+		Input:       map[string]any{"source_path": "applications/guestbook-v2"},
+	})
+	if err != nil {
+		t.Fatalf("updateFn: %v", err)
+	}
+
+	obj := f.get(t, applicationGVR, "guestbook")
+	if project, _, _ := unstructured.NestedString(obj.Object, "spec", "project"); project != "payments" {
+		t.Errorf("spec.project = %q, want payments", project)
+	}
+
+	source, _, _ := unstructured.NestedMap(obj.Object, "spec", "source")
+	want := map[string]any{
+		"repoURL":        "https://github.com/tempestdx/example-repository.git",
+		"path":           "applications/guestbook-v2",
+		"targetRevision": "v1.0.0",
+		"kustomize":      map[string]any{"images": []any{"guestbook=registry.example.com/guestbook:1.0.0"}},
+	}
+	if !reflect.DeepEqual(source, want) {
+		t.Errorf("spec.source = %v, want %v", source, want)
+	}
+
+	syncPolicy, _, _ := unstructured.NestedMap(obj.Object, "spec", "syncPolicy")
+	wantSyncPolicy := map[string]any{
+		"automated": map[string]any{"prune": true, "selfHeal": false, "allowEmpty": false},
+		"retry":     map[string]any{"limit": int64(3)},
+	}
+	if !reflect.DeepEqual(syncPolicy, wantSyncPolicy) {
+		t.Errorf("spec.syncPolicy = %v, want %v", syncPolicy, wantSyncPolicy)
+	}
+
+	if got := obj.GetLabels()["team"]; got != "payments" {
+		t.Errorf("label team = %q, want payments", got)
+	}
+	if got := obj.GetAnnotations()["notifications.argoproj.io/subscribe.on-sync-failed.slack"]; got != "payments-alerts" {
+		t.Errorf("notifications annotation = %q, want payments-alerts", got)
+	}
+
+	// An empty list removes them
+	if _, err := updateFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Resource:    created,
+		Input:       map[string]any{"labels": []any{}},
+	}); err != nil {
+		t.Fatalf("updateFn: %v", err)
+	}
+	obj = f.get(t, applicationGVR, "guestbook")
+	if _, ok := obj.GetLabels()["team"]; ok {
+		t.Error("label team was kept, want an empty labels input to remove it")
+	}
+	if obj.GetLabels()[projectLabel] != testProjectID || obj.GetAnnotations()["notifications.argoproj.io/subscribe.on-sync-failed.slack"] == "" {
+		t.Errorf("labels %v and annotations %v, want the project label and notifications annotation kept", obj.GetLabels(), obj.GetAnnotations())
+	}
+}
+
+func TestEvaluateUpdate(t *testing.T) {
+	source := map[string]any{"repoURL": "https://github.com/tempestdx/example-repository.git", "targetRevision": "v2"}
+	oldSource := map[string]any{"repoURL": "https://github.com/tempestdx/example-repository.git", "targetRevision": "v1"}
+	application := func(comparedTo map[string]any, reconciledAt, sync, health, phase, startedAt string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"spec": map[string]any{"source": source},
+			"status": map[string]any{
+				"reconciledAt":   reconciledAt,
+				"sync":           map[string]any{"status": sync, "comparedTo": map[string]any{"source": comparedTo}},
+				"health":         map[string]any{"status": health},
+				"operationState": map[string]any{"phase": phase, "startedAt": startedAt},
+			},
+		}}
+	}
+	// Before the update, the last sync of v1 failed
+	before := reconcileMark{
+		ReconciledAt: "2024-01-01T00:00:00Z",
+		StartedAt:    "2024-01-01T00:00:00Z",
+		ComparedTo:   map[string]any{"source": oldSource},
+	}
+
+	tests := []struct {
+		name    string
+		obj     *unstructured.Unstructured
+		want    bool
+		wantErr bool
+	}{
+		{name: "not compared yet", obj: application(oldSource, "2024-01-01T00:00:00Z", "Synced", "Healthy", "Succeeded", "2024-01-01T00:00:00Z")},
+		{name: "previous sync failed", obj: application(oldSource, "2024-01-01T00:00:00Z", "Synced", "Healthy", "Failed", "2024-01-01T00:00:00Z")},
+		{name: "compared, not synced yet", obj: application(source, "2024-01-01T00:01:00Z", "OutOfSync", "Healthy", "Failed", "2024-01-01T00:00:00Z")},
+		{name: "synced", obj: application(source, "2024-01-01T00:01:00Z", "Synced", "Healthy", "Succeeded", "2024-01-01T00:01:00Z"), want: true},
+		{name: "sync failed", obj: application(source, "2024-01-01T00:01:00Z", "OutOfSync", "Healthy", "Failed", "2024-01-01T00:01:00Z"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done, err := evaluateUpdate("argocd", "guestbook", before)(tt.obj, parseApplicationStatus(tt.obj))
+			if done != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("evaluateUpdate() = %v, %v, want %v, error %v", done, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	// An update that leaves the sources alone waits for ArgoCD to compare them again
+	unchanged := reconcileMark{ReconciledAt: "2024-01-01T00:00:00Z", ComparedTo: map[string]any{"source": source}}
+	stale := application(source, "2024-01-01T00:00:00Z", "Synced", "Healthy", "Succeeded", "")
+	if done, _ := evaluateUpdate("argocd", "guestbook", unchanged)(stale, parseApplicationStatus(stale)); done {
+		t.Error("evaluateUpdate() = true before ArgoCD compared the unchanged sources again")
+	}
+	fresh := application(source, "2024-01-01T00:01:00Z", "Synced", "Healthy", "Succeeded", "")
+	if done, _ := evaluateUpdate("argocd", "guestbook", unchanged)(fresh, parseApplicationStatus(fresh)); !done {
+		t.Error("evaluateUpdate() = false after ArgoCD compared the unchanged sources again")
+	}
+}
+
+func TestReadFn(t *testing.T) {
+	f := newFakeArgoCD(t, testApplication("guestbook", testProjectID), testApplication("billing", "proj-2"))
+
+	res, err := readFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Resource:    &app.Resource{ExternalID: "default/guestbook/uid-1"},
+	})
+	if err != nil {
+		t.Fatalf("readFn: %v", err)
+	}
+	for key, want := range map[string]any{
+		"name":          "guestbook",
+		"namespace":     "default",
+		"source_path":   "applications/guestbook",
+		"sync_status":   "Synced",
+		"health_status": "Healthy",
+	} {
+		if got := res.Resource.Properties[key]; got != want {
+			t.Errorf("property %s = %v, want %v", key, got, want)
+		}
+	}
+
+	// Applications of other Tempest projects are not read
+	_, err = readFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Resource:    &app.Resource{ExternalID: "default/billing/uid-2"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "managed by another Tempest project") {
+		t.Errorf("readFn error = %v, want managed by another Tempest project", err)
+	}
+
+	_, err = readFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Resource:    &app.Resource{ExternalID: "default/missing/uid-3"},
+	})
+	if err == nil {
+		t.Error("readFn of a missing Application succeeded")
+	}
+}
+
+func TestInvalidExternalID(t *testing.T) {
+	f := newFakeArgoCD(t)
+
+	operations := map[string]app.OperationFunc{
+		"read":   readFn,
+		"update": updateFn,
+	}
+	for _, externalID := range []string{"", "guestbook", "default/guestbook", "default/guestbook/uid/extra"} {
+		for name, fn := range operations {
+			t.Run(name+"/"+externalID, func(t *testing.T) {
+				_, err := fn(t.Context(), &app.OperationRequest{
+					Metadata:    testMetadata(),
+					Environment: f.env(),
+					Resource:    &app.Resource{ExternalID: externalID},
+					Input:       applicationInput(nil),
+				})
+				if err == nil || !strings.Contains(err.Error(), "invalid external ID") {
+					t.Fatalf("%s error = %v, want invalid external ID", name, err)
+				}
+			})
+		}
+	}
+}
+
+// testApplication returns a synced and healthy Application of a Tempest project.
+func testApplication(name, projectID string) runtime.Object {
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "argoproj.io/v1alpha1",
+		"kind":       "Application",
+		"metadata": map[string]any{
+			"name":      name,
+			"namespace": "argocd",
+			"labels":    map[string]any{projectLabel: projectID},
+		},
+		"spec": map[string]any{
+			"project": "default",
+			"source": map[string]any{
+				"repoURL":        "https://github.com/tempestdx/example-repository.git",
+				"path":           "applications/" + name,
+				"targetRevision": "HEAD",
+			},
+			"destination": map[string]any{"server": "https://kubernetes.default.svc", "namespace": "default"},
+		},
+		"status": map[string]any{
+			"sync":   map[string]any{"status": "Synced", "revision": "HEAD"},
+			"health": map[string]any{"status": "Healthy"},
+		},
+	}}
+}
@@ -0,0 +1,827 @@
+package appargocd
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tempestdx/sdk-go/app"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	watchtools "k8s.io/client-go/tools/watch"
+)
+
+// Generators accepted by the "generator_type" input.
+// See: https://argo-cd.readthedocs.io/en/stable/operator-manual/applicationset/Generators/
+const (
+	generatorList         = "list"          // One Application per element of a list
+	generatorClusters     = "clusters"      // One Application per cluster registered with ArgoCD
+	generatorGitDirectory = "git_directory" // One Application per directory of a Git repository
+)
+
+var (
+	// Embed the JSON schemas of the "applicationset" resource, like those of "application"
+	//go:embed schema/applicationset_properties.json
+	applicationSetPropertiesSchema []byte
+
+	//go:embed schema/applicationset_create.json
+	applicationSetCreateSchema []byte
+
+	//go:embed schema/applicationset_update.json
+	applicationSetUpdateSchema []byte
+
+	// applicationSetGVR identifies ArgoCD ApplicationSets for the dynamic client.
+	applicationSetGVR = schema.GroupVersionResource{
+		Group:    "argoproj.io",
+		Version:  "v1alpha1",
+		Resource: "applicationsets",
+	}
+)
+
+// newApplicationSetDefinition returns the second resource type of this app. An ApplicationSet
+// generates Applications from a single template, e.g. to deploy a service to dozens of clusters.
+func newApplicationSetDefinition() app.ResourceDefinition {
+	return app.ResourceDefinition{
+		Type:             "applicationset",
+		DisplayName:      "ApplicationSet",
+		Description:      "Manages an ArgoCD ApplicationSet, which generates Applications for many clusters, environments or directories.",
+		LifecycleStage:   app.LifecycleStageDeploy,
+		PropertiesSchema: app.MustParseJSONSchema(applicationSetPropertiesSchema),
+	}
+}
+
+// errApplicationSetFailed is wrapped by the error returned when the ApplicationSet
+// controller reports that it can't generate the Applications.
+var errApplicationSetFailed = errors.New("applicationset failed")
+
+// healthOrder ranks health statuses from best to worst, like ArgoCD does when it
+// aggregates the health of an Application's resources.
+var healthOrder = []string{"Healthy", "Suspended", "Progressing", "Missing", "Degraded", "Unknown"}
+
+// ApplicationSetTemplateInput defines the data passed to applicationset.yaml.tmpl.
+// Exactly one generator is set.
+type ApplicationSetTemplateInput struct {
+	Name         string                 // Name of the ArgoCD ApplicationSet
+	Labels       map[string]string      // Labels of the ApplicationSet, including the tempest.dev/project label
+	List         *ListGenerator         // Set for the list generator
+	Clusters     *ClusterGenerator      // Set for the clusters generator
+	GitDirectory *GitDirectoryGenerator // Set for the git_directory generator
+	Template     string                 // YAML of the Application template, rendered from application.yaml.tmpl
+}
+
+// ListGenerator generates one Application per element.
+type ListGenerator struct {
+	Elements []map[string]any // Parameters of each Application, e.g. cluster and url
+}
+
+// ClusterGenerator generates one Application per cluster registered with ArgoCD.
+type ClusterGenerator struct {
+	MatchLabels map[string]string // Labels of the cluster secrets to select, every cluster if empty
+}
+
+// GitDirectoryGenerator generates one Application per matching directory of a Git repository.
+type GitDirectoryGenerator struct {
+	RepoURL     string
+	Revision    string
+	Directories []string // Path patterns of the directories to include
+	Exclude     []string // Path patterns of the directories to leave out
+}
+
+// applicationSetFromInput builds the template input of an ApplicationSet from create
+// or update input, along with the template input of the Applications it generates.
+// The source and sync inputs are the same as those of the application resource.
+func applicationSetFromInput(name, projectID string, input map[string]any) (ApplicationSetTemplateInput, ApplicationTemplateInput, error) {
+	set := ApplicationSetTemplateInput{Name: name}
+	tmpl := ApplicationTemplateInput{
+		Namespace: stringInput(input, "namespace"),
+		Project:   stringInput(input, "argocd_project"),
+	}
+	if tmpl.Namespace == "" {
+		tmpl.Namespace = "default"
+	}
+
+	// Source inputs get generator-specific defaults, so don't change the caller's input
+	input = maps.Clone(input)
+
+	generatorType := stringInput(input, "generator_type")
+	if generatorType == "" {
+		generatorType = generatorList
+	}
+
+	switch generatorType {
+	case generatorList:
+		var elements []map[string]any
+		if err := decodeYAML([]byte(stringInput(input, "list_elements")), &elements); err != nil {
+			return set, tmpl, fmt.Errorf("invalid list_elements: %w", err)
+		}
+		if len(elements) == 0 {
+			return set, tmpl, errors.New("list_elements is required for list generators")
+		}
+		set.List = &ListGenerator{Elements: elements}
+
+		// By convention, elements name a cluster and its API server URL
+		tmpl.Name, tmpl.Server = "{{cluster}}-"+name, "{{url}}"
+		for _, key := range []string{"cluster", "url"} {
+			if stringInput(input, "application_name") != "" && key == "cluster" ||
+				stringInput(input, "destination_server") != "" && key == "url" {
+				continue
+			}
+			for i, e := range elements {
+				if _, ok := e[key]; !ok {
+					return set, tmpl, fmt.Errorf("list element %d has no %s, which the default Application name and server need", i+1, key)
+				}
+			}
+		}
+	case generatorClusters:
+		matchLabels, err := keyValueInput(input, "cluster_selector")
+		if err != nil {
+			return set, tmpl, err
+		}
+		set.Clusters = &ClusterGenerator{MatchLabels: matchLabels}
+		tmpl.Name, tmpl.Server = "{{name}}-"+name, "{{server}}"
+	case generatorGitDirectory:
+		git := &GitDirectoryGenerator{
+			RepoURL:     stringInput(input, "git_repo_url"),
+			Revision:    stringInput(input, "git_revision"),
+			Directories: stringSliceInput(input, "git_directories"),
+			Exclude:     stringSliceInput(input, "git_exclude_directories"),
+		}
+		if git.RepoURL == "" {
+			git.RepoURL = stringInput(input, "repo_url")
+		}
+		if git.RepoURL == "" {
+			return set, tmpl, errors.New("git_repo_url or repo_url is required for git_directory generators")
+		}
+		if len(git.Directories) == 0 {
+			return set, tmpl, errors.New("git_directories is required for git_directory generators")
+		}
+		set.GitDirectory = git
+
+		// Each Application deploys its own directory to the cluster ArgoCD runs in
+		tmpl.Name = "{{path.basename}}"
+		if stringInput(input, "repo_url") == "" {
+			input["repo_url"] = git.RepoURL
+		}
+		if stringInput(input, "source_path") == "" {
+			input["source_path"] = "{{path}}"
+		}
+	default:
+		return set, tmpl, fmt.Errorf("unsupported generator_type %q", generatorType)
+	}
+
+	if n := stringInput(input, "application_name"); n != "" {
+		tmpl.Name = n
+	}
+	if s := stringInput(input, "destination_server"); s != "" {
+		tmpl.Server = s
+	}
+
+	var err error
+	if tmpl.Sources, err = sourcesFromInput(name, input); err != nil {
+		return set, tmpl, err
+	}
+	if tmpl.SyncPolicy, err = syncPolicyFromInput(input); err != nil {
+		return set, tmpl, err
+	}
+	if tmpl.Annotations, err = annotationsFromInput(input); err != nil {
+		return set, tmpl, err
+	}
+
+	// Only the ApplicationSet gets the project label. Generated Applications are managed
+	// by the ApplicationSet controller, so they must not show up as Tempest applications.
+	if set.Labels, err = labelsFromInput(input, projectID); err != nil {
+		return set, tmpl, err
+	}
+	tmpl.Labels = maps.Clone(set.Labels)
+	delete(tmpl.Labels, projectLabel)
+
+	return set, tmpl, nil
+}
+
+// renderApplicationSet renders an ApplicationSet manifest. Its Application template is
+// rendered from application.yaml.tmpl, including overrides from ARGOCD_TEMPLATES_DIR,
+// so generated Applications look like Applications created by the application resource.
+func renderApplicationSet(env map[string]app.EnvironmentVariable, set ApplicationSetTemplateInput, tmpl ApplicationTemplateInput) ([]byte, error) {
+	manifest, err := renderTemplate(env, "application.yaml.tmpl", tmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := decodeManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	// An ApplicationSet template only holds the metadata and spec of an Application
+	set.Template, err = toYAML(map[string]any{
+		"metadata": obj.Object["metadata"],
+		"spec":     obj.Object["spec"],
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return renderTemplate(env, "applicationset.yaml.tmpl", set)
+}
+
+// applicationSetRepositories returns the sources whose repositories need credentials,
+// including the repository scanned by a git_directory generator. Repository URLs
+// filled in by the generator aren't known up front, so they are left out.
+func applicationSetRepositories(set ApplicationSetTemplateInput, tmpl ApplicationTemplateInput) []ApplicationSource {
+	var sources []ApplicationSource
+	if set.GitDirectory != nil {
+		sources = append(sources, ApplicationSource{RepoURL: set.GitDirectory.RepoURL})
+	}
+	for _, s := range tmpl.Sources {
+		if !strings.Contains(s.RepoURL, "{{") {
+			sources = append(sources, s)
+		}
+	}
+	return sources
+}
+
+// createApplicationSetFn implements the CREATE operation for the applicationset resource type.
+// It follows createFn: repository secrets and the ApplicationSet are applied in a
+// transaction, which is rolled back if the ApplicationSet fails to generate its Applications.
+func createApplicationSetFn(ctx context.Context, req *app.OperationRequest) (*app.OperationResponse, error) {
+	config, err := getConfigFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateCluster(ctx, config); err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := newDynamicClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	syncTimeout, err := getSyncTimeoutFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	applyOpts, err := getApplyOptionsFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	repoCreds, err := getRepoCredentialsFromEnv(req.Environment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository credentials: %w", err)
+	}
+
+	projectID, err := projectIDFromMetadata(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	name := req.Input["name"].(string)
+	set, tmpl, err := applicationSetFromInput(name, projectID, req.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := renderApplicationSet(req.Environment, set, tmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	secretTmpl, err := loadTemplate(req.Environment, "argocd_secret.yaml.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	tx := newTransaction(dynamicClient, applyOpts)
+	if err := applyRepositorySecrets(ctx, tx.apply, secretTmpl, applicationSetRepositories(set, tmpl), repoCreds); err != nil {
+		return nil, tx.rollback(ctx, err)
+	}
+
+	uid, err := tx.apply(ctx, manifest)
+	if err != nil {
+		return nil, tx.rollback(ctx, err)
+	}
+
+	// Wait for the controller to generate the Applications; their own syncs are not waited for
+	obj, err := waitForApplicationSet(ctx, dynamicClient, name, syncTimeout)
+	if err != nil {
+		return nil, tx.rollback(ctx, err)
+	}
+
+	resource, err := applicationSetResource(ctx, dynamicClient, obj, strings.Join([]string{"argocd", name, uid}, "/"), config.Host)
+	if err != nil {
+		return nil, tx.rollback(ctx, err)
+	}
+
+	return &app.OperationResponse{Resource: resource}, nil
+}
+
+// updateApplicationSetFn implements the UPDATE operation for the applicationset resource type.
+// The ApplicationSet controller then updates, creates or deletes the generated Applications.
+func updateApplicationSetFn(ctx context.Context, req *app.OperationRequest) (*app.OperationResponse, error) {
+	config, err := getConfigFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateCluster(ctx, config); err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := newDynamicClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	syncTimeout, err := getSyncTimeoutFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	applyOpts, err := getApplyOptionsFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	_, name, err := parseExternalID(req.Resource.ExternalID)
+	if err != nil {
+		return nil, err
+	}
+
+	repoCreds, err := getRepoCredentialsFromEnv(req.Environment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository credentials: %w", err)
+	}
+
+	projectID, err := projectIDFromMetadata(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	live, err := dynamicClient.Resource(applicationSetGVR).Namespace("argocd").Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get applicationset %s: %w", name, err)
+	}
+	if err := checkProjectOwner("applicationset", live, projectID); err != nil {
+		return nil, err
+	}
+
+	input, err := applicationSetUpdateInput(req.Input, live)
+	if err != nil {
+		return nil, err
+	}
+
+	set, tmpl, err := applicationSetFromInput(name, projectID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := renderApplicationSet(req.Environment, set, tmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	secretTmpl, err := loadTemplate(req.Environment, "argocd_secret.yaml.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	applyFn := func(ctx context.Context, manifest []byte) (string, error) {
+		return apply(ctx, dynamicClient, manifest, applyOpts)
+	}
+	if err := applyRepositorySecrets(ctx, applyFn, secretTmpl, applicationSetRepositories(set, tmpl), repoCreds); err != nil {
+		return nil, err
+	}
+
+	if _, err := apply(ctx, dynamicClient, manifest, applyOpts); err != nil {
+		return nil, err
+	}
+
+	obj, err := waitForApplicationSet(ctx, dynamicClient, name, syncTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	resource, err := applicationSetResource(ctx, dynamicClient, obj, req.Resource.ExternalID, config.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	return &app.OperationResponse{Resource: resource}, nil
+}
+
+// applicationSetUpdateInput returns the update input of an ApplicationSet, with the
+// inputs left out filled from the live ApplicationSet, so an update only changes the
+// inputs it sets. The source, sync and project inputs are read from its Application
+// template by updateInput. When the update changes the generator, the Application
+// name, server and path the live generator defaulted are not kept, since the new
+// generator has parameters of its own.
+func applicationSetUpdateInput(input map[string]any, live *unstructured.Unstructured) (map[string]any, error) {
+	template, _, _ := unstructured.NestedMap(live.Object, "spec", "template")
+	tmplObj := &unstructured.Unstructured{Object: template}
+
+	merged, err := updateInput(input, tmplObj)
+	if err != nil {
+		return nil, err
+	}
+	// The ApplicationSet is labeled, rather than its Application template
+	delete(merged, "destination_cluster")
+	if _, ok := input["labels"]; !ok {
+		merged["labels"] = toAnySlice(keyValueStrings(userLabels(live)))
+	}
+
+	current, err := generatorProperties(live)
+	if err != nil {
+		return nil, err
+	}
+	current["namespace"], _, _ = unstructured.NestedString(template, "spec", "destination", "namespace")
+	if generatorType, ok := input["generator_type"]; !ok || generatorType == current["generator_type"] {
+		current["application_name"], _, _ = unstructured.NestedString(template, "metadata", "name")
+		current["destination_server"], _, _ = unstructured.NestedString(template, "spec", "destination", "server")
+	} else if _, ok := input["source_path"]; !ok && current["generator_type"] == generatorGitDirectory && merged["source_path"] == "{{path}}" {
+		delete(merged, "source_path")
+	}
+
+	for k, v := range current {
+		if _, ok := input[k]; !ok {
+			merged[k] = v
+		}
+	}
+	return merged, nil
+}
+
+// readApplicationSetFn implements the READ operation for the applicationset resource type.
+// Besides the ApplicationSet's own configuration, it reports the generated Applications.
+func readApplicationSetFn(ctx context.Context, req *app.OperationRequest) (*app.OperationResponse, error) {
+	config, err := getConfigFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := newDynamicClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	_, name, err := parseExternalID(req.Resource.ExternalID)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := dynamicClient.Resource(applicationSetGVR).Namespace("argocd").Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	if project, ok := obj.GetLabels()[projectLabel]; ok && req.Metadata != nil && req.Metadata.ProjectID != "" && project != req.Metadata.ProjectID {
+		return nil, fmt.Errorf("applicationset %s is managed by another Tempest project (%s)", name, project)
+	}
+
+	resource, err := applicationSetResource(ctx, dynamicClient, obj, req.Resource.ExternalID, config.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	return &app.OperationResponse{Resource: resource}, nil
+}
+
+// deleteApplicationSetFn implements the DELETE operation for the applicationset resource type.
+// Kubernetes garbage collects the generated Applications, which are owned by the ApplicationSet,
+// and ArgoCD's resources finalizer then removes everything they deployed.
+// Repository secrets may be shared with other Applications, so they are kept.
+func deleteApplicationSetFn(ctx context.Context, req *app.OperationRequest) (*app.OperationResponse, error) {
+	config, err := getConfigFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := newDynamicClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	_, name, err := parseExternalID(req.Resource.ExternalID)
+	if err != nil {
+		return nil, err
+	}
+
+	propagation := metav1.DeletePropagationBackground
+	err = dynamicClient.Resource(applicationSetGVR).Namespace("argocd").Delete(ctx, name, metav1.DeleteOptions{
+		PropagationPolicy: &propagation,
+	})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to delete applicationset %s: %w", name, err)
+	}
+
+	return &app.OperationResponse{
+		Resource: &app.Resource{
+			ExternalID: req.Resource.ExternalID,
+		},
+	}, nil
+}
+
+// listApplicationSetsFn implements the LIST operation for the applicationset resource type.
+// Like listFn, it finds the ApplicationSets labeled with the requesting Tempest project.
+func listApplicationSetsFn(ctx context.Context, req *app.ListRequest) (*app.ListResponse, error) {
+	config, err := getConfigFromEnv(processEnvironment())
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := newDynamicClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	projectID, err := projectIDFromMetadata(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := dynamicClient.Resource(applicationSetGVR).Namespace("argocd").List(ctx, metav1.ListOptions{
+		LabelSelector: projectLabel + "=" + projectID,
+		Limit:         listPageSize,
+		Continue:      req.Next,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applicationsets: %w", err)
+	}
+
+	resources := make([]*app.Resource, 0, len(list.Items))
+	for i := range list.Items {
+		obj := &list.Items[i]
+		externalID := strings.Join([]string{"argocd", obj.GetName(), string(obj.GetUID())}, "/")
+		resource, err := applicationSetResource(ctx, dynamicClient, obj, externalID, config.Host)
+		if err != nil {
+			return nil, fmt.Errorf("applicationset %s: %w", obj.GetName(), err)
+		}
+		resources = append(resources, resource)
+	}
+
+	return &app.ListResponse{
+		Resources: resources,
+		Next:      list.GetContinue(),
+	}, nil
+}
+
+// waitForApplicationSet watches an ApplicationSet until the controller reports that the
+// generated Applications are up to date, and fails fast if it reports an error.
+func waitForApplicationSet(ctx context.Context, dc dynamic.Interface, name string, timeout time.Duration) (*unstructured.Unstructured, error) {
+	ctx, cancel := watchtools.ContextWithOptionalTimeout(ctx, timeout)
+	defer cancel()
+
+	obj, err := watchObject(ctx, dc, applicationSetGVR, "argocd", name,
+		func(obj *unstructured.Unstructured) (bool, error) {
+			conditions := applicationSetConditions(obj)
+			if msg, ok := conditions["ErrorOccurred"]; ok {
+				return false, fmt.Errorf("%w: applicationset %s: %s", errApplicationSetFailed, name, msg)
+			}
+			_, ok := conditions["ResourcesUpToDate"]
+			return ok, nil
+		},
+		func() error {
+			return fmt.Errorf("%w: applicationset %s was deleted", errApplicationSetFailed, name)
+		},
+	)
+	switch {
+	case err == nil:
+		return obj, nil
+	case errors.Is(err, errApplicationSetFailed):
+		return obj, err
+	case ctx.Err() != nil:
+		return obj, fmt.Errorf("applicationset %s did not generate its applications: %v", name, context.Cause(ctx))
+	}
+	return obj, fmt.Errorf("failed to watch applicationset %s: %w", name, err)
+}
+
+// applicationSetConditions returns the messages of the ApplicationSet's conditions
+// whose status is True, by condition type.
+func applicationSetConditions(obj *unstructured.Unstructured) map[string]string {
+	out := map[string]string{}
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		m, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		status, _, _ := unstructured.NestedString(m, "status")
+		if status != "True" {
+			continue
+		}
+		t, _, _ := unstructured.NestedString(m, "type")
+		msg, _, _ := unstructured.NestedString(m, "message")
+		out[t] = msg
+	}
+	return out
+}
+
+// applicationSetResource builds the Tempest resource of a live ApplicationSet.
+func applicationSetResource(ctx context.Context, dc dynamic.Interface, obj *unstructured.Unstructured, externalID, cluster string) (*app.Resource, error) {
+	properties, err := applicationSetProperties(obj, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	children, err := generatedApplications(ctx, dc, obj)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range generatedApplicationProperties(children) {
+		properties[k] = v
+	}
+
+	return &app.Resource{
+		ExternalID:  externalID,
+		DisplayName: obj.GetName(),
+		Properties:  properties,
+	}, nil
+}
+
+// applicationSetProperties builds the properties of an ApplicationSet, matching the
+// applicationset_properties.json schema, except for those of the generated Applications.
+func applicationSetProperties(obj *unstructured.Unstructured, cluster string) (map[string]any, error) {
+	// The Application template is read back like an Application
+	template, _, _ := unstructured.NestedMap(obj.Object, "spec", "template")
+	tmplObj := &unstructured.Unstructured{Object: template}
+
+	sources, err := sourcesFromApplication(tmplObj)
+	if err != nil {
+		return nil, err
+	}
+
+	syncPolicy, err := syncPolicyFromApplication(tmplObj)
+	if err != nil {
+		return nil, err
+	}
+
+	properties, err := sourceProperties(sources)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range imageProperties(sourceImageOverrides(sources)) {
+		properties[k] = v
+	}
+	for k, v := range syncPolicyProperties(syncPolicy) {
+		properties[k] = v
+	}
+
+	generator, err := generatorProperties(obj)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range generator {
+		properties[k] = v
+	}
+
+	// Labels are those of the ApplicationSet, annotations those of the generated Applications
+	for k, v := range metadataProperties(obj) {
+		properties[k] = v
+	}
+	properties["annotations"] = metadataProperties(tmplObj)["annotations"]
+
+	conditions := []string{}
+	for t, msg := range applicationSetConditions(obj) {
+		conditions = append(conditions, t+": "+msg)
+	}
+	properties["conditions"] = toAnySlice(sortedStrings(conditions))
+
+	properties["name"] = obj.GetName()
+	properties["namespace"], _, _ = unstructured.NestedString(template, "spec", "destination", "namespace")
+	properties["argocd_project"], _, _ = unstructured.NestedString(template, "spec", "project")
+	properties["application_name"], _, _ = unstructured.NestedString(template, "metadata", "name")
+	properties["destination_server"], _, _ = unstructured.NestedString(template, "spec", "destination", "server")
+	properties["cluster"] = cluster
+	return properties, nil
+}
+
+// generatorProperties reports the first generator of an ApplicationSet. Generators
+// this app doesn't create, such as matrix, are reported by their name only.
+func generatorProperties(obj *unstructured.Unstructured) (map[string]any, error) {
+	props := map[string]any{
+		"generator_type":          "",
+		"list_elements":           "",
+		"cluster_selector":        []any{},
+		"git_repo_url":            "",
+		"git_revision":            "",
+		"git_directories":         []any{},
+		"git_exclude_directories": []any{},
+	}
+
+	generators, _, _ := unstructured.NestedSlice(obj.Object, "spec", "generators")
+	if len(generators) == 0 {
+		return props, nil
+	}
+	generator, _ := generators[0].(map[string]any)
+
+	switch {
+	case generator["list"] != nil:
+		props["generator_type"] = generatorList
+		elements, _, _ := unstructured.NestedSlice(generator, "list", "elements")
+		out, err := encodeYAML(elements)
+		if err != nil {
+			return nil, err
+		}
+		props["list_elements"] = out
+	case generator["clusters"] != nil:
+		props["generator_type"] = generatorClusters
+		matchLabels, _, _ := unstructured.NestedStringMap(generator, "clusters", "selector", "matchLabels")
+		props["cluster_selector"] = toAnySlice(keyValueStrings(matchLabels))
+	case generator["git"] != nil:
+		props["generator_type"] = generatorGitDirectory
+		props["git_repo_url"], _, _ = unstructured.NestedString(generator, "git", "repoURL")
+		props["git_revision"], _, _ = unstructured.NestedString(generator, "git", "revision")
+		directories, _, _ := unstructured.NestedSlice(generator, "git", "directories")
+		var include, exclude []string
+		for _, d := range directories {
+			m, _ := d.(map[string]any)
+			path, _, _ := unstructured.NestedString(m, "path")
+			if excluded, _, _ := unstructured.NestedBool(m, "exclude"); excluded {
+				exclude = append(exclude, path)
+			} else {
+				include = append(include, path)
+			}
+		}
+		props["git_directories"] = toAnySlice(include)
+		props["git_exclude_directories"] = toAnySlice(exclude)
+	default:
+		for k := range generator {
+			props["generator_type"] = k
+		}
+	}
+
+	return props, nil
+}
+
+// generatedApplications lists the Applications owned by an ApplicationSet.
+func generatedApplications(ctx context.Context, dc dynamic.Interface, set *unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	list, err := dc.Resource(applicationGVR).Namespace("argocd").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applications of applicationset %s: %w", set.GetName(), err)
+	}
+
+	var out []*unstructured.Unstructured
+	for i := range list.Items {
+		for _, ref := range list.Items[i].GetOwnerReferences() {
+			if ref.UID == set.GetUID() {
+				out = append(out, &list.Items[i])
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// generatedApplicationProperties reports the generated Applications and their aggregate
+// health: the worst health of any Application, and Synced only if all of them are.
+func generatedApplicationProperties(apps []*unstructured.Unstructured) map[string]any {
+	health, sync := "Missing", "Unknown"
+	names := make([]string, 0, len(apps))
+	for i, obj := range apps {
+		s := parseApplicationStatus(obj)
+		names = append(names, fmt.Sprintf("%s: %s, %s", obj.GetName(), s.Health, s.Sync))
+
+		if i == 0 || healthRank(s.Health) > healthRank(health) {
+			health = s.Health
+		}
+		if i == 0 || s.Sync != "Synced" {
+			sync = s.Sync
+		}
+	}
+	if sync != "Synced" && len(apps) > 0 {
+		sync = "OutOfSync"
+	}
+
+	return map[string]any{
+		"applications":      toAnySlice(sortedStrings(names)),
+		"application_count": len(apps),
+		"health_status":     health,
+		"sync_status":       sync,
+	}
+}
+
+// healthRank returns the position of a health status in healthOrder.
+// Statuses ArgoCD hasn't assessed yet rank as Unknown.
+func healthRank(health string) int {
+	for i, h := range healthOrder {
+		if h == health {
+			return i
+		}
+	}
+	return len(healthOrder) - 1
+}
+
+// sortedStrings sorts s in place and returns it.
+func sortedStrings(s []string) []string {
+	sort.Strings(s)
+	return s
+}
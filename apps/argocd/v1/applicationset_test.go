@@ -0,0 +1,190 @@
+package appargocd
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tempestdx/sdk-go/app"
+)
+
+func TestApplicationSetGenerators(t *testing.T) {
+	tests := []struct {
+		name      string
+		generator map[string]any
+		property  string // Generator property the update must keep
+		want      any
+	}{
+		{
+			name: "list",
+			generator: map[string]any{
+				"generator_type": "list",
+				"list_elements":  "- cluster: staging\n  url: https://staging.invalid\n",
+			},
+			property: "application_name",
+			want:     "{{cluster}}-guestbook",
+		},
+		{
+			name: "clusters",
+			generator: map[string]any{
+				"generator_type":   "clusters",
+				"cluster_selector": []any{"env=production"},
+			},
+			property: "cluster_selector",
+			want:     "env=production",
+		},
+		{
+			name: "git_directory",
+			generator: map[string]any{
+				"generator_type":          "git_directory",
+				"source_path":             "",
+				"git_directories":         []any{"services/*"},
+				"git_exclude_directories": []any{"services/legacy"},
+			},
+			property: "git_exclude_directories",
+			want:     "services/legacy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeArgoCD(t)
+
+			input := applicationSetInput(tt.generator)
+			input["namespace"] = "{{name}}"
+			input["sync_mode"] = "manual"
+			input["labels"] = []any{"team=payments"}
+			created, err := createApplicationSetFn(t.Context(), &app.OperationRequest{
+				Metadata:    testMetadata(),
+				Environment: f.env(),
+				Input:       input,
+			})
+			if err != nil {
+				t.Fatalf("createApplicationSetFn: %v", err)
+			}
+
+			// Only the revision is updated, every other input is left out
+			if _, err := updateApplicationSetFn(t.Context(), &app.OperationRequest{
+				Metadata:    testMetadata(),
+				Environment: f.env(),
+				Resource:    created.Resource,
+				Input:       map[string]any{"target_revision": "v1.1.0"},
+			}); err != nil {
+				t.Fatalf("updateApplicationSetFn: %v", err)
+			}
+
+			read, err := readApplicationSetFn(t.Context(), &app.OperationRequest{
+				Metadata:    testMetadata(),
+				Environment: f.env(),
+				Resource:    created.Resource,
+			})
+			if err != nil {
+				t.Fatalf("readApplicationSetFn: %v", err)
+			}
+
+			props := read.Resource.Properties
+			for key, want := range map[string]any{
+				"generator_type":   tt.generator["generator_type"],
+				"target_revision":  "v1.1.0",
+				"source_type":      "kustomize",
+				"namespace":        "{{name}}",
+				"sync_mode":        "manual",
+				"argocd_project":   "default",
+				"application_name": created.Resource.Properties["application_name"],
+				"source_path":      created.Resource.Properties["source_path"],
+			} {
+				if got := props[key]; got != want {
+					t.Errorf("property %s = %v, want %v", key, got, want)
+				}
+			}
+			switch got := props[tt.property].(type) {
+			case []any:
+				if !slices.Contains(got, tt.want) {
+					t.Errorf("property %s = %v, want it to contain %v", tt.property, got, tt.want)
+				}
+			default:
+				if got != tt.want {
+					t.Errorf("property %s = %v, want %v", tt.property, got, tt.want)
+				}
+			}
+			if got := props["labels"].([]any); !slices.Contains(got, any("team=payments")) {
+				t.Errorf("property labels = %v, want team=payments kept", got)
+			}
+		})
+	}
+}
+
+func TestUpdateApplicationSetFnGenerator(t *testing.T) {
+	f := newFakeArgoCD(t)
+
+	created, err := createApplicationSetFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Input: applicationSetInput(map[string]any{
+			"list_elements": "- cluster: staging\n  url: https://staging.invalid\n",
+		}),
+	})
+	if err != nil {
+		t.Fatalf("createApplicationSetFn: %v", err)
+	}
+
+	// The new generator's name and server replace those the list generator defaulted
+	res, err := updateApplicationSetFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Resource:    created.Resource,
+		Input:       map[string]any{"generator_type": "clusters"},
+	})
+	if err != nil {
+		t.Fatalf("updateApplicationSetFn: %v", err)
+	}
+	for key, want := range map[string]any{
+		"generator_type":     "clusters",
+		"application_name":   "{{name}}-guestbook",
+		"destination_server": "{{server}}",
+		"repo_url":           "https://github.com/tempestdx/example-repository.git",
+	} {
+		if got := res.Resource.Properties[key]; got != want {
+			t.Errorf("property %s = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestUpdateApplicationSetFnOtherProject(t *testing.T) {
+	f := newFakeArgoCD(t)
+
+	other := testMetadata()
+	other.ProjectID = "proj-2"
+	created, err := createApplicationSetFn(t.Context(), &app.OperationRequest{
+		Metadata:    other,
+		Environment: f.env(),
+		Input: applicationSetInput(map[string]any{
+			"list_elements": "- cluster: staging\n  url: https://staging.invalid\n",
+		}),
+	})
+	if err != nil {
+		t.Fatalf("createApplicationSetFn: %v", err)
+	}
+
+	_, err = updateApplicationSetFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Resource:    created.Resource,
+		Input:       map[string]any{"target_revision": "v1.1.0"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "another Tempest project") {
+		t.Fatalf("updateApplicationSetFn error = %v, want the ApplicationSet of another project refused", err)
+	}
+}
+
+// applicationSetInput returns create input with the defaults of applicationset_create.json
+// applied, like Tempest does before calling an operation, overridden by overrides.
+func applicationSetInput(overrides map[string]any) map[string]any {
+	input := applicationInput(nil)
+	input["generator_type"] = "list"
+	input["git_revision"] = "HEAD"
+	for k, v := range overrides {
+		input[k] = v
+	}
+	return input
+}
@@ -0,0 +1,608 @@
+package appargocd
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/tempestdx/sdk-go/app"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var (
+	// Embed the JSON schemas of the "appproject" resource, like those of "application"
+	//go:embed schema/appproject_properties.json
+	appProjectPropertiesSchema []byte
+
+	//go:embed schema/appproject_create.json
+	appProjectCreateSchema []byte
+
+	//go:embed schema/appproject_update.json
+	appProjectUpdateSchema []byte
+
+	// appProjectGVR identifies ArgoCD AppProjects for the dynamic client.
+	appProjectGVR = schema.GroupVersionResource{
+		Group:    "argoproj.io",
+		Version:  "v1alpha1",
+		Resource: "appprojects",
+	}
+)
+
+// newAppProjectDefinition returns the resource type teams are onboarded with.
+// Applications join an AppProject through their argocd_project input.
+func newAppProjectDefinition() app.ResourceDefinition {
+	return app.ResourceDefinition{
+		Type:             "appproject",
+		DisplayName:      "AppProject",
+		Description:      "Manages an ArgoCD AppProject, which restricts where its Applications deploy from and to.",
+		LifecycleStage:   app.LifecycleStageDeploy,
+		PropertiesSchema: app.MustParseJSONSchema(appProjectPropertiesSchema),
+	}
+}
+
+// roleNameRegexp matches the role names ArgoCD accepts.
+var roleNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([-_a-zA-Z0-9]*[a-zA-Z0-9])?$`)
+
+// AppProjectTemplateInput defines the data passed to appproject.yaml.tmpl.
+type AppProjectTemplateInput struct {
+	Name                       string               // Name of the ArgoCD AppProject
+	Description                string               // Shown in the ArgoCD UI
+	Labels                     map[string]string    // Labels of the AppProject, including the tempest.dev/project label
+	Annotations                map[string]string    // Annotations of the AppProject
+	SourceRepos                []string             // Repository URL patterns Applications may deploy from
+	Destinations               []ProjectDestination // Clusters and namespaces Applications may deploy to
+	ClusterResourceWhitelist   []GroupKind          // Cluster-scoped kinds Applications may deploy
+	NamespaceResourceBlacklist []GroupKind          // Namespaced kinds Applications may not deploy
+	Roles                      []ProjectRole        // Roles granting access to the project's Applications
+}
+
+// ProjectDestination is a cluster and namespace pattern Applications may deploy to.
+// The cluster is referenced either by its API server URL or by its name in ArgoCD.
+type ProjectDestination struct {
+	Server    string
+	Name      string
+	Namespace string
+}
+
+// GroupKind identifies a Kubernetes kind. The core API group is empty.
+type GroupKind struct {
+	Group string
+	Kind  string
+}
+
+// ProjectRole is a role of an AppProject, in the form of spec.roles.
+type ProjectRole struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Policies    []string `json:"policies,omitempty"`
+	Groups      []string `json:"groups,omitempty"`
+
+	// JWTTokens lists the tokens ArgoCD issued for the role. They are not part of
+	// the input, and are carried over from the live AppProject on update.
+	JWTTokens []any `json:"jwtTokens,omitempty"`
+}
+
+// appProjectFromInput builds the template input of an AppProject from create or update input.
+func appProjectFromInput(name, projectID string, input map[string]any) (AppProjectTemplateInput, error) {
+	in := AppProjectTemplateInput{
+		Name:        name,
+		Description: stringInput(input, "description"),
+		SourceRepos: stringSliceInput(input, "source_repos"),
+	}
+
+	for _, d := range stringSliceInput(input, "destinations") {
+		dest, err := parseProjectDestination(d)
+		if err != nil {
+			return in, err
+		}
+		in.Destinations = append(in.Destinations, dest)
+	}
+
+	var err error
+	if in.ClusterResourceWhitelist, err = groupKindsInput(input, "cluster_resource_whitelist"); err != nil {
+		return in, err
+	}
+	if in.NamespaceResourceBlacklist, err = groupKindsInput(input, "namespace_resource_blacklist"); err != nil {
+		return in, err
+	}
+	if in.Roles, err = projectRolesFromInput(name, stringInput(input, "roles")); err != nil {
+		return in, err
+	}
+	if in.Labels, err = labelsFromInput(input, projectID); err != nil {
+		return in, err
+	}
+	if in.Annotations, err = annotationsFromInput(input); err != nil {
+		return in, err
+	}
+
+	return in, nil
+}
+
+// parseProjectDestination parses a destination in server,namespace form, the form of
+// "argocd proj add-destination". A server that is not a URL names a registered cluster.
+func parseProjectDestination(s string) (ProjectDestination, error) {
+	cluster, namespace, ok := strings.Cut(s, ",")
+	cluster, namespace = strings.TrimSpace(cluster), strings.TrimSpace(namespace)
+	if !ok || cluster == "" || namespace == "" {
+		return ProjectDestination{}, fmt.Errorf("invalid destination %q: expected server,namespace", s)
+	}
+
+	if cluster == "*" || strings.Contains(cluster, "://") {
+		return ProjectDestination{Server: cluster, Namespace: namespace}, nil
+	}
+	return ProjectDestination{Name: cluster, Namespace: namespace}, nil
+}
+
+// groupKindsInput parses a string array input of group/kind entries. An entry
+// without a group, such as Namespace, refers to the core API group.
+func groupKindsInput(input map[string]any, key string) ([]GroupKind, error) {
+	var out []GroupKind
+	for _, s := range stringSliceInput(input, key) {
+		gk := GroupKind{Kind: s}
+		if group, kind, ok := strings.Cut(s, "/"); ok {
+			gk = GroupKind{Group: group, Kind: kind}
+		}
+		if gk.Kind == "" || strings.Contains(gk.Kind, "/") {
+			return nil, fmt.Errorf("invalid %s entry %q: expected group/kind", key, s)
+		}
+		out = append(out, gk)
+	}
+	return out, nil
+}
+
+// projectRolesFromInput parses the "roles" input, a YAML list of roles. The Kubernetes
+// API doesn't validate roles, so the checks the ArgoCD API server makes are made here.
+func projectRolesFromInput(project, data string) ([]ProjectRole, error) {
+	if strings.TrimSpace(data) == "" {
+		return nil, nil
+	}
+
+	jsonBytes, err := yamlToJSON([]byte(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid roles: %w", err)
+	}
+
+	var roles []ProjectRole
+	dec := json.NewDecoder(bytes.NewReader(jsonBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&roles); err != nil {
+		return nil, fmt.Errorf("invalid roles: %w", err)
+	}
+
+	seen := map[string]bool{}
+	for i := range roles {
+		role := &roles[i]
+		if !roleNameRegexp.MatchString(role.Name) {
+			return nil, fmt.Errorf("invalid role name %q", role.Name)
+		}
+		if seen[role.Name] {
+			return nil, fmt.Errorf("duplicate role %q", role.Name)
+		}
+		seen[role.Name] = true
+
+		if len(role.JWTTokens) > 0 {
+			return nil, fmt.Errorf("role %s: jwtTokens are issued by ArgoCD and can't be set", role.Name)
+		}
+
+		for _, policy := range role.Policies {
+			if err := validateRolePolicy(project, role.Name, policy); err != nil {
+				return nil, fmt.Errorf("role %s: %w", role.Name, err)
+			}
+		}
+	}
+	return roles, nil
+}
+
+// validateRolePolicy checks a policy of a project role, which must be of the form
+// "p, proj:<project>:<role>, <resource>, <action>, <project>/<object>, allow|deny".
+func validateRolePolicy(project, role, policy string) error {
+	fields := strings.Split(policy, ",")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+
+	if len(fields) != 6 || fields[0] != "p" {
+		return fmt.Errorf("invalid policy %q: expected p, subject, resource, action, object, effect", policy)
+	}
+	if subject := "proj:" + project + ":" + role; fields[1] != subject {
+		return fmt.Errorf("invalid policy %q: the subject must be %s", policy, subject)
+	}
+	if !strings.HasPrefix(fields[4], project+"/") {
+		return fmt.Errorf("invalid policy %q: the object must be in the project, e.g. %s/*", policy, project)
+	}
+	if fields[5] != "allow" && fields[5] != "deny" {
+		return fmt.Errorf("invalid policy %q: the effect must be allow or deny", policy)
+	}
+	return nil
+}
+
+// keepRoleTokens carries the tokens ArgoCD issued for roles of the live AppProject
+// over to the roles of the same name, so an update doesn't revoke them.
+func keepRoleTokens(roles []ProjectRole, live *unstructured.Unstructured) {
+	liveRoles, _, _ := unstructured.NestedSlice(live.Object, "spec", "roles")
+	tokens := map[string][]any{}
+	for _, r := range liveRoles {
+		m, _ := r.(map[string]any)
+		name, _, _ := unstructured.NestedString(m, "name")
+		if t, ok := m["jwtTokens"].([]any); ok {
+			tokens[name] = t
+		}
+	}
+
+	for i := range roles {
+		roles[i].JWTTokens = tokens[roles[i].Name]
+	}
+}
+
+// createAppProjectFn implements the CREATE operation for the appproject resource type.
+// AppProjects have no status to wait for, so the AppProject is ready once applied.
+func createAppProjectFn(ctx context.Context, req *app.OperationRequest) (*app.OperationResponse, error) {
+	config, err := getConfigFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateCluster(ctx, config); err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := newDynamicClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	applyOpts, err := getApplyOptionsFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	projectID, err := projectIDFromMetadata(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	name := req.Input["name"].(string)
+	in, err := appProjectFromInput(name, projectID, req.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := renderTemplate(req.Environment, "appproject.yaml.tmpl", in)
+	if err != nil {
+		return nil, err
+	}
+
+	uid, err := apply(ctx, dynamicClient, manifest, applyOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := dynamicClient.Resource(appProjectGVR).Namespace("argocd").Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get appproject %s: %w", name, err)
+	}
+
+	resource, err := appProjectResource(ctx, dynamicClient, obj, strings.Join([]string{"argocd", name, uid}, "/"), config.Host, req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	return &app.OperationResponse{Resource: resource}, nil
+}
+
+// updateAppProjectFn implements the UPDATE operation for the appproject resource type.
+// Applications that no longer fit the project's restrictions are reported by ArgoCD
+// as failing to sync; they are not changed.
+func updateAppProjectFn(ctx context.Context, req *app.OperationRequest) (*app.OperationResponse, error) {
+	config, err := getConfigFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateCluster(ctx, config); err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := newDynamicClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	applyOpts, err := getApplyOptionsFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	_, name, err := parseExternalID(req.Resource.ExternalID)
+	if err != nil {
+		return nil, err
+	}
+
+	projectID, err := projectIDFromMetadata(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	in, err := appProjectFromInput(name, projectID, req.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	live, err := dynamicClient.Resource(appProjectGVR).Namespace("argocd").Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get appproject %s: %w", name, err)
+	}
+	if err := checkProjectOwner("appproject", live, projectID); err != nil {
+		return nil, err
+	}
+	keepRoleTokens(in.Roles, live)
+
+	manifest, err := renderTemplate(req.Environment, "appproject.yaml.tmpl", in)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := apply(ctx, dynamicClient, manifest, applyOpts); err != nil {
+		return nil, err
+	}
+
+	obj, err := dynamicClient.Resource(appProjectGVR).Namespace("argocd").Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get appproject %s: %w", name, err)
+	}
+
+	resource, err := appProjectResource(ctx, dynamicClient, obj, req.Resource.ExternalID, config.Host, req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	return &app.OperationResponse{Resource: resource}, nil
+}
+
+// readAppProjectFn implements the READ operation for the appproject resource type.
+func readAppProjectFn(ctx context.Context, req *app.OperationRequest) (*app.OperationResponse, error) {
+	config, err := getConfigFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := newDynamicClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	_, name, err := parseExternalID(req.Resource.ExternalID)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := dynamicClient.Resource(appProjectGVR).Namespace("argocd").Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	if project, ok := obj.GetLabels()[projectLabel]; ok && req.Metadata != nil && req.Metadata.ProjectID != "" && project != req.Metadata.ProjectID {
+		return nil, fmt.Errorf("appproject %s is managed by another Tempest project (%s)", name, project)
+	}
+
+	resource, err := appProjectResource(ctx, dynamicClient, obj, req.Resource.ExternalID, config.Host, req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	return &app.OperationResponse{Resource: resource}, nil
+}
+
+// deleteAppProjectFn implements the DELETE operation for the appproject resource type.
+// Like the ArgoCD API, it refuses to delete a project that Applications still belong to.
+func deleteAppProjectFn(ctx context.Context, req *app.OperationRequest) (*app.OperationResponse, error) {
+	config, err := getConfigFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := newDynamicClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	_, name, err := parseExternalID(req.Resource.ExternalID)
+	if err != nil {
+		return nil, err
+	}
+
+	apps, err := projectApplications(ctx, dynamicClient, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(apps) > 0 {
+		return nil, fmt.Errorf("appproject %s is still used by applications: %s", name, strings.Join(apps, ", "))
+	}
+
+	err = dynamicClient.Resource(appProjectGVR).Namespace("argocd").Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to delete appproject %s: %w", name, err)
+	}
+
+	return &app.OperationResponse{
+		Resource: &app.Resource{
+			ExternalID: req.Resource.ExternalID,
+		},
+	}, nil
+}
+
+// listAppProjectsFn implements the LIST operation for the appproject resource type.
+// Like listFn, it finds the AppProjects labeled with the requesting Tempest project.
+func listAppProjectsFn(ctx context.Context, req *app.ListRequest) (*app.ListResponse, error) {
+	env := processEnvironment()
+	config, err := getConfigFromEnv(env)
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := newDynamicClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	projectID, err := projectIDFromMetadata(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := dynamicClient.Resource(appProjectGVR).Namespace("argocd").List(ctx, metav1.ListOptions{
+		LabelSelector: projectLabel + "=" + projectID,
+		Limit:         listPageSize,
+		Continue:      req.Next,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list appprojects: %w", err)
+	}
+
+	resources := make([]*app.Resource, 0, len(list.Items))
+	for i := range list.Items {
+		obj := &list.Items[i]
+		externalID := strings.Join([]string{"argocd", obj.GetName(), string(obj.GetUID())}, "/")
+		resource, err := appProjectResource(ctx, dynamicClient, obj, externalID, config.Host, env)
+		if err != nil {
+			return nil, fmt.Errorf("appproject %s: %w", obj.GetName(), err)
+		}
+		resources = append(resources, resource)
+	}
+
+	return &app.ListResponse{
+		Resources: resources,
+		Next:      list.GetContinue(),
+	}, nil
+}
+
+// projectApplications returns the names of the Applications that belong to an AppProject.
+func projectApplications(ctx context.Context, dc dynamic.Interface, project string) ([]string, error) {
+	list, err := dc.Resource(applicationGVR).Namespace("argocd").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applications of appproject %s: %w", project, err)
+	}
+
+	var names []string
+	for _, obj := range list.Items {
+		if p, _, _ := unstructured.NestedString(obj.Object, "spec", "project"); p == project {
+			names = append(names, obj.GetName())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// appProjectResource builds the Tempest resource of a live AppProject.
+func appProjectResource(ctx context.Context, dc dynamic.Interface, obj *unstructured.Unstructured, externalID, cluster string, env map[string]app.EnvironmentVariable) (*app.Resource, error) {
+	properties, err := appProjectProperties(obj, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	apps, err := projectApplications(ctx, dc, obj.GetName())
+	if err != nil {
+		return nil, err
+	}
+	properties["applications"] = toAnySlice(apps)
+
+	links, err := argocdLinks(env, "settings", "projects", obj.GetName())
+	if err != nil {
+		return nil, err
+	}
+
+	return &app.Resource{
+		ExternalID:  externalID,
+		DisplayName: obj.GetName(),
+		Links:       links,
+		Properties:  properties,
+	}, nil
+}
+
+// appProjectProperties builds the properties of an AppProject, matching the
+// appproject_properties.json schema, except for its Applications.
+func appProjectProperties(obj *unstructured.Unstructured, cluster string) (map[string]any, error) {
+	spec, _, _ := unstructured.NestedMap(obj.Object, "spec")
+
+	description, _, _ := unstructured.NestedString(spec, "description")
+	sourceRepos, _, _ := unstructured.NestedStringSlice(spec, "sourceRepos")
+
+	// Destinations and kinds are reported in the form they are input in
+	destinations := []string{}
+	rawDestinations, _, _ := unstructured.NestedSlice(spec, "destinations")
+	for _, d := range rawDestinations {
+		m, _ := d.(map[string]any)
+		server, _, _ := unstructured.NestedString(m, "server")
+		if name, _, _ := unstructured.NestedString(m, "name"); name != "" {
+			server = name
+		}
+		namespace, _, _ := unstructured.NestedString(m, "namespace")
+		destinations = append(destinations, server+","+namespace)
+	}
+
+	// Roles are reported without the tokens issued for them
+	var roles []ProjectRole
+	if raw, found, _ := unstructured.NestedSlice(spec, "roles"); found {
+		b, err := json.Marshal(raw)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, &roles); err != nil {
+			return nil, fmt.Errorf("invalid roles of appproject %s: %w", obj.GetName(), err)
+		}
+	}
+	roleNames := []string{}
+	for i := range roles {
+		roles[i].JWTTokens = nil
+		roleNames = append(roleNames, roles[i].Name)
+	}
+	rolesYAML := ""
+	if len(roles) > 0 {
+		var err error
+		if rolesYAML, err = encodeYAML(roles); err != nil {
+			return nil, err
+		}
+	}
+
+	properties := map[string]any{
+		"name":                         obj.GetName(),
+		"description":                  description,
+		"source_repos":                 toAnySlice(sourceRepos),
+		"destinations":                 toAnySlice(destinations),
+		"cluster_resource_whitelist":   toAnySlice(groupKindStrings(spec, "clusterResourceWhitelist")),
+		"namespace_resource_blacklist": toAnySlice(groupKindStrings(spec, "namespaceResourceBlacklist")),
+		"roles":                        rolesYAML,
+		"role_names":                   toAnySlice(roleNames),
+		"cluster":                      cluster,
+	}
+	for k, v := range metadataProperties(obj) {
+		properties[k] = v
+	}
+	return properties, nil
+}
+
+// groupKindStrings formats a list of group and kind pairs as group/kind strings,
+// or kind for the core API group.
+func groupKindStrings(spec map[string]any, field string) []string {
+	out := []string{}
+	items, _, _ := unstructured.NestedSlice(spec, field)
+	for _, item := range items {
+		m, _ := item.(map[string]any)
+		group, _, _ := unstructured.NestedString(m, "group")
+		kind, _, _ := unstructured.NestedString(m, "kind")
+		if group != "" {
+			kind = group + "/" + kind
+		}
+		out = append(out, kind)
+	}
+	return out
+}
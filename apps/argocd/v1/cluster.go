@@ -0,0 +1,698 @@
+package appargocd
+
+import (
+	"context"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tempestdx/sdk-go/app"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+// Authentication modes accepted by the "auth_type" input of the cluster resource.
+const (
+	clusterAuthBearerToken = "bearer_token" // A bearer token, e.g. of a service account in the cluster
+	clusterAuthExec        = "exec"         // An exec plugin, e.g. argocd-k8s-auth for EKS, GKE or AKS
+)
+
+// clusterExecCommandsEnv lists the commands exec plugins may run, separated by commas.
+// ArgoCD runs the command of an exec cluster with the credentials of its controller,
+// so exec clusters are refused unless their command is listed.
+const clusterExecCommandsEnv = "ARGOCD_CLUSTER_EXEC_COMMANDS"
+
+// clusterSecretTypeLabel marks the Secrets ArgoCD reads its clusters from.
+const clusterSecretTypeLabel = "argocd.argoproj.io/secret-type"
+
+// inClusterName is the name ArgoCD gives the cluster it runs in, which has no Secret.
+const inClusterName = "in-cluster"
+
+// clusterConnectTimeout bounds the connectivity check made before a cluster is registered.
+const clusterConnectTimeout = 30 * time.Second
+
+var (
+	// Embed the JSON schemas of the "cluster" resource, like those of "application"
+	//go:embed schema/cluster_properties.json
+	clusterPropertiesSchema []byte
+
+	//go:embed schema/cluster_create.json
+	clusterCreateSchema []byte
+
+	//go:embed schema/cluster_update.json
+	clusterUpdateSchema []byte
+
+	// secretGVR identifies Kubernetes Secrets, which hold ArgoCD's repositories and clusters.
+	secretGVR = schema.GroupVersionResource{
+		Group:    "",        // Core Kubernetes API group (empty string)
+		Version:  "v1",      // Kubernetes API version
+		Resource: "secrets", // Resource type plural name
+	}
+)
+
+// newClusterDefinition returns the resource type that registers a destination cluster
+// with ArgoCD, so Applications can deploy to other clusters than the one ArgoCD runs in.
+func newClusterDefinition() app.ResourceDefinition {
+	return app.ResourceDefinition{
+		Type:             "cluster",
+		DisplayName:      "Cluster",
+		Description:      "Registers a Kubernetes cluster with ArgoCD, so Applications can deploy to it by name.",
+		LifecycleStage:   app.LifecycleStageDeploy,
+		PropertiesSchema: app.MustParseJSONSchema(clusterPropertiesSchema),
+	}
+}
+
+// clusterTemplateInput holds the values rendered into argocd_cluster.yaml.tmpl.
+type clusterTemplateInput struct {
+	SecretName       string            // Name of the Secret, see clusterSecretName
+	Name             string            // Name Applications reference the cluster by
+	Server           string            // Kubernetes API server URL
+	Config           string            // clusterConfig as JSON
+	Namespaces       []string          // Namespaces ArgoCD is limited to, all if empty
+	ClusterResources bool              // Whether cluster-scoped resources are managed when limited to namespaces
+	Project          string            // ArgoCD project the cluster is restricted to, if any
+	Labels           map[string]string // Labels of the Secret, including the tempest.dev/project label
+}
+
+// clusterConfig is the "config" field of an ArgoCD cluster Secret.
+type clusterConfig struct {
+	BearerToken        string             `json:"bearerToken,omitempty"`
+	TLSClientConfig    clusterTLSConfig   `json:"tlsClientConfig"`
+	ExecProviderConfig *clusterExecConfig `json:"execProviderConfig,omitempty"`
+}
+
+type clusterTLSConfig struct {
+	Insecure bool   `json:"insecure"`
+	CAData   []byte `json:"caData,omitempty"` // PEM, base64-encoded in JSON like ArgoCD expects
+}
+
+type clusterExecConfig struct {
+	Command    string            `json:"command"`
+	Args       []string          `json:"args,omitempty"`
+	Env        map[string]string `json:"env,omitempty"`
+	APIVersion string            `json:"apiVersion,omitempty"`
+}
+
+// clusterSecretName returns the name of the Secret of a cluster.
+func clusterSecretName(name string) string {
+	return "cluster-" + name
+}
+
+// clusterFromInput builds the Secret of a cluster from create input, or update input
+// completed by clusterUpdateInput. On update, live holds the config of the existing
+// Secret, whose bearer token is kept when the input leaves it out, since tokens are
+// never reported back.
+func clusterFromInput(name, projectID string, input map[string]any, live *clusterConfig) (clusterTemplateInput, clusterConfig, error) {
+	in := clusterTemplateInput{
+		SecretName:       clusterSecretName(name),
+		Name:             name,
+		Server:           strings.TrimSuffix(stringInput(input, "server"), "/"),
+		Namespaces:       stringSliceInput(input, "namespaces"),
+		ClusterResources: boolInput(input, "cluster_resources"),
+		Project:          stringInput(input, "argocd_project"),
+	}
+	var config clusterConfig
+
+	if name == inClusterName {
+		return in, config, fmt.Errorf("cluster name %s is reserved for the cluster ArgoCD runs in", inClusterName)
+	}
+	if errs := validation.IsDNS1123Subdomain(in.SecretName); len(errs) > 0 {
+		return in, config, fmt.Errorf("invalid cluster name %q: %s", name, strings.Join(errs, "; "))
+	}
+
+	u, err := url.Parse(in.Server)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return in, config, fmt.Errorf("invalid server %q: expected the URL of a Kubernetes API server", in.Server)
+	}
+
+	config.TLSClientConfig.Insecure = boolInput(input, "insecure")
+	if config.TLSClientConfig.CAData, err = decodeCAData(stringInput(input, "ca_data")); err != nil {
+		return in, config, fmt.Errorf("invalid ca_data: %w", err)
+	}
+
+	authType := stringInput(input, "auth_type")
+	if authType == "" {
+		authType = clusterAuthBearerToken
+	}
+
+	switch authType {
+	case clusterAuthBearerToken:
+		config.BearerToken = stringInput(input, "bearer_token")
+		if config.BearerToken == "" && live != nil {
+			config.BearerToken = live.BearerToken
+		}
+		if config.BearerToken == "" {
+			return in, config, errors.New("bearer_token is required for bearer_token clusters")
+		}
+	case clusterAuthExec:
+		exec := &clusterExecConfig{
+			Command:    stringInput(input, "exec_command"),
+			Args:       stringSliceInput(input, "exec_args"),
+			APIVersion: stringInput(input, "exec_api_version"),
+		}
+		if exec.Command == "" {
+			return in, config, errors.New("exec_command is required for exec clusters")
+		}
+		if exec.APIVersion == "" {
+			exec.APIVersion = "client.authentication.k8s.io/v1beta1"
+		}
+		if exec.Env, err = keyValueInput(input, "exec_env"); err != nil {
+			return in, config, err
+		}
+		if len(exec.Env) == 0 {
+			exec.Env = nil
+		}
+		config.ExecProviderConfig = exec
+	default:
+		return in, config, fmt.Errorf("unsupported auth_type %q", authType)
+	}
+
+	b, err := json.Marshal(config)
+	if err != nil {
+		return in, config, err
+	}
+	in.Config = string(b)
+
+	if in.Labels, err = labelsFromInput(input, projectID); err != nil {
+		return in, config, err
+	}
+
+	return in, config, nil
+}
+
+// clusterUpdateInput returns the update input of a cluster, with the inputs left out
+// filled from its live Secret and config, so an update only changes the inputs it
+// sets. The exec plugin's arguments and environment are only kept along with its
+// command, since they are meaningless to another one.
+func clusterUpdateInput(input map[string]any, live *unstructured.Unstructured, config clusterConfig) map[string]any {
+	data := secretData(live)
+	current := map[string]any{
+		"server":            data["server"],
+		"auth_type":         clusterAuthBearerToken,
+		"insecure":          config.TLSClientConfig.Insecure,
+		"cluster_resources": data["clusterResources"] == "true",
+		"argocd_project":    data["project"],
+		"labels":            toAnySlice(keyValueStrings(userLabels(live))),
+	}
+	if len(config.TLSClientConfig.CAData) > 0 {
+		current["ca_data"] = string(config.TLSClientConfig.CAData)
+	}
+	if data["namespaces"] != "" {
+		current["namespaces"] = toAnySlice(strings.Split(data["namespaces"], ","))
+	}
+	if exec := config.ExecProviderConfig; exec != nil {
+		current["auth_type"] = clusterAuthExec
+		current["exec_api_version"] = exec.APIVersion
+		if _, ok := input["exec_command"]; !ok {
+			current["exec_command"] = exec.Command
+			current["exec_args"] = toAnySlice(exec.Args)
+			current["exec_env"] = toAnySlice(keyValueStrings(exec.Env))
+		}
+	}
+
+	merged := make(map[string]any, len(input)+len(current))
+	for k, v := range current {
+		merged[k] = v
+	}
+	for k, v := range input {
+		merged[k] = v
+	}
+	return merged
+}
+
+// clusterRESTConfig builds a client configuration that authenticates to a cluster
+// with a bearer token, the same way ArgoCD will. Exec plugins are left out: the
+// command comes from user input, and is only ever run by ArgoCD.
+func clusterRESTConfig(server string, config clusterConfig) *rest.Config {
+	return &rest.Config{
+		Host:        server,
+		BearerToken: config.BearerToken,
+		Timeout:     clusterConnectTimeout,
+		TLSClientConfig: rest.TLSClientConfig{
+			Insecure: config.TLSClientConfig.Insecure,
+			CAData:   config.TLSClientConfig.CAData,
+		},
+	}
+}
+
+// verifyClusterConnection checks that a cluster is reachable and accepts the
+// credentials, before its Secret is written. ArgoCD would otherwise only report
+// the problem once an Application tries to deploy to the cluster.
+func verifyClusterConnection(ctx context.Context, config *rest.Config) error {
+	dc, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to create discovery client for cluster %s: %w", config.Host, err)
+	}
+
+	err = dc.RESTClient().Get().AbsPath("/version").Do(ctx).Error()
+	switch {
+	case k8serrors.IsUnauthorized(err):
+		return fmt.Errorf("cluster %s rejected the credentials: %w", config.Host, err)
+	case err != nil:
+		return fmt.Errorf("failed to reach cluster %s: %w", config.Host, err)
+	}
+	return nil
+}
+
+// checkClusterConflicts makes sure no other cluster Secret uses the same name or
+// server. ArgoCD would silently use only one of them.
+func checkClusterConflicts(ctx context.Context, dc dynamic.Interface, in clusterTemplateInput) error {
+	secrets, err := clusterSecrets(ctx, dc, "")
+	if err != nil {
+		return err
+	}
+
+	for i := range secrets {
+		s := &secrets[i]
+		if s.GetName() == in.SecretName {
+			continue
+		}
+		data := secretData(s)
+		switch {
+		case data["name"] == in.Name:
+			return fmt.Errorf("cluster %s is already registered by secret %s", in.Name, s.GetName())
+		case strings.TrimSuffix(data["server"], "/") == in.Server:
+			return fmt.Errorf("server %s is already registered as cluster %s by secret %s", in.Server, data["name"], s.GetName())
+		}
+	}
+	return nil
+}
+
+// checkDestinationCluster makes sure an Application's destination cluster is
+// registered with ArgoCD, so a typo fails the operation instead of the sync.
+func checkDestinationCluster(ctx context.Context, dc dynamic.Interface, name string) error {
+	if name == "" || name == inClusterName {
+		return nil
+	}
+
+	secrets, err := clusterSecrets(ctx, dc, "")
+	if err != nil {
+		return err
+	}
+	for i := range secrets {
+		if secretData(&secrets[i])["name"] == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("destination cluster %q is not registered with ArgoCD", name)
+}
+
+// clusterSecrets lists the cluster Secrets in the argocd namespace, optionally
+// filtered by an additional label selector.
+func clusterSecrets(ctx context.Context, dc dynamic.Interface, selector string) ([]unstructured.Unstructured, error) {
+	labelSelector := clusterSecretTypeLabel + "=cluster"
+	if selector != "" {
+		labelSelector += "," + selector
+	}
+
+	list, err := dc.Resource(secretGVR).Namespace("argocd").List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cluster secrets: %w", err)
+	}
+	return list.Items, nil
+}
+
+// secretData decodes the data of a Secret. Values that are not valid base64 are left out.
+func secretData(obj *unstructured.Unstructured) map[string]string {
+	out := map[string]string{}
+	data, _, _ := unstructured.NestedStringMap(obj.Object, "data")
+	for k, v := range data {
+		if b, err := base64.StdEncoding.DecodeString(v); err == nil {
+			out[k] = string(b)
+		}
+	}
+	return out
+}
+
+// getClusterSecret fetches the Secret of a cluster.
+func getClusterSecret(ctx context.Context, dc dynamic.Interface, name string) (*unstructured.Unstructured, error) {
+	obj, err := dc.Resource(secretGVR).Namespace("argocd").Get(ctx, clusterSecretName(name), metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster %s: %w", name, err)
+	}
+	return obj, nil
+}
+
+// checkExecCommand refuses an exec plugin whose command is not listed in
+// ARGOCD_CLUSTER_EXEC_COMMANDS. Commands must match exactly, so listing
+// argocd-k8s-auth doesn't permit /tmp/argocd-k8s-auth.
+func checkExecCommand(env map[string]app.EnvironmentVariable, command string) error {
+	permitted := envValue(env, clusterExecCommandsEnv)
+	if permitted == "" {
+		return fmt.Errorf("exec clusters are disabled: set %s to the commands exec plugins may run", clusterExecCommandsEnv)
+	}
+	for _, c := range strings.Split(permitted, ",") {
+		if strings.TrimSpace(c) == command {
+			return nil
+		}
+	}
+	return fmt.Errorf("exec_command %q is not permitted by %s", command, clusterExecCommandsEnv)
+}
+
+// applyCluster verifies the connection to a cluster and applies its Secret.
+// Clusters using an exec plugin are not verified, since that would run a
+// user-supplied command in this app, but their command must be permitted.
+func applyCluster(ctx context.Context, dc dynamic.Interface, env map[string]app.EnvironmentVariable, in clusterTemplateInput, config clusterConfig, opts applyOptions) (string, error) {
+	if exec := config.ExecProviderConfig; exec != nil {
+		if err := checkExecCommand(env, exec.Command); err != nil {
+			return "", err
+		}
+	} else if err := verifyClusterConnection(ctx, clusterRESTConfig(in.Server, config)); err != nil {
+		return "", err
+	}
+
+	if err := checkClusterConflicts(ctx, dc, in); err != nil {
+		return "", err
+	}
+
+	manifest, err := renderTemplate(env, "argocd_cluster.yaml.tmpl", in)
+	if err != nil {
+		return "", err
+	}
+
+	return apply(ctx, dc, manifest, opts)
+}
+
+// createClusterFn implements the CREATE operation for the cluster resource type.
+func createClusterFn(ctx context.Context, req *app.OperationRequest) (*app.OperationResponse, error) {
+	config, err := getConfigFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateCluster(ctx, config); err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := newDynamicClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	applyOpts, err := getApplyOptionsFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	projectID, err := projectIDFromMetadata(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	name := req.Input["name"].(string)
+	in, clusterCfg, err := clusterFromInput(name, projectID, req.Input, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	uid, err := applyCluster(ctx, dynamicClient, req.Environment, in, clusterCfg, applyOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := getClusterSecret(ctx, dynamicClient, name)
+	if err != nil {
+		return nil, err
+	}
+
+	resource, err := clusterResource(ctx, dynamicClient, obj, strings.Join([]string{"argocd", name, uid}, "/"), config.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	return &app.OperationResponse{Resource: resource}, nil
+}
+
+// updateClusterFn implements the UPDATE operation for the cluster resource type.
+// The name of a cluster can't change, since Applications reference it.
+func updateClusterFn(ctx context.Context, req *app.OperationRequest) (*app.OperationResponse, error) {
+	config, err := getConfigFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateCluster(ctx, config); err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := newDynamicClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	applyOpts, err := getApplyOptionsFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	_, name, err := parseExternalID(req.Resource.ExternalID)
+	if err != nil {
+		return nil, err
+	}
+
+	projectID, err := projectIDFromMetadata(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	live, err := getClusterSecret(ctx, dynamicClient, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkProjectOwner("cluster", live, projectID); err != nil {
+		return nil, err
+	}
+	var liveCfg clusterConfig
+	if err := json.Unmarshal([]byte(secretData(live)["config"]), &liveCfg); err != nil {
+		return nil, fmt.Errorf("invalid config of cluster %s: %w", name, err)
+	}
+
+	in, clusterCfg, err := clusterFromInput(name, projectID, clusterUpdateInput(req.Input, live, liveCfg), &liveCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := applyCluster(ctx, dynamicClient, req.Environment, in, clusterCfg, applyOpts); err != nil {
+		return nil, err
+	}
+
+	obj, err := getClusterSecret(ctx, dynamicClient, name)
+	if err != nil {
+		return nil, err
+	}
+
+	resource, err := clusterResource(ctx, dynamicClient, obj, req.Resource.ExternalID, config.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	return &app.OperationResponse{Resource: resource}, nil
+}
+
+// readClusterFn implements the READ operation for the cluster resource type.
+func readClusterFn(ctx context.Context, req *app.OperationRequest) (*app.OperationResponse, error) {
+	config, err := getConfigFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := newDynamicClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	_, name, err := parseExternalID(req.Resource.ExternalID)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := getClusterSecret(ctx, dynamicClient, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if project, ok := obj.GetLabels()[projectLabel]; ok && req.Metadata != nil && req.Metadata.ProjectID != "" && project != req.Metadata.ProjectID {
+		return nil, fmt.Errorf("cluster %s is managed by another Tempest project (%s)", name, project)
+	}
+
+	resource, err := clusterResource(ctx, dynamicClient, obj, req.Resource.ExternalID, config.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	return &app.OperationResponse{Resource: resource}, nil
+}
+
+// deleteClusterFn implements the DELETE operation for the cluster resource type.
+// It refuses to unregister a cluster that Applications still deploy to, since ArgoCD
+// would lose track of what they deployed.
+func deleteClusterFn(ctx context.Context, req *app.OperationRequest) (*app.OperationResponse, error) {
+	config, err := getConfigFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := newDynamicClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	_, name, err := parseExternalID(req.Resource.ExternalID)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := dynamicClient.Resource(secretGVR).Namespace("argocd").Get(ctx, clusterSecretName(name), metav1.GetOptions{})
+	switch {
+	case k8serrors.IsNotFound(err):
+		return &app.OperationResponse{Resource: &app.Resource{ExternalID: req.Resource.ExternalID}}, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to get cluster %s: %w", name, err)
+	}
+
+	apps, err := clusterApplications(ctx, dynamicClient, name, secretData(obj)["server"])
+	if err != nil {
+		return nil, err
+	}
+	if len(apps) > 0 {
+		return nil, fmt.Errorf("cluster %s is still used by applications: %s", name, strings.Join(apps, ", "))
+	}
+
+	err = dynamicClient.Resource(secretGVR).Namespace("argocd").Delete(ctx, obj.GetName(), metav1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to delete cluster %s: %w", name, err)
+	}
+
+	return &app.OperationResponse{
+		Resource: &app.Resource{
+			ExternalID: req.Resource.ExternalID,
+		},
+	}, nil
+}
+
+// listClustersFn implements the LIST operation for the cluster resource type.
+// Like listFn, it finds the cluster Secrets labeled with the requesting Tempest project.
+func listClustersFn(ctx context.Context, req *app.ListRequest) (*app.ListResponse, error) {
+	config, err := getConfigFromEnv(processEnvironment())
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := newDynamicClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	projectID, err := projectIDFromMetadata(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := dynamicClient.Resource(secretGVR).Namespace("argocd").List(ctx, metav1.ListOptions{
+		LabelSelector: clusterSecretTypeLabel + "=cluster," + projectLabel + "=" + projectID,
+		Limit:         listPageSize,
+		Continue:      req.Next,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clusters: %w", err)
+	}
+
+	resources := make([]*app.Resource, 0, len(list.Items))
+	for i := range list.Items {
+		obj := &list.Items[i]
+		name := secretData(obj)["name"]
+		externalID := strings.Join([]string{"argocd", name, string(obj.GetUID())}, "/")
+		resource, err := clusterResource(ctx, dynamicClient, obj, externalID, config.Host)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", name, err)
+		}
+		resources = append(resources, resource)
+	}
+
+	return &app.ListResponse{
+		Resources: resources,
+		Next:      list.GetContinue(),
+	}, nil
+}
+
+// clusterApplications returns the names of the Applications that deploy to a cluster,
+// by name or by server URL.
+func clusterApplications(ctx context.Context, dc dynamic.Interface, name, server string) ([]string, error) {
+	list, err := dc.Resource(applicationGVR).Namespace("argocd").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applications of cluster %s: %w", name, err)
+	}
+
+	server = strings.TrimSuffix(server, "/")
+	var names []string
+	for _, obj := range list.Items {
+		destName, _, _ := unstructured.NestedString(obj.Object, "spec", "destination", "name")
+		destServer, _, _ := unstructured.NestedString(obj.Object, "spec", "destination", "server")
+		if destName == name || (server != "" && strings.TrimSuffix(destServer, "/") == server) {
+			names = append(names, obj.GetName())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// clusterResource builds the Tempest resource of a cluster Secret.
+// The credentials are never part of its properties.
+func clusterResource(ctx context.Context, dc dynamic.Interface, obj *unstructured.Unstructured, externalID, host string) (*app.Resource, error) {
+	data := secretData(obj)
+
+	var config clusterConfig
+	if err := json.Unmarshal([]byte(data["config"]), &config); err != nil {
+		return nil, fmt.Errorf("invalid config of cluster %s: %w", data["name"], err)
+	}
+
+	authType, execCommand := clusterAuthBearerToken, ""
+	if config.ExecProviderConfig != nil {
+		authType, execCommand = clusterAuthExec, config.ExecProviderConfig.Command
+	}
+
+	namespaces := []string{}
+	if data["namespaces"] != "" {
+		namespaces = strings.Split(data["namespaces"], ",")
+	}
+
+	apps, err := clusterApplications(ctx, dc, data["name"], data["server"])
+	if err != nil {
+		return nil, err
+	}
+
+	properties := map[string]any{
+		"name":              data["name"],
+		"server":            data["server"],
+		"auth_type":         authType,
+		"insecure":          config.TLSClientConfig.Insecure,
+		"exec_command":      execCommand,
+		"namespaces":        toAnySlice(namespaces),
+		"cluster_resources": data["clusterResources"] == "true",
+		"argocd_project":    data["project"],
+		"secret_name":       obj.GetName(),
+		"applications":      toAnySlice(apps),
+		"cluster":           host,
+	}
+	for k, v := range metadataProperties(obj) {
+		properties[k] = v
+	}
+
+	return &app.Resource{
+		ExternalID:  externalID,
+		DisplayName: data["name"],
+		Properties:  properties,
+	}, nil
+}
@@ -0,0 +1,204 @@
+package appargocd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tempestdx/sdk-go/app"
+)
+
+func TestVerifyClusterConnection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer valid" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"major": "1", "minor": "30"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	if err := verifyClusterConnection(t.Context(), clusterRESTConfig(server.URL, clusterConfig{BearerToken: "valid"})); err != nil {
+		t.Errorf("verifyClusterConnection: %v", err)
+	}
+	err := verifyClusterConnection(t.Context(), clusterRESTConfig(server.URL, clusterConfig{BearerToken: "expired"}))
+	if err == nil || !strings.Contains(err.Error(), "rejected the credentials") {
+		t.Errorf("verifyClusterConnection error = %v, want the credentials rejected", err)
+	}
+}
+
+func TestCreateClusterFnExec(t *testing.T) {
+	f := newFakeArgoCD(t)
+
+	// The exec plugin is only run by ArgoCD, never by the app
+	ran := filepath.Join(t.TempDir(), "ran")
+	res, err := createClusterFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.execEnv("touch"),
+		Input: map[string]any{
+			"name":         "production",
+			"server":       "https://production.invalid",
+			"auth_type":    "exec",
+			"exec_command": "touch",
+			"exec_args":    []any{ran},
+		},
+	})
+	if err != nil {
+		t.Fatalf("createClusterFn: %v", err)
+	}
+	if _, err := os.Stat(ran); !os.IsNotExist(err) {
+		t.Errorf("the exec plugin ran in the app")
+	}
+	if got := res.Resource.Properties["auth_type"]; got != "exec" {
+		t.Errorf("property auth_type = %v, want exec", got)
+	}
+}
+
+func TestCreateClusterFnExecRefused(t *testing.T) {
+	f := newFakeArgoCD(t)
+
+	tests := []struct {
+		name    string
+		env     map[string]app.EnvironmentVariable
+		command string
+		wantErr string
+	}{
+		{name: "exec disabled", env: f.env(), command: "argocd-k8s-auth", wantErr: "exec clusters are disabled"},
+		{name: "command not listed", env: f.execEnv("argocd-k8s-auth"), command: "sh", wantErr: "not permitted"},
+		{name: "other path", env: f.execEnv("argocd-k8s-auth"), command: "/tmp/argocd-k8s-auth", wantErr: "not permitted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := createClusterFn(t.Context(), &app.OperationRequest{
+				Metadata:    testMetadata(),
+				Environment: tt.env,
+				Input: map[string]any{
+					"name":         "production",
+					"server":       "https://production.invalid",
+					"auth_type":    "exec",
+					"exec_command": tt.command,
+				},
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("createClusterFn error = %v, want %q", err, tt.wantErr)
+			}
+			if f.exists(secretGVR, clusterSecretName("production")) {
+				t.Error("cluster Secret was applied")
+			}
+		})
+	}
+}
+
+func TestUpdateFnKeepsDestinationCluster(t *testing.T) {
+	f := newFakeArgoCD(t)
+	if _, err := createClusterFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.execEnv("argocd-k8s-auth"),
+		Input: map[string]any{
+			"name":         "production",
+			"server":       "https://production.invalid",
+			"auth_type":    "exec",
+			"exec_command": "argocd-k8s-auth",
+		},
+	}); err != nil {
+		t.Fatalf("createClusterFn: %v", err)
+	}
+	created := createTestApplication(t, f, map[string]any{"destination_cluster": "production"})
+
+	if _, err := updateFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Resource:    created,
+		Input:       map[string]any{"target_revision": "v1.1.0"},
+	}); err != nil {
+		t.Fatalf("updateFn: %v", err)
+	}
+
+	obj := f.get(t, applicationGVR, "guestbook")
+	if got := destinationCluster(obj); got != "production" {
+		t.Errorf("destination cluster = %q, want production", got)
+	}
+}
+
+func TestUpdateClusterFnKeepsOmittedInputs(t *testing.T) {
+	f := newFakeArgoCD(t)
+	const ca = "-----BEGIN CERTIFICATE-----\nMIIBdzCCAR2gAwIBAgIBADAKBggqhkjOPQQDAjAjMSEwHwYDVQQDDBhrM3Mtc2Vy\n-----END CERTIFICATE-----"
+	created, err := createClusterFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.execEnv("argocd-k8s-auth", "kubelogin"),
+		Input: map[string]any{
+			"name":              "production",
+			"server":            "https://production.invalid",
+			"auth_type":         "exec",
+			"exec_command":      "argocd-k8s-auth",
+			"exec_args":         []any{"aws", "--cluster-name", "production"},
+			"exec_env":          []any{"AWS_REGION=eu-west-1"},
+			"ca_data":           ca,
+			"namespaces":        []any{"guestbook", "monitoring"},
+			"cluster_resources": true,
+			"argocd_project":    "payments",
+			"labels":            []any{"team=payments"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("createClusterFn: %v", err)
+	}
+
+	// Only the labels are updated, every other input is left out
+	if _, err := updateClusterFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.execEnv("argocd-k8s-auth", "kubelogin"),
+		Resource:    created.Resource,
+		Input:       map[string]any{"labels": []any{"team=checkout"}},
+	}); err != nil {
+		t.Fatalf("updateClusterFn: %v", err)
+	}
+
+	obj := f.get(t, secretGVR, clusterSecretName("production"))
+	data := secretData(obj)
+	var config clusterConfig
+	if err := json.Unmarshal([]byte(data["config"]), &config); err != nil {
+		t.Fatalf("config: %v", err)
+	}
+	if exec := config.ExecProviderConfig; exec == nil || exec.Command != "argocd-k8s-auth" || len(exec.Args) != 3 || exec.Env["AWS_REGION"] != "eu-west-1" {
+		t.Errorf("exec config = %+v, want the live exec plugin", config.ExecProviderConfig)
+	}
+	if string(config.TLSClientConfig.CAData) != ca {
+		t.Errorf("CA data = %q, want the live CA", config.TLSClientConfig.CAData)
+	}
+	for key, want := range map[string]string{
+		"server":           "https://production.invalid",
+		"namespaces":       "guestbook,monitoring",
+		"clusterResources": "true",
+		"project":          "payments",
+	} {
+		if data[key] != want {
+			t.Errorf("data %s = %q, want %q", key, data[key], want)
+		}
+	}
+	if got := obj.GetLabels()["team"]; got != "checkout" {
+		t.Errorf("label team = %q, want checkout", got)
+	}
+
+	// A new command doesn't inherit the arguments and environment of the live one
+	if _, err := updateClusterFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.execEnv("argocd-k8s-auth", "kubelogin"),
+		Resource:    created.Resource,
+		Input:       map[string]any{"exec_command": "kubelogin"},
+	}); err != nil {
+		t.Fatalf("updateClusterFn: %v", err)
+	}
+	config = clusterConfig{}
+	if err := json.Unmarshal([]byte(secretData(f.get(t, secretGVR, clusterSecretName("production")))["config"]), &config); err != nil {
+		t.Fatalf("config: %v", err)
+	}
+	if exec := config.ExecProviderConfig; exec.Command != "kubelogin" || len(exec.Args) != 0 || len(exec.Env) != 0 {
+		t.Errorf("exec config = %+v, want kubelogin without arguments or environment", exec)
+	}
+}
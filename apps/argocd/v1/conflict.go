@@ -0,0 +1,170 @@
+package appargocd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/tempestdx/sdk-go/app"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+)
+
+// Conflict strategies accepted by the ARGOCD_CONFLICT_STRATEGY environment variable.
+// They decide what happens when server-side apply finds fields of the Application
+// that are owned by another field manager, such as kubectl or the ArgoCD UI.
+// See: https://kubernetes.io/docs/reference/using-api/server-side-apply/#conflicts
+const (
+	conflictStrategyFail  = "fail"  // Return a ConflictError listing the conflicting managers and fields
+	conflictStrategyForce = "force" // Take ownership of the conflicting fields and overwrite them
+	conflictStrategyMerge = "merge" // Keep the live value of the conflicting fields and retry
+)
+
+// maxMergeAttempts bounds how often the merge strategy retries, since another
+// manager may keep changing fields between attempts.
+const maxMergeAttempts = 3
+
+// conflictManagerPattern extracts the field manager from a conflict cause message,
+// e.g. `conflict with "kubectl-edit" using argoproj.io/v1alpha1`.
+var conflictManagerPattern = regexp.MustCompile(`conflict with "([^"]*)"`)
+
+// applyOptions controls how apply handles server-side apply.
+type applyOptions struct {
+	ConflictStrategy string // One of the conflictStrategy* constants, defaults to fail
+	DryRun           bool   // Let the API server compute the result without persisting it
+}
+
+// getApplyOptionsFromEnv reads the optional ARGOCD_CONFLICT_STRATEGY from environment variables.
+func getApplyOptionsFromEnv(env map[string]app.EnvironmentVariable) (applyOptions, error) {
+	opts := applyOptions{ConflictStrategy: envValue(env, "ARGOCD_CONFLICT_STRATEGY")}
+
+	switch opts.ConflictStrategy {
+	case "":
+		opts.ConflictStrategy = conflictStrategyFail
+	case conflictStrategyFail, conflictStrategyForce, conflictStrategyMerge:
+	default:
+		return applyOptions{}, fmt.Errorf("unsupported ARGOCD_CONFLICT_STRATEGY %q", opts.ConflictStrategy)
+	}
+
+	return opts, nil
+}
+
+// FieldConflict is a field that another field manager owns with a different value.
+type FieldConflict struct {
+	Manager string // Field manager owning the field, e.g. kubectl-edit or argocd-server
+	Field   string // Path of the field, e.g. .spec.source.targetRevision
+}
+
+// ConflictError is returned when applying an object conflicts with changes made
+// by another field manager, and the conflict strategy doesn't resolve it.
+type ConflictError struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Conflicts []FieldConflict
+	Err       error // The error returned by the API server
+}
+
+func (e *ConflictError) Error() string {
+	fields := make([]string, 0, len(e.Conflicts))
+	for _, c := range e.Conflicts {
+		fields = append(fields, fmt.Sprintf("%s (owned by %s)", c.Field, c.Manager))
+	}
+	return fmt.Sprintf("%s %s/%s has fields changed by another manager: %s; set ARGOCD_CONFLICT_STRATEGY to force or merge to resolve",
+		e.Kind, e.Namespace, e.Name, strings.Join(fields, ", "))
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+// fieldConflicts extracts the conflicting fields from a server-side apply conflict error.
+func fieldConflicts(err error) []FieldConflict {
+	var status k8serrors.APIStatus
+	if !errors.As(err, &status) || status.Status().Details == nil {
+		return nil
+	}
+
+	var conflicts []FieldConflict
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Type != metav1.CauseTypeFieldManagerConflict {
+			continue
+		}
+		c := FieldConflict{Field: cause.Field, Manager: "unknown"}
+		if m := conflictManagerPattern.FindStringSubmatch(cause.Message); m != nil {
+			c.Manager = m[1]
+		}
+		conflicts = append(conflicts, c)
+	}
+	return conflicts
+}
+
+// applyWithStrategy server-side applies obj, resolving conflicts with other
+// field managers according to opts.ConflictStrategy.
+func applyWithStrategy(ctx context.Context, client dynamic.ResourceInterface, obj *unstructured.Unstructured, opts applyOptions) (*unstructured.Unstructured, error) {
+	applyOpts := metav1.ApplyOptions{
+		FieldManager: "tempest", // Important: This identifies our app as the field manager
+		Force:        opts.ConflictStrategy == conflictStrategyForce,
+	}
+	if opts.DryRun {
+		applyOpts.DryRun = []string{metav1.DryRunAll}
+	}
+
+	obj = obj.DeepCopy()
+	for attempt := 1; ; attempt++ {
+		res, err := client.Apply(ctx, obj.GetName(), obj, applyOpts)
+		if err == nil || !k8serrors.IsConflict(err) {
+			return res, err
+		}
+
+		conflictErr := &ConflictError{
+			Kind:      obj.GetKind(),
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+			Conflicts: fieldConflicts(err),
+			Err:       err,
+		}
+		if opts.ConflictStrategy != conflictStrategyMerge || len(conflictErr.Conflicts) == 0 || attempt == maxMergeAttempts {
+			return nil, conflictErr
+		}
+
+		// Share ownership of the conflicting fields by applying their live value,
+		// so changes made by other managers are kept instead of overwritten
+		live, err := client.Get(ctx, obj.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get live %s %s/%s: %w", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+		}
+		for _, c := range conflictErr.Conflicts {
+			if err := mergeLiveField(obj, live, c.Field); err != nil {
+				return nil, conflictErr
+			}
+			slog.Warn("keeping field changed by another manager", "kind", obj.GetKind(), "namespace", obj.GetNamespace(), "name", obj.GetName(), "field", c.Field, "manager", c.Manager)
+		}
+	}
+}
+
+// mergeLiveField copies the live value of field into obj, or removes it from obj
+// if it isn't set in the live object. Only plain paths of map keys are supported;
+// fields within list items can't be merged this way.
+func mergeLiveField(obj, live *unstructured.Unstructured, field string) error {
+	if strings.ContainsAny(field, "[]") {
+		return fmt.Errorf("can't merge list item field %s", field)
+	}
+	path := strings.Split(strings.TrimPrefix(field, "."), ".")
+
+	value, found, err := unstructured.NestedFieldNoCopy(live.Object, path...)
+	if err != nil {
+		return err
+	}
+	if !found {
+		unstructured.RemoveNestedField(obj.Object, path...)
+		return nil
+	}
+	return unstructured.SetNestedField(obj.Object, runtime.DeepCopyJSONValue(value), path...)
+}
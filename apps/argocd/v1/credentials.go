@@ -0,0 +1,230 @@
+package appargocd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/tempestdx/sdk-go/app"
+)
+
+// Repository authentication modes accepted by the ARGOCD_REPO_AUTH environment variable.
+// See: https://argo-cd.readthedocs.io/en/stable/user-guide/private-repositories/
+const (
+	repoAuthNone      = "none"       // Public repositories, no credentials are stored
+	repoAuthGitHubApp = "github_app" // GitHub App ID, installation ID and private key
+	repoAuthSSH       = "ssh"        // SSH private key, e.g. a deploy key
+	repoAuthHTTPS     = "https"      // HTTPS username and token
+)
+
+// Values of the argocd.argoproj.io/secret-type label.
+const (
+	secretTypeRepository = "repository" // Credentials for a single repository
+	secretTypeRepoCreds  = "repo-creds" // Credential template for every repository under a URL prefix
+)
+
+// credentialsHashAnnotation records a hash of the Secret's content, so Secrets are
+// only re-applied when the credentials or repository settings actually change.
+const credentialsHashAnnotation = "tempest.dev/credentials-hash"
+
+// repoCredentials holds the Git credentials read from the Tempest environment.
+// Only the fields of the selected Mode are set.
+type repoCredentials struct {
+	Mode                 string
+	GitHubAppID          string
+	GitHubInstallationID string
+	GitHubAppPrivateKey  string
+	SSHPrivateKey        string
+	Username             string
+	Password             string
+
+	// CredsURL, when set, stores the credentials once in a repo-creds Secret that
+	// ArgoCD uses for every repository whose URL starts with CredsURL.
+	CredsURL string
+}
+
+// getRepoCredentialsFromEnv reads repository credentials from environment variables.
+// ARGOCD_REPO_AUTH selects the mode explicitly. When it is not set, the mode is
+// inferred from the variables that are present, and public repositories need none.
+func getRepoCredentialsFromEnv(env map[string]app.EnvironmentVariable) (repoCredentials, error) {
+	creds := repoCredentials{
+		Mode:     envValue(env, "ARGOCD_REPO_AUTH"),
+		CredsURL: envValue(env, "ARGOCD_REPO_CREDS_URL"),
+	}
+
+	if creds.Mode == "" {
+		switch {
+		case envValue(env, "GITHUB_APP_ID") != "":
+			creds.Mode = repoAuthGitHubApp
+		case envValue(env, "DEPLOY_KEY_FILE") != "":
+			creds.Mode = repoAuthSSH
+		case envValue(env, "GIT_TOKEN") != "":
+			creds.Mode = repoAuthHTTPS
+		default:
+			creds.Mode = repoAuthNone
+		}
+	}
+
+	var err error
+	switch creds.Mode {
+	case repoAuthNone:
+	case repoAuthGitHubApp:
+		if creds.GitHubAppID, err = requireEnv(env, "GITHUB_APP_ID"); err != nil {
+			return repoCredentials{}, err
+		}
+		if creds.GitHubInstallationID, err = requireEnv(env, "GITHUB_INSTALLATION_ID"); err != nil {
+			return repoCredentials{}, err
+		}
+		// DEPLOY_KEY_FILE used to hold the GitHub App private key, keep accepting it
+		keyFile := "GITHUB_APP_PRIVATE_KEY_FILE"
+		if envValue(env, keyFile) == "" {
+			keyFile = "DEPLOY_KEY_FILE"
+		}
+		if creds.GitHubAppPrivateKey, err = getFileFromEnv(env, keyFile); err != nil {
+			return repoCredentials{}, err
+		}
+	case repoAuthSSH:
+		if creds.SSHPrivateKey, err = getFileFromEnv(env, "DEPLOY_KEY_FILE"); err != nil {
+			return repoCredentials{}, err
+		}
+	case repoAuthHTTPS:
+		if creds.Password, err = requireEnv(env, "GIT_TOKEN"); err != nil {
+			return repoCredentials{}, err
+		}
+		// Git hosts such as GitHub accept any non-empty username along with a token
+		creds.Username = envValue(env, "GIT_USERNAME")
+		if creds.Username == "" {
+			creds.Username = "x-access-token"
+		}
+	default:
+		return repoCredentials{}, fmt.Errorf("unsupported ARGOCD_REPO_AUTH %q", creds.Mode)
+	}
+
+	return creds, nil
+}
+
+// envValue returns the value of an optional environment variable.
+func envValue(env map[string]app.EnvironmentVariable, key string) string {
+	return env[key].Value
+}
+
+// requireEnv returns the value of a required environment variable.
+func requireEnv(env map[string]app.EnvironmentVariable, key string) (string, error) {
+	v := envValue(env, key)
+	if v == "" {
+		return "", fmt.Errorf("%s not found in environment", key)
+	}
+	return v, nil
+}
+
+// getFileFromEnv reads a file, such as a private key, whose path is given by an environment variable.
+func getFileFromEnv(env map[string]app.EnvironmentVariable, key string) (string, error) {
+	path, err := requireEnv(env, key)
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", key, err)
+	}
+
+	return strings.TrimSpace(string(content)), nil
+}
+
+// repositorySecrets builds the ArgoCD Secrets needed to access repoURLs.
+// Every repository gets its own Secret so it shows up in ArgoCD. With a credential
+// template, the credentials are stored once in a repo-creds Secret, and the
+// repository Secrets under its URL prefix carry no credentials of their own.
+func repositorySecrets(repoURLs []string, creds repoCredentials) []secretTemplateInput {
+	var secrets []secretTemplateInput
+
+	if creds.CredsURL != "" && creds.Mode != repoAuthNone {
+		s := secretTemplateInput{
+			SecretName: secretNameFor("creds", creds.CredsURL),
+			SecretType: secretTypeRepoCreds,
+			RepoURL:    creds.CredsURL,
+			Type:       "git",
+		}
+		s.setCredentials(creds)
+		secrets = append(secrets, s)
+	}
+
+	for _, repoURL := range repoURLs {
+		s := secretTemplateInput{
+			SecretName: secretNameFor("repo", repoURL),
+			SecretType: secretTypeRepository,
+			RepoURL:    repoURL,
+			Type:       "git",
+		}
+		// ArgoCD only falls back to a credential template for repositories without credentials
+		if creds.CredsURL == "" || !strings.HasPrefix(repoURL, creds.CredsURL) {
+			s.setCredentials(creds)
+		}
+		secrets = append(secrets, s)
+	}
+
+	return secrets
+}
+
+// secretNameFor generates a deterministic Secret name based on a repository URL.
+// This ensures one Secret per repository, since ArgoCD only recognizes the first one it finds.
+func secretNameFor(prefix, url string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(url))
+	return fmt.Sprintf("%s-%v", prefix, h.Sum32())
+}
+
+// setCredentials stores the credentials of the selected mode.
+func (s *secretTemplateInput) setCredentials(creds repoCredentials) {
+	switch creds.Mode {
+	case repoAuthGitHubApp:
+		s.GitHubAppID = creds.GitHubAppID
+		s.GitHubInstallationID = creds.GitHubInstallationID
+		s.GitHubAppPrivateKey = creds.GitHubAppPrivateKey
+	case repoAuthSSH:
+		s.SSHPrivateKey = creds.SSHPrivateKey
+	case repoAuthHTTPS:
+		s.Username = creds.Username
+		s.Password = creds.Password
+	}
+}
+
+// contentHash returns a hash of everything rendered into the Secret.
+func (s secretTemplateInput) contentHash() string {
+	s.Hash = ""
+	b, _ := json.Marshal(s)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// applyRepositorySecrets renders and applies the Secrets ArgoCD needs to access the
+// Git repositories of an Application. Secrets that already exist are updated in place
+// when their content hash changes, so rotated credentials are picked up.
+func applyRepositorySecrets(ctx context.Context, apply applyFunc, tmpl *template.Template, sources []ApplicationSource, creds repoCredentials) error {
+	for _, secret := range repositorySecrets(gitRepoURLs(sources), creds) {
+		secret.Hash = secret.contentHash()
+
+		var manifest bytes.Buffer
+		if err := tmpl.Execute(&manifest, secret); err != nil {
+			return err
+		}
+
+		uid, err := apply(ctx, manifest.Bytes())
+		if err != nil {
+			return err
+		}
+
+		if uid == "" {
+			return fmt.Errorf("failed to apply secret manifest")
+		}
+	}
+
+	return nil
+}
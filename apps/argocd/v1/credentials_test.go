@@ -0,0 +1,30 @@
+package appargocd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRepositorySecretShared(t *testing.T) {
+	f := newFakeArgoCD(t, testAppProject("payments",
+		[]any{"https://github.com/tempestdx/*"},
+		[]any{map[string]any{"server": "https://kubernetes.default.svc", "namespace": "*"}},
+	))
+	createTestApplication(t, f, nil)
+
+	name := secretNameFor("repo", "https://github.com/tempestdx/example-repository.git")
+	first := f.getIn(t, secretGVR, "argocd", name)
+
+	// Another Application using the repository, in another ArgoCD project, leaves the Secret as it is
+	createTestApplication(t, f, map[string]any{"name": "guestbook-2", "argocd_project": "payments"})
+	second := f.getIn(t, secretGVR, "argocd", name)
+
+	if !reflect.DeepEqual(second.Object["data"], first.Object["data"]) || second.GetAnnotations()[credentialsHashAnnotation] != first.GetAnnotations()[credentialsHashAnnotation] {
+		t.Errorf("repository Secret changed for another Application: %v, was %v", second.Object["data"], first.Object["data"])
+	}
+	for _, key := range []string{"name", "project"} {
+		if _, ok := second.Object["data"].(map[string]any)[key]; ok {
+			t.Errorf("repository Secret has %s, which only describes one Application", key)
+		}
+	}
+}
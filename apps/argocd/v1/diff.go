@@ -0,0 +1,226 @@
+package appargocd
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"strings"
+
+	"github.com/tempestdx/sdk-go/app"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+var (
+	// The preview action reports whether an update would change the Application, and how
+	//go:embed schema/preview_output.json
+	previewOutputSchema []byte
+)
+
+// previewAction renders the Application from update input and server-side applies it
+// in dry-run mode, so the API server computes the result, defaults included, without
+// persisting it. The result is compared with the live Application.
+func previewAction(ctx context.Context, req *app.ActionRequest) (*app.ActionResponse, error) {
+	namespace, name, err := parseExternalID(req.Resource.ExternalID)
+	if err != nil {
+		return nil, err
+	}
+
+	applyOpts, err := getApplyOptionsFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+	applyOpts.DryRun = true
+
+	dynamicClient, _, err := actionClient(req)
+	if err != nil {
+		return nil, err
+	}
+
+	projectID, err := projectIDFromMetadata(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	client := dynamicClient.Resource(applicationGVR).Namespace("argocd")
+	live, err := client.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := updateInput(req.Input, live)
+	if err != nil {
+		return nil, err
+	}
+
+	in, err := updateTemplateInput(namespace, name, projectID, input)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the deployed-* annotations of the last update, which the update
+	// would only refresh, so an update without changes previews as unchanged
+	keepTempestAnnotations(in.Annotations, live)
+
+	manifest, err := renderTemplate(req.Environment, "application.yaml.tmpl", in)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := decodeManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	// Report the same validation errors an update would
+	if err := validateApplication(ctx, dynamicClient, obj); err != nil {
+		return nil, err
+	}
+
+	// Conflicts are reported just like a real update would report them
+	res, err := applyWithStrategy(ctx, client, obj, applyOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	diff, err := diffApplications(live, res)
+	if err != nil {
+		return nil, err
+	}
+
+	return &app.ActionResponse{
+		Output: map[string]any{
+			"changed": diff != "",
+			"diff":    diff,
+		},
+	}, nil
+}
+
+// diffApplications returns a unified diff of the parts of two Applications that
+// this app manages: labels, annotations and spec. Server-populated fields such as
+// status and managedFields are left out, since they always differ.
+func diffApplications(live, desired *unstructured.Unstructured) (string, error) {
+	from, err := encodeYAML(applicationView(live))
+	if err != nil {
+		return "", err
+	}
+	to, err := encodeYAML(applicationView(desired))
+	if err != nil {
+		return "", err
+	}
+	return lineDiff("live", "desired", from, to), nil
+}
+
+func applicationView(obj *unstructured.Unstructured) map[string]any {
+	view := map[string]any{}
+	metadata := map[string]any{}
+	if labels := obj.GetLabels(); len(labels) > 0 {
+		metadata["labels"] = labels
+	}
+	if annotations := obj.GetAnnotations(); len(annotations) > 0 {
+		metadata["annotations"] = annotations
+	}
+	if len(metadata) > 0 {
+		view["metadata"] = metadata
+	}
+	if spec, found, _ := unstructured.NestedFieldNoCopy(obj.Object, "spec"); found {
+		view["spec"] = spec
+	}
+	return view
+}
+
+// lineDiff returns a unified diff of two texts, or an empty string if they are equal.
+// It uses the longest common subsequence of lines, which is plenty for manifests.
+func lineDiff(fromName, toName, from, to string) string {
+	if from == to {
+		return ""
+	}
+	a := strings.Split(strings.TrimSuffix(from, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(to, "\n"), "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	// Walk the table into a list of edits
+	type edit struct {
+		op   byte // ' ', '-' or '+'
+		line string
+	}
+	var edits []edit
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{'-', a[i]})
+			i++
+		default:
+			edits = append(edits, edit{'+', b[j]})
+			j++
+		}
+	}
+
+	// Group edits into hunks with diffContext lines of context
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+	for start, end := 0, 0; start < len(edits); {
+		if edits[start].op == ' ' {
+			start++
+			continue
+		}
+
+		lo := max(start-diffContext, end)
+		hi := start
+		for k := start; k < len(edits) && k <= hi+2*diffContext; k++ {
+			if edits[k].op != ' ' {
+				hi = k
+			}
+		}
+		hi = min(hi+diffContext, len(edits)-1)
+
+		// Line numbers of the hunk in both texts
+		aStart, bStart := 1, 1
+		for _, e := range edits[:lo] {
+			if e.op != '+' {
+				aStart++
+			}
+			if e.op != '-' {
+				bStart++
+			}
+		}
+		var aLen, bLen int
+		for _, e := range edits[lo : hi+1] {
+			if e.op != '+' {
+				aLen++
+			}
+			if e.op != '-' {
+				bLen++
+			}
+		}
+
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen)
+		for _, e := range edits[lo : hi+1] {
+			fmt.Fprintf(&out, "%c%s\n", e.op, e.line)
+		}
+		start, end = hi+1, hi+1
+	}
+	return out.String()
+}
@@ -0,0 +1,580 @@
+package appargocd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tempestdx/examples/deps/kube"
+	"github.com/tempestdx/sdk-go/app"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	dfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/rest"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// testProjectID is the Tempest project operations are requested by.
+const testProjectID = "proj-1"
+
+// argoCDKinds are the kinds the fake cluster serves, by resource.
+var argoCDKinds = map[schema.GroupVersionResource]string{
+	applicationGVR:    "Application",
+	applicationSetGVR: "ApplicationSet",
+	appProjectGVR:     "AppProject",
+	secretGVR:         "Secret",
+	namespaceGVR:      "Namespace",
+	resourceQuotaGVR:  "ResourceQuota",
+	limitRangeGVR:     "LimitRange",
+	networkPolicyGVR:  "NetworkPolicy",
+	roleBindingGVR:    "RoleBinding",
+	crdGVR:            "CustomResourceDefinition",
+}
+
+// argoCDFixtures are the objects every fake cluster starts with: the Application
+// CRD, with the part of ArgoCD's schema the templates render, and the default
+// AppProject, which permits any source and destination like a fresh install.
+const argoCDFixtures = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: applications.argoproj.io
+spec:
+  group: argoproj.io
+  names:
+    kind: Application
+    plural: applications
+  scope: Namespaced
+  versions:
+  - name: v1alpha1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            required: [destination, project]
+            properties:
+              destination:
+                type: object
+                properties:
+                  name: {type: string}
+                  namespace: {type: string}
+                  server: {type: string}
+              project:
+                type: string
+              source: &source
+                type: object
+                required: [repoURL]
+                properties:
+                  repoURL: {type: string}
+                  path: {type: string}
+                  targetRevision: {type: string}
+                  chart: {type: string}
+                  ref: {type: string}
+                  kustomize:
+                    type: object
+                    properties:
+                      images:
+                        type: array
+                        items: {type: string}
+                  helm:
+                    type: object
+                    properties:
+                      releaseName: {type: string}
+                      values: {type: string}
+                      valueFiles:
+                        type: array
+                        items: {type: string}
+                      parameters:
+                        type: array
+                        items:
+                          type: object
+                          properties:
+                            name: {type: string}
+                            value: {type: string}
+                            forceString: {type: boolean}
+                  directory:
+                    type: object
+                    properties:
+                      recurse: {type: boolean}
+                      include: {type: string}
+                      exclude: {type: string}
+              sources:
+                type: array
+                items: *source
+              syncPolicy:
+                type: object
+                properties:
+                  automated:
+                    type: object
+                    properties:
+                      prune: {type: boolean}
+                      selfHeal: {type: boolean}
+                      allowEmpty: {type: boolean}
+                  syncOptions:
+                    type: array
+                    items: {type: string}
+                  retry:
+                    type: object
+                    properties:
+                      limit: {type: integer, format: int64}
+                      backoff:
+                        type: object
+                        properties:
+                          duration: {type: string}
+                          factor: {type: integer, format: int64}
+                          maxDuration: {type: string}
+          operation:
+            type: object
+            x-kubernetes-preserve-unknown-fields: true
+          status:
+            type: object
+            x-kubernetes-preserve-unknown-fields: true
+---
+apiVersion: argoproj.io/v1alpha1
+kind: AppProject
+metadata:
+  name: default
+  namespace: argocd
+spec:
+  sourceRepos: ["*"]
+  destinations:
+  - server: "*"
+    namespace: "*"
+  clusterResourceWhitelist:
+  - group: "*"
+    kind: "*"
+`
+
+// fakeArgoCD is an offline cluster running ArgoCD. Operations reach it through a
+// fake dynamic client, extended with server-side apply, and validateCluster
+// discovers the ArgoCD API from a local HTTP server. A simulated application
+// controller syncs every Application and reports its health.
+type fakeArgoCD struct {
+	client *dfake.FakeDynamicClient
+	server *httptest.Server
+
+	mu         sync.Mutex        // Serializes writes of the apply reactor and the controller
+	health     map[string]string // Health the controller reports per Application, Healthy if not set
+	reconciled map[string]int64  // Generation of each Application the controller last reconciled
+	deployed   map[string]string // Revision the controller last synced per Application
+	clock      time.Time         // Time of the last reconciliation, to report distinct times
+	uids       int
+}
+
+// newFakeArgoCD starts a fake cluster holding objs, and points operations at it
+// until the test ends.
+func newFakeArgoCD(t *testing.T, objs ...runtime.Object) *fakeArgoCD {
+	t.Helper()
+
+	// Register the ArgoCD kinds, since the fake client has no CRDs to learn them from
+	scheme := runtime.NewScheme()
+	listKinds := make(map[schema.GroupVersionResource]string, len(argoCDKinds))
+	for gvr, kind := range argoCDKinds {
+		scheme.AddKnownTypeWithName(gvr.GroupVersion().WithKind(kind), &unstructured.Unstructured{})
+		scheme.AddKnownTypeWithName(gvr.GroupVersion().WithKind(kind+"List"), &unstructured.UnstructuredList{})
+		listKinds[gvr] = kind + "List"
+	}
+
+	// Every cluster running ArgoCD has the Application CRD and the default AppProject
+	fixtures, err := kube.Decode([]byte(argoCDFixtures))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, obj := range fixtures {
+		objs = append(objs, obj)
+	}
+
+	f := &fakeArgoCD{
+		client:     dfake.NewSimpleDynamicClientWithCustomListKinds(scheme, listKinds, objs...),
+		health:     map[string]string{},
+		reconciled: map[string]int64{},
+		deployed:   map[string]string{},
+	}
+	f.client.PrependReactor("patch", "*", f.serverSideApply)
+	f.client.PrependWatchReactor("*", f.watchWithReplay)
+
+	// validateCluster looks for Applications in the ArgoCD API group
+	mux := http.NewServeMux()
+	mux.HandleFunc("/apis/"+applicationGVR.GroupVersion().String(), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(metav1.APIResourceList{
+			GroupVersion: applicationGVR.GroupVersion().String(),
+			APIResources: []metav1.APIResource{
+				{Name: applicationGVR.Resource, Kind: "Application", Namespaced: true},
+				{Name: applicationSetGVR.Resource, Kind: "ApplicationSet", Namespaced: true},
+				{Name: appProjectGVR.Resource, Kind: "AppProject", Namespaced: true},
+			},
+		})
+	})
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+
+	prev := newDynamicClient
+	newDynamicClient = func(*rest.Config) (dynamic.Interface, error) {
+		return f.client, nil
+	}
+	t.Cleanup(func() {
+		newDynamicClient = prev
+	})
+
+	f.runController(t)
+	return f
+}
+
+// env returns the environment of operations against the fake cluster.
+func (f *fakeArgoCD) env() map[string]app.EnvironmentVariable {
+	return testEnv(map[string]string{
+		"KUBE_SERVER":         f.server.URL,
+		"KUBE_TOKEN":          "token",
+		"ARGOCD_SYNC_TIMEOUT": "5s",
+	})
+}
+
+// execEnv returns the environment of env, with exec clusters permitted to run commands.
+func (f *fakeArgoCD) execEnv(commands ...string) map[string]app.EnvironmentVariable {
+	env := f.env()
+	env[clusterExecCommandsEnv] = app.EnvironmentVariable{Key: clusterExecCommandsEnv, Value: strings.Join(commands, ","), Type: app.ENVIRONMENT_VARIABLE_TYPE_VAR}
+	return env
+}
+
+// setHealth makes the controller report health for the Application name.
+func (f *fakeArgoCD) setHealth(name, health string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.health[name] = health
+}
+
+// get returns an object from the fake cluster, failing the test if it doesn't exist.
+func (f *fakeArgoCD) get(t *testing.T, gvr schema.GroupVersionResource, name string) *unstructured.Unstructured {
+	t.Helper()
+	obj, err := f.client.Tracker().Get(gvr, "argocd", name)
+	if err != nil {
+		t.Fatalf("failed to get %s %s: %v", gvr.Resource, name, err)
+	}
+	return obj.(*unstructured.Unstructured)
+}
+
+// exists reports whether an object exists in the fake cluster.
+func (f *fakeArgoCD) exists(gvr schema.GroupVersionResource, name string) bool {
+	_, err := f.client.Tracker().Get(gvr, "argocd", name)
+	return err == nil
+}
+
+// getIn returns an object of a namespace from the fake cluster, failing the test if
+// it doesn't exist. Cluster-scoped objects have an empty namespace.
+func (f *fakeArgoCD) getIn(t *testing.T, gvr schema.GroupVersionResource, namespace, name string) *unstructured.Unstructured {
+	t.Helper()
+	obj, err := f.client.Tracker().Get(gvr, namespace, name)
+	if err != nil {
+		t.Fatalf("failed to get %s %s/%s: %v", gvr.Resource, namespace, name, err)
+	}
+	return obj.(*unstructured.Unstructured)
+}
+
+// existsIn reports whether an object of a namespace exists in the fake cluster.
+func (f *fakeArgoCD) existsIn(gvr schema.GroupVersionResource, namespace, name string) bool {
+	_, err := f.client.Tracker().Get(gvr, namespace, name)
+	return err == nil
+}
+
+// serverSideApply implements server-side apply, which the fake client lacks, for a
+// single field manager: the applied object replaces the live object, keeping its
+// identity and status. Like the API server, changing the spec leaves the status
+// of an Application alone until the controller reconciles it, new Namespaces are
+// Active right away, and new ApplicationSets report their Applications up to date.
+func (f *fakeArgoCD) serverSideApply(action clienttesting.Action) (bool, runtime.Object, error) {
+	patch, ok := action.(clienttesting.PatchActionImpl)
+	if !ok || patch.GetPatchType() != types.ApplyPatchType {
+		return false, nil, nil
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(patch.GetPatch()); err != nil {
+		return true, nil, k8serrors.NewBadRequest(err.Error())
+	}
+	dryRun := len(patch.PatchOptions.DryRun) > 0
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tracker := f.client.Tracker()
+	gvr, namespace := patch.GetResource(), patch.GetNamespace()
+	live, err := tracker.Get(gvr, namespace, patch.GetName())
+	if k8serrors.IsNotFound(err) {
+		f.uids++
+		obj.SetUID(types.UID(fmt.Sprintf("uid-%d", f.uids)))
+		obj.SetGeneration(1)
+		obj.SetCreationTimestamp(metav1.Now())
+		if gvr == namespaceGVR {
+			_ = unstructured.SetNestedField(obj.Object, "Active", "status", "phase")
+		}
+		if gvr == applicationSetGVR {
+			_ = unstructured.SetNestedSlice(obj.Object, []any{map[string]any{
+				"type": "ResourcesUpToDate", "status": "True", "message": "All applications have been generated successfully",
+			}}, "status", "conditions")
+		}
+		if dryRun {
+			return true, obj, nil
+		}
+		return true, obj.DeepCopy(), tracker.Create(gvr, obj, namespace)
+	}
+	if err != nil {
+		return true, nil, err
+	}
+
+	current := live.(*unstructured.Unstructured)
+	obj.SetUID(current.GetUID())
+	obj.SetCreationTimestamp(current.GetCreationTimestamp())
+	obj.SetGeneration(current.GetGeneration())
+	if status, ok := current.Object["status"]; ok {
+		obj.Object["status"] = status
+	}
+	if !equality.Semantic.DeepEqual(obj.Object["spec"], current.Object["spec"]) {
+		obj.SetGeneration(current.GetGeneration() + 1)
+	}
+	if dryRun {
+		return true, obj, nil
+	}
+	return true, obj.DeepCopy(), tracker.Update(gvr, obj, namespace)
+}
+
+// watchWithReplay starts watches that don't miss changes made after the list an
+// informer syncs from, like the API server does by resuming from the list's
+// resourceVersion. The fake tracker has no resource versions, so the objects that
+// exist once the watch is started are replayed as Modified events instead.
+func (f *fakeArgoCD) watchWithReplay(action clienttesting.Action) (bool, watch.Interface, error) {
+	gvr, namespace := action.GetResource(), action.GetNamespace()
+	w, err := f.client.Tracker().Watch(gvr, namespace)
+	if err != nil {
+		return true, nil, err
+	}
+	list, err := f.client.Tracker().List(gvr, gvr.GroupVersion().WithKind(argoCDKinds[gvr]), namespace)
+	if err != nil {
+		w.Stop()
+		return true, nil, err
+	}
+	objs, err := meta.ExtractList(list)
+	if err != nil {
+		w.Stop()
+		return true, nil, err
+	}
+
+	events := make(chan watch.Event)
+	proxy := watch.NewProxyWatcher(events)
+	go func() {
+		defer close(events)
+		defer w.Stop()
+		send := func(event watch.Event) bool {
+			select {
+			case events <- event:
+				return true
+			case <-proxy.StopChan():
+				return false
+			}
+		}
+		for _, obj := range objs {
+			if !send(watch.Event{Type: watch.Modified, Object: obj}) {
+				return
+			}
+		}
+		for {
+			select {
+			case event, ok := <-w.ResultChan():
+				if !ok || !send(event) {
+					return
+				}
+			case <-proxy.StopChan():
+				return
+			}
+		}
+	}()
+	return true, proxy, nil
+}
+
+// runController simulates the ArgoCD application controller until the test ends:
+// each Application it sees is synced to its target revision, and reports a single
+// Deployment with the health set by setHealth. Requested operations are completed
+// by syncing again.
+func (f *fakeArgoCD) runController(t *testing.T) {
+	w, err := f.client.Resource(applicationGVR).Namespace("argocd").Watch(t.Context(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to watch applications: %v", err)
+	}
+
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		w.Stop()
+		<-done
+	})
+	go func() {
+		defer close(done)
+		for event := range w.ResultChan() {
+			if event.Type != watch.Added && event.Type != watch.Modified {
+				continue
+			}
+			if obj, ok := event.Object.(*unstructured.Unstructured); ok {
+				f.reconcile(obj.GetName())
+			}
+		}
+	}()
+}
+
+// reconcile reports the status of an Application, unless it was reconciled since
+// its spec last changed, and neither an operation was requested nor its health changed.
+// Like ArgoCD, it syncs when the sources changed since the last sync, e.g. their images.
+func (f *fakeArgoCD) reconcile(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Re-read the Application, since the event may be older than the last apply
+	live, err := f.client.Tracker().Get(applicationGVR, "argocd", name)
+	if err != nil {
+		return
+	}
+	obj := live.(*unstructured.Unstructured).DeepCopy()
+
+	health := f.health[name]
+	if health == "" {
+		health = "Healthy"
+	}
+	s := parseApplicationStatus(obj)
+	if !s.Pending && s.Health == health && f.reconciled[name] == obj.GetGeneration() {
+		return
+	}
+	f.reconciled[name] = obj.GetGeneration()
+
+	// ArgoCD reports times in seconds; distinct times tell reconciliations apart
+	now := time.Now().UTC().Truncate(time.Second)
+	if !now.After(f.clock) {
+		now = f.clock.Add(time.Second)
+	}
+	f.clock = now
+
+	revision, _, _ := unstructured.NestedString(obj.Object, "spec", "source", "targetRevision")
+	spec, _, _ := unstructured.NestedMap(obj.Object, "spec")
+	source, _, _ := unstructured.NestedMap(spec, "source")
+	comparedTo := map[string]any{"destination": spec["destination"]}
+	if sources, ok := spec["sources"]; ok {
+		comparedTo["sources"] = sources
+	} else {
+		comparedTo["source"] = source
+	}
+
+	status, _, _ := unstructured.NestedMap(obj.Object, "status")
+	if status == nil {
+		status = map[string]any{}
+	}
+	status["reconciledAt"] = now.Format(time.RFC3339)
+
+	// Record a deployment in the history when syncing, initiated by the operation if there is one.
+	// An operation can sync another revision and source than the spec's, like a rollback does.
+	if s.Pending || !comparedToSpec(obj) {
+		history, _, _ := unstructured.NestedSlice(obj.Object, "status", "history")
+		initiatedBy := map[string]any{"automated": true}
+		if op, found, _ := unstructured.NestedMap(obj.Object, "operation", "initiatedBy"); found {
+			initiatedBy = op
+		}
+		deployed, deployedSource := revision, source
+		if r, found, _ := unstructured.NestedString(obj.Object, "operation", "sync", "revision"); found {
+			deployed = r
+		}
+		if src, found, _ := unstructured.NestedMap(obj.Object, "operation", "sync", "source"); found {
+			deployedSource = src
+		}
+		f.deployed[name] = deployed
+		history = append(history, map[string]any{
+			"id":          int64(len(history)),
+			"revision":    deployed,
+			"deployedAt":  now.Format(time.RFC3339),
+			"source":      deployedSource,
+			"initiatedBy": initiatedBy,
+		})
+		status["history"] = history
+		status["operationState"] = map[string]any{
+			"phase":      "Succeeded",
+			"message":    "successfully synced (all tasks run)",
+			"startedAt":  now.Format(time.RFC3339),
+			"finishedAt": now.Format(time.RFC3339),
+		}
+		delete(obj.Object, "operation")
+	}
+
+	namespace, _, _ := unstructured.NestedString(obj.Object, "spec", "destination", "namespace")
+	deployment := map[string]any{
+		"group":     "apps",
+		"kind":      "Deployment",
+		"namespace": namespace,
+		"name":      name,
+		"status":    "Synced",
+		"health":    map[string]any{"status": health},
+	}
+	if health == "Degraded" {
+		deployment["health"] = map[string]any{"status": health, "message": "Deployment " + name + " has exceeded its progress deadline"}
+	}
+
+	// ArgoCD compares the live state with the target revision, which a rollback leaves OutOfSync
+	syncStatus := "Synced"
+	if f.deployed[name] != revision {
+		syncStatus = "OutOfSync"
+	}
+	status["sync"] = map[string]any{"status": syncStatus, "revision": revision, "comparedTo": comparedTo}
+	status["health"] = map[string]any{"status": health}
+	status["resources"] = []any{deployment}
+	obj.Object["status"] = status
+	_ = f.client.Tracker().Update(applicationGVR, obj, "argocd")
+}
+
+// testEnv builds an operation environment from plain values.
+func testEnv(values map[string]string) map[string]app.EnvironmentVariable {
+	env := make(map[string]app.EnvironmentVariable, len(values))
+	for k, v := range values {
+		env[k] = app.EnvironmentVariable{Key: k, Value: v, Type: app.ENVIRONMENT_VARIABLE_TYPE_VAR}
+	}
+	return env
+}
+
+// testMetadata returns the metadata of operations requested by testProjectID.
+func testMetadata() *app.Metadata {
+	return &app.Metadata{ProjectID: testProjectID, ProjectName: "Project 1"}
+}
+
+// applicationInput returns create input with the defaults of create.json applied,
+// like Tempest does before calling an operation, overridden by overrides.
+func applicationInput(overrides map[string]any) map[string]any {
+	input := map[string]any{
+		"name":              "guestbook",
+		"namespace":         "default",
+		"argocd_project":    "default",
+		"source_type":       "kustomize",
+		"repo_url":          "https://github.com/tempestdx/example-repository.git",
+		"source_path":       "applications/guestbook",
+		"target_revision":   "HEAD",
+		"directory_recurse": false,
+		"sync_mode":         "automated",
+		"sync_prune":        true,
+		"sync_self_heal":    true,
+		"sync_allow_empty":  false,
+		"sync_retry_limit":  float64(0),
+	}
+	for k, v := range overrides {
+		input[k] = v
+	}
+	return input
+}
@@ -0,0 +1,103 @@
+package appargocd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tempestdx/sdk-go/app"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Annotations recording the last create or update of an Application through
+// Tempest, reported as the deployed_* properties. ArgoCD's own history only
+// knows that automated sync deployed the change, not where it came from.
+// Requests don't name the Tempest user behind them, so neither do these.
+const (
+	deployedByProjectAnnotation = "tempest.dev/deployed-by-project" // Tempest project the change was made in
+	deployedAtAnnotation        = "tempest.dev/deployed-at"         // Time of the change, in RFC 3339 format
+)
+
+// tempestInitiator is the ArgoCD username operations are initiated by when the
+// request doesn't name a Tempest project.
+const tempestInitiator = "tempest"
+
+// initiatorFromMetadata returns the ArgoCD username of operations requested through
+// Tempest, recorded in status.history. It names the Tempest project the request was
+// made in, e.g. tempest:project-1, since requests don't name the user behind them.
+func initiatorFromMetadata(md *app.Metadata) string {
+	if md != nil && md.ProjectID != "" {
+		return tempestInitiator + ":" + md.ProjectID
+	}
+	return tempestInitiator
+}
+
+// stampDeployment adds the deployed-* annotations of a create or update to the
+// annotations of an Application.
+func stampDeployment(annotations map[string]string, md *app.Metadata, now time.Time) {
+	if md != nil && md.ProjectID != "" {
+		annotations[deployedByProjectAnnotation] = md.ProjectID
+	}
+	annotations[deployedAtAnnotation] = now.UTC().Format(time.RFC3339)
+}
+
+// historyProperties reports the deployments ArgoCD recorded in status.history,
+// newest first, and the last change made through Tempest. Each deployment is
+// flattened into a string of key=value pairs, since properties can't hold objects:
+//
+//	id=3 revision=9f2c1e4 deployed_at=2026-10-18T12:00:00Z initiated_by=automated images=guestbook:1.1.0
+func historyProperties(obj *unstructured.Unstructured) map[string]any {
+	history, _, _ := unstructured.NestedSlice(obj.Object, "status", "history")
+
+	deployments := make([]string, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		entry, ok := history[i].(map[string]any)
+		if !ok {
+			continue
+		}
+		deployments = append(deployments, formatDeployment(entry))
+	}
+
+	annotations := obj.GetAnnotations()
+	return map[string]any{
+		"deployments":         toAnySlice(deployments),
+		"deployed_by_project": annotations[deployedByProjectAnnotation],
+		"deployed_at":         annotations[deployedAtAnnotation],
+	}
+}
+
+// formatDeployment describes a status.history entry. Multi-source deployments
+// list a revision per source, and the images of all their Kustomize sources.
+func formatDeployment(entry map[string]any) string {
+	id, _, _ := unstructured.NestedInt64(entry, "id")
+	revision, _, _ := unstructured.NestedString(entry, "revision")
+	if revisions, _, _ := unstructured.NestedStringSlice(entry, "revisions"); len(revisions) > 0 {
+		revision = strings.Join(revisions, ",")
+	}
+	deployedAt, _, _ := unstructured.NestedString(entry, "deployedAt")
+
+	initiatedBy, _, _ := unstructured.NestedString(entry, "initiatedBy", "username")
+	if automated, _, _ := unstructured.NestedBool(entry, "initiatedBy", "automated"); automated && initiatedBy == "" {
+		initiatedBy = "automated"
+	}
+
+	// History entries hold their sources under the same keys as spec
+	var images []string
+	sources, _ := sourcesFromApplication(&unstructured.Unstructured{Object: map[string]any{"spec": entry}})
+	for _, s := range sources {
+		if s.Kustomize != nil {
+			images = append(images, imageReferences(parseImageOverrides(s.Kustomize.Images))...)
+		}
+	}
+
+	fields := []string{
+		fmt.Sprintf("id=%d", id),
+		"revision=" + revision,
+		"deployed_at=" + deployedAt,
+		"initiated_by=" + initiatedBy,
+	}
+	if len(images) > 0 {
+		fields = append(fields, "images="+strings.Join(images, ","))
+	}
+	return strings.Join(fields, " ")
+}
@@ -0,0 +1,102 @@
+package appargocd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tempestdx/sdk-go/app"
+)
+
+func TestDeploymentHistory(t *testing.T) {
+	f := newFakeArgoCD(t)
+
+	// The project author is who created the project, not who makes the request
+	md := testMetadata()
+	md.Author = app.Owner{Email: "alice@example.com", Name: "Alice", Type: app.OwnerTypeUser}
+
+	created, err := createFn(t.Context(), &app.OperationRequest{
+		Metadata:    md,
+		Environment: f.env(),
+		Input:       applicationInput(map[string]any{"image": "registry.example.com/guestbook:1.0.0"}),
+	})
+	if err != nil {
+		t.Fatalf("createFn: %v", err)
+	}
+
+	// The image is updated, which automated sync deploys
+	if _, err := updateFn(t.Context(), &app.OperationRequest{
+		Metadata:    md,
+		Environment: f.env(),
+		Resource:    created.Resource,
+		Input:       applicationInput(map[string]any{"image": "registry.example.com/guestbook:1.1.0"}),
+	}); err != nil {
+		t.Fatalf("updateFn: %v", err)
+	}
+
+	// And synced again through Tempest
+	if _, err := syncAction(t.Context(), &app.ActionRequest{
+		Metadata:    md,
+		Environment: f.env(),
+		Resource:    created.Resource,
+		Input:       map[string]any{"prune": false},
+	}); err != nil {
+		t.Fatalf("syncAction: %v", err)
+	}
+
+	read, err := readFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Resource:    created.Resource,
+	})
+	if err != nil {
+		t.Fatalf("readFn: %v", err)
+	}
+
+	if got := read.Resource.Properties["deployed_by_project"]; got != testProjectID {
+		t.Errorf("property deployed_by_project = %v, want %v", got, testProjectID)
+	}
+	if _, ok := read.Resource.Properties["deployed_by"]; ok {
+		t.Error("property deployed_by is reported, but requests don't name the user behind them")
+	}
+	if got := read.Resource.Properties["deployed_at"]; got == "" {
+		t.Error("property deployed_at is empty")
+	}
+
+	// Newest first
+	deployments := read.Resource.Properties["deployments"].([]any)
+	want := []string{
+		"id=2 revision=HEAD deployed_at=* initiated_by=tempest:proj-1 images=registry.example.com/guestbook:1.1.0",
+		"id=1 revision=HEAD deployed_at=* initiated_by=automated images=registry.example.com/guestbook:1.1.0",
+		"id=0 revision=HEAD deployed_at=* initiated_by=automated images=registry.example.com/guestbook:1.0.0",
+	}
+	if len(deployments) != len(want) {
+		t.Fatalf("property deployments = %v, want %d deployments", deployments, len(want))
+	}
+	for i, d := range deployments {
+		prefix, suffix, _ := strings.Cut(want[i], "*")
+		if s := d.(string); !strings.HasPrefix(s, prefix) || !strings.HasSuffix(s, suffix) || len(s) <= len(want[i]) {
+			t.Errorf("deployments[%d] = %q, want %q", i, s, want[i])
+		}
+	}
+}
+
+func TestFormatDeployment(t *testing.T) {
+	entry := map[string]any{
+		"id":         int64(7),
+		"revisions":  []any{"9f2c1e4", "19.0.0"},
+		"deployedAt": "2026-10-18T12:00:00Z",
+		"sources": []any{
+			map[string]any{
+				"repoURL":   "https://github.com/tempestdx/example-repository.git",
+				"kustomize": map[string]any{"images": []any{"guestbook=registry.example.com/guestbook:1.1.0"}},
+			},
+			map[string]any{"repoURL": "https://charts.bitnami.com/bitnami", "chart": "redis"},
+		},
+		"initiatedBy": map[string]any{"username": "admin"},
+	}
+
+	want := "id=7 revision=9f2c1e4,19.0.0 deployed_at=2026-10-18T12:00:00Z initiated_by=admin images=registry.example.com/guestbook:1.1.0"
+	if got := formatDeployment(entry); got != want {
+		t.Errorf("formatDeployment = %q, want %q", got, want)
+	}
+}
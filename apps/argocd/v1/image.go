@@ -0,0 +1,173 @@
+package appargocd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/distribution/reference"
+)
+
+// ImageOverride is a Kustomize image override, as stored in an Application's
+// spec.source.kustomize.images using the syntax "name=newName:newTag@digest".
+// See: https://argo-cd.readthedocs.io/en/stable/user-guide/kustomize/
+type ImageOverride struct {
+	Name    string // Image name as it appears in the manifests
+	NewName string // Replacement image name, empty to keep Name
+	NewTag  string // Replacement tag
+	Digest  string // Replacement digest, e.g. sha256:...
+}
+
+// parseImageReference splits an image reference such as
+// "registry.example.com/app:1.0.1@sha256:..." into its name, tag and digest,
+// rejecting anything that isn't a valid reference.
+func parseImageReference(s string) (name, tag, digest string, err error) {
+	ref, err := reference.Parse(s)
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid image reference %q: %w", s, err)
+	}
+
+	named, ok := ref.(reference.Named)
+	if !ok {
+		return "", "", "", fmt.Errorf("invalid image reference %q: missing image name", s)
+	}
+	name = named.Name()
+	if tagged, ok := ref.(reference.Tagged); ok {
+		tag = tagged.Tag()
+	}
+	if digested, ok := ref.(reference.Digested); ok {
+		digest = digested.Digest().String()
+	}
+	return name, tag, digest, nil
+}
+
+// parseImageOverride parses the Kustomize image syntax used by ArgoCD. Both
+// "name=newName:newTag@digest" and the short form "name:newTag@digest", which
+// only replaces the tag or digest, are accepted.
+func parseImageOverride(s string) (ImageOverride, error) {
+	oldName, newImage, replaced := strings.Cut(s, "=")
+	if !replaced {
+		name, tag, digest, err := parseImageReference(s)
+		if err != nil {
+			return ImageOverride{}, err
+		}
+		return ImageOverride{Name: name, NewTag: tag, Digest: digest}, nil
+	}
+
+	if _, _, _, err := parseImageReference(oldName); err != nil {
+		return ImageOverride{}, fmt.Errorf("invalid image override %q: %w", s, err)
+	}
+
+	newName, tag, digest, err := parseImageReference(newImage)
+	if err != nil {
+		return ImageOverride{}, fmt.Errorf("invalid image override %q: %w", s, err)
+	}
+	return ImageOverride{Name: oldName, NewName: newName, NewTag: tag, Digest: digest}, nil
+}
+
+// String formats the override in Kustomize image syntax.
+func (o ImageOverride) String() string {
+	if o.NewName == "" {
+		return o.Name + o.suffix()
+	}
+	return o.Name + "=" + o.NewName + o.suffix()
+}
+
+// Reference returns the image that is actually deployed, without the "name=" prefix.
+func (o ImageOverride) Reference() string {
+	if o.NewName == "" {
+		return o.Name + o.suffix()
+	}
+	return o.NewName + o.suffix()
+}
+
+func (o ImageOverride) suffix() string {
+	var s string
+	if o.NewTag != "" {
+		s += ":" + o.NewTag
+	}
+	if o.Digest != "" {
+		s += "@" + o.Digest
+	}
+	return s
+}
+
+// imageOverridesFromInput reads the "image" and "images" inputs.
+// Entries in Kustomize syntax ("name=newName:tag") are used as is. A plain image
+// reference replaces the image named after the Application, so "image" keeps
+// its original meaning of "the image to deploy".
+func imageOverridesFromInput(name string, input map[string]any) ([]ImageOverride, error) {
+	var refs []string
+	if image := stringInput(input, "image"); image != "" {
+		refs = append(refs, image)
+	}
+	refs = append(refs, stringSliceInput(input, "images")...)
+
+	overrides := make([]ImageOverride, 0, len(refs))
+	for _, ref := range refs {
+		if strings.Contains(ref, "=") {
+			o, err := parseImageOverride(ref)
+			if err != nil {
+				return nil, err
+			}
+			overrides = append(overrides, o)
+			continue
+		}
+
+		newName, tag, digest, err := parseImageReference(ref)
+		if err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, ImageOverride{Name: name, NewName: newName, NewTag: tag, Digest: digest})
+	}
+
+	return overrides, nil
+}
+
+// parseImageOverrides parses a list of Kustomize images, as found in an Application.
+// Entries that can't be parsed were not written by this app, so they are kept
+// verbatim rather than failing the whole read.
+func parseImageOverrides(images []string) []ImageOverride {
+	overrides := make([]ImageOverride, 0, len(images))
+	for _, image := range images {
+		o, err := parseImageOverride(image)
+		if err != nil {
+			o = ImageOverride{Name: image}
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides
+}
+
+// sourceImageOverrides returns the Kustomize image overrides of the first source.
+func sourceImageOverrides(sources []ApplicationSource) []ImageOverride {
+	if len(sources) == 0 || sources[0].Kustomize == nil {
+		return nil
+	}
+	return parseImageOverrides(sources[0].Kustomize.Images)
+}
+
+// imageStrings formats overrides in Kustomize image syntax.
+func imageStrings(overrides []ImageOverride) []string {
+	out := make([]string, 0, len(overrides))
+	for _, o := range overrides {
+		out = append(out, o.String())
+	}
+	return out
+}
+
+// imageProperties returns the image related properties exposed in the Tempest catalog.
+// The first override is reported as the deployed "image", split into tag and digest.
+func imageProperties(overrides []ImageOverride) map[string]any {
+	props := map[string]any{
+		"image":        "",
+		"image_tag":    "",
+		"image_digest": "",
+		"images":       toAnySlice(imageStrings(overrides)),
+	}
+	if len(overrides) > 0 {
+		props["image"] = overrides[0].Reference()
+		props["image_tag"] = overrides[0].NewTag
+		props["image_digest"] = overrides[0].Digest
+	}
+	return props
+}
@@ -0,0 +1,34 @@
+package appargocd
+
+// Tempest validates req.Input against the operation's JSON schema before calling
+// the handlers, so optional values are either absent or of the declared type.
+// These helpers read optional values without repeating the type assertions.
+
+// stringInput returns the string value of key, or "" if it is not set.
+func stringInput(input map[string]any, key string) string {
+	s, _ := input[key].(string)
+	return s
+}
+
+// boolInput returns the boolean value of key, or false if it is not set.
+func boolInput(input map[string]any, key string) bool {
+	b, _ := input[key].(bool)
+	return b
+}
+
+// stringSliceInput returns the string array value of key, or nil if it is not set.
+// JSON arrays are decoded as []any, so each element is asserted individually.
+func stringSliceInput(input map[string]any, key string) []string {
+	items, _ := input[key].([]any)
+	if len(items) == 0 {
+		return nil
+	}
+
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,175 @@
+package appargocd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tempestdx/sdk-go/app"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// newDynamicClient creates the dynamic client operations reach the cluster with.
+// Tests replace it to run operations against a fake client.
+var newDynamicClient = func(config *rest.Config) (dynamic.Interface, error) {
+	return dynamic.NewForConfig(config)
+}
+
+// getConfigFromEnv creates a Kubernetes client configuration from environment variables
+// Tempest Private Apps receive configuration through environment variables passed
+// from the Tempest platform. This is how the app connects to the target Kubernetes cluster.
+//
+// The first of these that is set is used:
+//   - KUBE_SERVER and KUBE_TOKEN, with an optional KUBE_CA_DATA: a bearer token for an API server
+//   - KUBECONFIG: a kubeconfig, either inline or as a file path, optionally with KUBE_CONTEXT
+//   - KUBE_IN_CLUSTER=true: the service account of the Pod the app runs in
+func getConfigFromEnv(env map[string]app.EnvironmentVariable) (*rest.Config, error) {
+	switch {
+	case envValue(env, "KUBE_SERVER") != "" || envValue(env, "KUBE_TOKEN") != "":
+		return getTokenConfigFromEnv(env)
+	case envValue(env, "KUBECONFIG") != "":
+		return getKubeconfigFromEnv(env)
+	}
+
+	if inCluster := envValue(env, "KUBE_IN_CLUSTER"); inCluster != "" {
+		enabled, err := strconv.ParseBool(inCluster)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KUBE_IN_CLUSTER: %w", err)
+		}
+		if enabled {
+			config, err := rest.InClusterConfig()
+			if err != nil {
+				return nil, fmt.Errorf("failed to build in-cluster config: %w", err)
+			}
+			return config, nil
+		}
+	}
+
+	return nil, errors.New("KUBECONFIG not found in environment; set KUBECONFIG, KUBE_SERVER and KUBE_TOKEN, or KUBE_IN_CLUSTER")
+}
+
+// getKubeconfigFromEnv builds a config from KUBECONFIG, which holds either the content
+// of a kubeconfig or the path to one, and selects the KUBE_CONTEXT context if set.
+func getKubeconfigFromEnv(env map[string]app.EnvironmentVariable) (*rest.Config, error) {
+	kubeconfig := envValue(env, "KUBECONFIG")
+
+	var raw *clientcmdapi.Config
+	var err error
+	if isInlineKubeconfig(kubeconfig) {
+		raw, err = clientcmd.Load([]byte(kubeconfig))
+	} else {
+		raw, err = clientcmd.LoadFromFile(kubeconfig)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+
+	contextName := envValue(env, "KUBE_CONTEXT")
+	if contextName != "" {
+		if _, ok := raw.Contexts[contextName]; !ok {
+			return nil, fmt.Errorf("context %q not found in kubeconfig", contextName)
+		}
+	}
+
+	config, err := clientcmd.NewNonInteractiveClientConfig(*raw, contextName, &clientcmd.ConfigOverrides{}, nil).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build kubeconfig: %w", err)
+	}
+
+	return config, nil
+}
+
+// isInlineKubeconfig tells a kubeconfig's content apart from a file path.
+// Kubeconfigs are YAML or JSON documents spanning several lines, paths are not.
+func isInlineKubeconfig(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.Contains(s, "\n") || strings.HasPrefix(s, "{") || strings.HasPrefix(s, "apiVersion:")
+}
+
+// getTokenConfigFromEnv builds a config from an API server URL, a bearer token,
+// and the API server's CA certificate, as PEM or base64-encoded PEM.
+// Without KUBE_CA_DATA, the system's trusted CAs are used.
+func getTokenConfigFromEnv(env map[string]app.EnvironmentVariable) (*rest.Config, error) {
+	server, err := requireEnv(env, "KUBE_SERVER")
+	if err != nil {
+		return nil, err
+	}
+	token, err := requireEnv(env, "KUBE_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+
+	config := &rest.Config{
+		Host:        server,
+		BearerToken: token,
+	}
+
+	if config.CAData, err = decodeCAData(envValue(env, "KUBE_CA_DATA")); err != nil {
+		return nil, fmt.Errorf("invalid KUBE_CA_DATA: %w", err)
+	}
+
+	return config, nil
+}
+
+// decodeCAData reads a CA certificate given as PEM or as base64-encoded PEM.
+// It returns nil for an empty certificate.
+func decodeCAData(ca string) ([]byte, error) {
+	ca = strings.TrimSpace(ca)
+	if ca == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(ca, "-----BEGIN") {
+		return []byte(ca), nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(ca)
+	if err != nil {
+		return nil, fmt.Errorf("expected PEM or base64-encoded PEM: %w", err)
+	}
+	return decoded, nil
+}
+
+// validateCluster checks that the cluster is reachable, that the credentials are
+// accepted, and that ArgoCD is installed, before anything is applied.
+// This way a misconfigured environment fails fast instead of after the repository
+// secrets have already been applied.
+func validateCluster(ctx context.Context, config *rest.Config) error {
+	dc, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to create discovery client: %w", err)
+	}
+
+	groupVersion := applicationGVR.GroupVersion().String()
+	body, err := dc.RESTClient().Get().AbsPath("/apis", groupVersion).DoRaw(ctx)
+	switch {
+	case k8serrors.IsUnauthorized(err):
+		return fmt.Errorf("cluster %s rejected the credentials: %w", config.Host, err)
+	case k8serrors.IsForbidden(err):
+		return fmt.Errorf("credentials are not allowed to access %s in cluster %s: %w", groupVersion, config.Host, err)
+	case k8serrors.IsNotFound(err):
+		return fmt.Errorf("ArgoCD is not installed in cluster %s: API %s not found", config.Host, groupVersion)
+	case err != nil:
+		return fmt.Errorf("failed to reach cluster %s: %w", config.Host, err)
+	}
+
+	var resources metav1.APIResourceList
+	if err := json.Unmarshal(body, &resources); err != nil {
+		return fmt.Errorf("failed to decode API resources of %s: %w", groupVersion, err)
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == applicationGVR.Resource {
+			return nil
+		}
+	}
+	return fmt.Errorf("ArgoCD is not installed in cluster %s: %s not found in %s", config.Host, applicationGVR.Resource, groupVersion)
+}
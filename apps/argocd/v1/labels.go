@@ -0,0 +1,169 @@
+package appargocd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/tempestdx/sdk-go/app"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// projectLabel is stamped on every Application created through Tempest, and holds
+// the ID of the Tempest project it belongs to. readFn and listFn use it to find
+// the Applications managed by Tempest.
+const projectLabel = "tempest.dev/project"
+
+// tempestPrefix is reserved for labels and annotations set by the app itself,
+// so user input can't overwrite them.
+const tempestPrefix = "tempest.dev/"
+
+// listPageSize is how many Applications listFn returns per page.
+const listPageSize = 100
+
+// lastAppliedAnnotation is set by kubectl apply, and holds a copy of the whole
+// manifest. It is left out of the reported annotations.
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// projectIDFromMetadata returns the ID of the Tempest project making a request,
+// which must be a valid label value.
+func projectIDFromMetadata(md *app.Metadata) (string, error) {
+	if md == nil || md.ProjectID == "" {
+		return "", fmt.Errorf("project ID not found in request metadata")
+	}
+	if errs := validation.IsValidLabelValue(md.ProjectID); len(errs) > 0 {
+		return "", fmt.Errorf("project ID %q can't be used as the %s label: %s", md.ProjectID, projectLabel, strings.Join(errs, "; "))
+	}
+	return md.ProjectID, nil
+}
+
+// checkProjectOwner refuses to change a live object of kind managed by another Tempest
+// project, which updating would hand over to projectID by stamping its project label.
+// Objects without the label, such as ones created before it was stamped, are adopted.
+func checkProjectOwner(kind string, live *unstructured.Unstructured, projectID string) error {
+	if project, ok := live.GetLabels()[projectLabel]; ok && project != projectID {
+		return fmt.Errorf("%s %s is managed by another Tempest project (%s)", kind, live.GetName(), project)
+	}
+	return nil
+}
+
+// labelsFromInput reads the "labels" input, in key=value form, and stamps the project label.
+// Keys such as team or cost-center tag Applications with their owners.
+func labelsFromInput(input map[string]any, projectID string) (map[string]string, error) {
+	labels, err := keyValueInput(input, "labels")
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range labels {
+		if errs := validation.IsValidLabelValue(v); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid value of label %s: %s", k, strings.Join(errs, "; "))
+		}
+	}
+
+	labels[projectLabel] = projectID
+	return labels, nil
+}
+
+// annotationsFromInput reads the "annotations" input, in key=value form.
+// This is where ArgoCD Notifications subscriptions go, such as
+// notifications.argoproj.io/subscribe.on-sync-failed.slack=my-channel.
+func annotationsFromInput(input map[string]any) (map[string]string, error) {
+	return keyValueInput(input, "annotations")
+}
+
+// keyValueInput parses a string array input of key=value pairs into a map.
+// Keys must be valid Kubernetes label or annotation keys outside tempest.dev/.
+func keyValueInput(input map[string]any, key string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, kv := range stringSliceInput(input, key) {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid %s entry %q: expected key=value", key, kv)
+		}
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid %s key %q: %s", key, k, strings.Join(errs, "; "))
+		}
+		if strings.HasPrefix(k, tempestPrefix) {
+			return nil, fmt.Errorf("invalid %s key %q: keys starting with %s are reserved", key, k, tempestPrefix)
+		}
+		if _, dup := out[k]; dup {
+			return nil, fmt.Errorf("duplicate %s key %q", key, k)
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+// userLabels returns the labels of a live object that came from the labels input,
+// leaving out the tempest.dev/ labels the app sets itself.
+func userLabels(obj *unstructured.Unstructured) map[string]string {
+	labels := map[string]string{}
+	for k, v := range obj.GetLabels() {
+		if !strings.HasPrefix(k, tempestPrefix) {
+			labels[k] = v
+		}
+	}
+	return labels
+}
+
+// userAnnotations returns the annotations of a live object that came from the annotations
+// input. The app's own tempest.dev/ annotations are left out, along with the ones kubectl
+// and ArgoCD set, such as a pending refresh, which applying again would repeat.
+func userAnnotations(obj *unstructured.Unstructured) map[string]string {
+	annotations := map[string]string{}
+	for k, v := range obj.GetAnnotations() {
+		if !strings.HasPrefix(k, tempestPrefix) && k != lastAppliedAnnotation && k != refreshAnnotation {
+			annotations[k] = v
+		}
+	}
+	return annotations
+}
+
+// keepTempestAnnotations copies the tempest.dev/ annotations of the live Application
+// that annotations doesn't set. They record what the app did to the Application, such
+// as its promotions, rather than input, so rendering it again must not drop them.
+func keepTempestAnnotations(annotations map[string]string, live *unstructured.Unstructured) {
+	for k, v := range live.GetAnnotations() {
+		if _, ok := annotations[k]; !ok && strings.HasPrefix(k, tempestPrefix) {
+			annotations[k] = v
+		}
+	}
+}
+
+// metadataProperties exposes the labels and annotations of an Application in the
+// Tempest catalog, in key=value form.
+func metadataProperties(obj *unstructured.Unstructured) map[string]any {
+	annotations := obj.GetAnnotations()
+	delete(annotations, lastAppliedAnnotation)
+
+	return map[string]any{
+		"project_id":  obj.GetLabels()[projectLabel],
+		"labels":      toAnySlice(keyValueStrings(obj.GetLabels())),
+		"annotations": toAnySlice(keyValueStrings(annotations)),
+	}
+}
+
+// keyValueStrings formats a map as key=value strings, sorted by key.
+func keyValueStrings(m map[string]string) []string {
+	out := make([]string, 0, len(m))
+	for k, v := range m {
+		out = append(out, k+"="+v)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// processEnvironment returns the environment variables of the app's own process.
+// List requests don't carry the Tempest environment, so listFn reads KUBECONFIG
+// and the other settings from the environment the app was started with.
+func processEnvironment() map[string]app.EnvironmentVariable {
+	env := map[string]app.EnvironmentVariable{}
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = app.EnvironmentVariable{Key: k, Value: v}
+		}
+	}
+	return env
+}
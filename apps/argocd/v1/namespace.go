@@ -0,0 +1,798 @@
+package appargocd
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tempestdx/examples/deps/kube"
+	"github.com/tempestdx/sdk-go/app"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+)
+
+// namespaceReadyTimeout bounds how long namespace operations wait for the namespace to become Active.
+const namespaceReadyTimeout = time.Minute
+
+// Names of the objects the namespace resource adds to a namespace. Objects with these
+// names and the project label are owned by Tempest.
+const (
+	namespaceQuotaName     = "tempest-quota"
+	namespaceLimitsName    = "tempest-limits"
+	namespaceIsolationName = "tempest-isolation"
+)
+
+// protectedNamespaces can't be managed by the namespace resource, since Kubernetes
+// or ArgoCD itself run in them.
+var protectedNamespaces = []string{"default", "argocd", "kube-system", "kube-public", "kube-node-lease"}
+
+// namespaceRoles are the ClusterRoles the "<role>_groups" inputs grant in a namespace.
+// Each is granted by a RoleBinding called tempest-<role>.
+var namespaceRoles = []string{"admin", "edit", "view"}
+
+// namespaceQuotaInputs maps the quota inputs to the resources of the ResourceQuota.
+var namespaceQuotaInputs = map[string]quotaResource{
+	"quota_cpu":     {"requests.cpu", "cpu"},
+	"quota_memory":  {"requests.memory", "memory"},
+	"quota_storage": {"requests.storage", "storage"},
+}
+
+// quotaResource is a resource a quota input caps, by its name in a ResourceQuota
+// and in the properties.
+type quotaResource struct {
+	Quota    string
+	Property string
+}
+
+var (
+	// Embed the JSON schemas of the "namespace" resource, like those of "application"
+	//go:embed schema/namespace_properties.json
+	namespacePropertiesSchema []byte
+
+	//go:embed schema/namespace_create.json
+	namespaceCreateSchema []byte
+
+	//go:embed schema/namespace_update.json
+	namespaceUpdateSchema []byte
+
+	// GVRs of the objects the namespace resource manages.
+	namespaceGVR     = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	resourceQuotaGVR = schema.GroupVersionResource{Version: "v1", Resource: "resourcequotas"}
+	limitRangeGVR    = schema.GroupVersionResource{Version: "v1", Resource: "limitranges"}
+	networkPolicyGVR = schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "networkpolicies"}
+	roleBindingGVR   = schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "rolebindings"}
+)
+
+// newNamespaceDefinition returns the resource type that provisions the namespaces
+// Applications deploy to, with a quota, default container limits, network isolation
+// and access for the team.
+func newNamespaceDefinition() app.ResourceDefinition {
+	return app.ResourceDefinition{
+		Type:             "namespace",
+		DisplayName:      "Namespace",
+		Description:      "Provisions a Kubernetes namespace for Applications, with a resource quota, default limits, network isolation and access for a team.",
+		LifecycleStage:   app.LifecycleStageDeploy,
+		PropertiesSchema: app.MustParseJSONSchema(namespacePropertiesSchema),
+	}
+}
+
+// namespaceTemplateInput holds the values rendered into namespace.yaml.tmpl.
+type namespaceTemplateInput struct {
+	Name             string            // Name of the namespace
+	CreateNamespace  bool              // Whether the Namespace object is rendered, see namespaceFromInput
+	Labels           map[string]string // Labels of every object, including the tempest.dev/project label
+	Quota            map[string]string // Hard limits of the ResourceQuota, by resource
+	DefaultRequest   map[string]string // Default container requests of the LimitRange, by resource
+	DefaultLimit     map[string]string // Default container limits of the LimitRange, by resource
+	NetworkIsolation bool              // Whether the NetworkPolicy isolating the namespace is rendered
+	RoleBindings     []namespaceRoleBinding
+}
+
+// namespaceRoleBinding grants a ClusterRole in the namespace to groups.
+type namespaceRoleBinding struct {
+	Name        string
+	ClusterRole string
+	Groups      []string
+}
+
+// namespaceFromInput builds the objects of a namespace from create or update input.
+// live is the existing Namespace, if any. A Namespace that exists without the project
+// label was not created by Tempest: it is left alone, and only the objects in it are
+// managed, so deleting the resource doesn't delete the namespace.
+func namespaceFromInput(name, projectID string, input map[string]any, live *unstructured.Unstructured) (namespaceTemplateInput, error) {
+	in := namespaceTemplateInput{
+		Name:             name,
+		CreateNamespace:  live == nil || live.GetLabels()[projectLabel] == projectID,
+		Quota:            map[string]string{},
+		DefaultRequest:   map[string]string{},
+		DefaultLimit:     map[string]string{},
+		NetworkIsolation: boolInput(input, "network_isolation"),
+	}
+
+	if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+		return in, fmt.Errorf("invalid namespace name %q: %s", name, strings.Join(errs, "; "))
+	}
+	if slices.Contains(protectedNamespaces, name) || strings.HasPrefix(name, "kube-") {
+		return in, fmt.Errorf("namespace %s is reserved and can't be managed by Tempest", name)
+	}
+
+	labels, err := labelsFromInput(input, projectID)
+	if err != nil {
+		return in, err
+	}
+	in.Labels = labels
+
+	for key, r := range namespaceQuotaInputs {
+		if err := quantityInput(input, key, r.Quota, in.Quota); err != nil {
+			return in, err
+		}
+	}
+	if pods, ok := input["quota_pods"].(float64); ok && pods > 0 {
+		in.Quota["pods"] = fmt.Sprint(int64(pods))
+	}
+
+	for key, values := range map[string]map[string]string{
+		"default_cpu_request":    in.DefaultRequest,
+		"default_memory_request": in.DefaultRequest,
+		"default_cpu_limit":      in.DefaultLimit,
+		"default_memory_limit":   in.DefaultLimit,
+	} {
+		// The resource is the middle part of the input name, e.g. "cpu" of default_cpu_request
+		r := strings.Split(key, "_")[1]
+		if err := quantityInput(input, key, r, values); err != nil {
+			return in, err
+		}
+	}
+	for _, r := range []string{"cpu", "memory"} {
+		request, limit := in.DefaultRequest[r], in.DefaultLimit[r]
+		if request == "" || limit == "" {
+			continue
+		}
+		// Both were validated by quantityInput
+		if q := resource.MustParse(request); q.Cmp(resource.MustParse(limit)) > 0 {
+			return in, fmt.Errorf("default_%s_request %s is more than default_%s_limit %s", r, request, r, limit)
+		}
+	}
+
+	for _, role := range namespaceRoles {
+		groups := stringSliceInput(input, role+"_groups")
+		if len(groups) == 0 {
+			continue
+		}
+		in.RoleBindings = append(in.RoleBindings, namespaceRoleBinding{
+			Name:        "tempest-" + role,
+			ClusterRole: role,
+			Groups:      groups,
+		})
+	}
+
+	return in, nil
+}
+
+// quantityInput validates the Kubernetes quantity of the input key, e.g. "500m" or
+// "2Gi", and stores it as resource in values if it is set.
+func quantityInput(input map[string]any, key, resourceName string, values map[string]string) error {
+	s := stringInput(input, key)
+	if s == "" {
+		return nil
+	}
+	q, err := resource.ParseQuantity(s)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", key, s, err)
+	}
+	if q.Sign() <= 0 {
+		return fmt.Errorf("invalid %s %q: must be more than zero", key, s)
+	}
+	values[resourceName] = q.String()
+	return nil
+}
+
+// namespaceObjects returns every object the namespace resource may add to a
+// namespace, whether or not the input renders it, so objects left out of an
+// update can be removed. The Namespace itself is not included.
+func namespaceObjects(name string) []*unstructured.Unstructured {
+	ref := func(apiVersion, kind, objName string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(apiVersion)
+		obj.SetKind(kind)
+		obj.SetNamespace(name)
+		obj.SetName(objName)
+		return obj
+	}
+
+	objs := []*unstructured.Unstructured{
+		ref("v1", "ResourceQuota", namespaceQuotaName),
+		ref("v1", "LimitRange", namespaceLimitsName),
+		ref("networking.k8s.io/v1", "NetworkPolicy", namespaceIsolationName),
+	}
+	for _, role := range namespaceRoles {
+		objs = append(objs, ref("rbac.authorization.k8s.io/v1", "RoleBinding", "tempest-"+role))
+	}
+	return objs
+}
+
+// newNamespaceApplier creates the applier of namespace objects. Kinds are resolved
+// with restMapper, since the namespace resource only applies built-in kinds.
+func newNamespaceApplier(dc dynamic.Interface, name string) *kube.Applier {
+	return kube.NewForClients(dc, restMapper, kube.WithDefaultNamespace(name))
+}
+
+// applyNamespace applies the objects of a namespace and waits for it to become Active.
+// Objects the input no longer renders, e.g. the quota after its inputs were
+// cleared, are deleted if Tempest owns them.
+func applyNamespace(ctx context.Context, dc dynamic.Interface, env map[string]app.EnvironmentVariable, in namespaceTemplateInput, opts applyOptions) error {
+	manifests, err := renderTemplate(env, "namespace.yaml.tmpl", in)
+	if err != nil {
+		return err
+	}
+	objs, err := kube.Decode(manifests)
+	if err != nil {
+		return fmt.Errorf("failed to decode namespace manifests: %w", err)
+	}
+
+	// Conflicts are only merged for ArgoCD objects, so merge fails like fail does
+	applier := newNamespaceApplier(dc, in.Name)
+	applied, err := applier.Apply(ctx, objs, kube.ApplyOptions{
+		Force:  opts.ConflictStrategy == conflictStrategyForce,
+		DryRun: opts.DryRun,
+	})
+	if err != nil {
+		return err
+	}
+
+	rendered := map[string]bool{}
+	for _, obj := range objs {
+		rendered[obj.GetKind()+"/"+obj.GetName()] = true
+	}
+	var stale []*unstructured.Unstructured
+	for _, obj := range namespaceObjects(in.Name) {
+		if !rendered[obj.GetKind()+"/"+obj.GetName()] {
+			stale = append(stale, obj)
+		}
+	}
+	if err := deleteOwnedObjects(ctx, applier, stale, in.Labels[projectLabel]); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, namespaceReadyTimeout)
+	defer cancel()
+	return applier.Wait(ctx, applied)
+}
+
+// deleteOwnedObjects deletes the objects that exist and carry the project label of
+// projectID, leaving objects created by others alone.
+func deleteOwnedObjects(ctx context.Context, applier *kube.Applier, objs []*unstructured.Unstructured, projectID string) error {
+	var owned []*unstructured.Unstructured
+	for _, obj := range objs {
+		client, _, err := applier.ResourceClient(obj)
+		if err != nil {
+			return err
+		}
+		live, err := client.Get(ctx, obj.GetName(), metav1.GetOptions{})
+		switch {
+		case k8serrors.IsNotFound(err):
+			continue
+		case err != nil:
+			return fmt.Errorf("failed to get %s %s/%s: %w", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+		}
+		if live.GetLabels()[projectLabel] == projectID {
+			owned = append(owned, obj)
+		}
+	}
+	return applier.Delete(ctx, owned)
+}
+
+// getNamespace fetches a Namespace, returning nil if it doesn't exist.
+func getNamespace(ctx context.Context, dc dynamic.Interface, name string) (*unstructured.Unstructured, error) {
+	obj, err := dc.Resource(namespaceGVR).Get(ctx, name, metav1.GetOptions{})
+	switch {
+	case k8serrors.IsNotFound(err):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to get namespace %s: %w", name, err)
+	}
+	return obj, nil
+}
+
+// checkNamespaceOwner refuses namespaces managed by another Tempest project. For
+// namespaces Tempest didn't create, the owner is read from the quota or, failing
+// that, any other object the namespace resource adds.
+func checkNamespaceOwner(ctx context.Context, dc dynamic.Interface, obj *unstructured.Unstructured, projectID string) error {
+	project := obj.GetLabels()[projectLabel]
+	if project == "" {
+		for _, ref := range namespaceObjects(obj.GetName()) {
+			client, _, err := newNamespaceApplier(dc, obj.GetName()).ResourceClient(ref)
+			if err != nil {
+				return err
+			}
+			live, err := client.Get(ctx, ref.GetName(), metav1.GetOptions{})
+			if err == nil && live.GetLabels()[projectLabel] != "" {
+				project = live.GetLabels()[projectLabel]
+				break
+			}
+		}
+	}
+	if project != "" && projectID != "" && project != projectID {
+		return fmt.Errorf("namespace %s is managed by another Tempest project (%s)", obj.GetName(), project)
+	}
+	return nil
+}
+
+// createNamespaceFn implements the CREATE operation for the namespace resource type.
+func createNamespaceFn(ctx context.Context, req *app.OperationRequest) (*app.OperationResponse, error) {
+	config, err := getConfigFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := newDynamicClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	applyOpts, err := getApplyOptionsFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	projectID, err := projectIDFromMetadata(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	name := req.Input["name"].(string)
+	live, err := getNamespace(ctx, dynamicClient, name)
+	if err != nil {
+		return nil, err
+	}
+	if live != nil {
+		if err := checkNamespaceOwner(ctx, dynamicClient, live, projectID); err != nil {
+			return nil, err
+		}
+	}
+
+	in, err := namespaceFromInput(name, projectID, req.Input, live)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applyNamespace(ctx, dynamicClient, req.Environment, in, applyOpts); err != nil {
+		return nil, err
+	}
+
+	obj, err := getNamespace(ctx, dynamicClient, name)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, fmt.Errorf("namespace %s was not created", name)
+	}
+
+	resource, err := namespaceResource(ctx, dynamicClient, obj, strings.Join([]string{name, name, string(obj.GetUID())}, "/"), config.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	return &app.OperationResponse{Resource: resource}, nil
+}
+
+// updateNamespaceFn implements the UPDATE operation for the namespace resource type.
+func updateNamespaceFn(ctx context.Context, req *app.OperationRequest) (*app.OperationResponse, error) {
+	config, err := getConfigFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := newDynamicClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	applyOpts, err := getApplyOptionsFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	_, name, err := parseExternalID(req.Resource.ExternalID)
+	if err != nil {
+		return nil, err
+	}
+
+	projectID, err := projectIDFromMetadata(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	live, err := getNamespace(ctx, dynamicClient, name)
+	if err != nil {
+		return nil, err
+	}
+	if live == nil {
+		return nil, fmt.Errorf("namespace %s does not exist", name)
+	}
+	if err := checkNamespaceOwner(ctx, dynamicClient, live, projectID); err != nil {
+		return nil, err
+	}
+
+	input, err := namespaceUpdateInput(ctx, dynamicClient, req.Input, live)
+	if err != nil {
+		return nil, err
+	}
+
+	in, err := namespaceFromInput(name, projectID, input, live)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applyNamespace(ctx, dynamicClient, req.Environment, in, applyOpts); err != nil {
+		return nil, err
+	}
+
+	obj, err := getNamespace(ctx, dynamicClient, name)
+	if err != nil {
+		return nil, err
+	}
+
+	resource, err := namespaceResource(ctx, dynamicClient, obj, req.Resource.ExternalID, config.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	return &app.OperationResponse{Resource: resource}, nil
+}
+
+// namespaceUpdateInput returns the update input of a namespace, with the inputs left
+// out filled from the objects Tempest added to it, like updateInput does for
+// Applications. This way an update only removes the quota, limits, isolation or
+// RoleBindings whose inputs it explicitly clears.
+func namespaceUpdateInput(ctx context.Context, dc dynamic.Interface, input map[string]any, live *unstructured.Unstructured) (map[string]any, error) {
+	resource, err := namespaceResource(ctx, dc, live, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	// The inputs each object renders, by kind and name
+	inputs := map[string][]string{
+		"ResourceQuota/" + namespaceQuotaName:     {"quota_cpu", "quota_memory", "quota_storage", "quota_pods"},
+		"LimitRange/" + namespaceLimitsName:       {"default_cpu_request", "default_memory_request", "default_cpu_limit", "default_memory_limit"},
+		"NetworkPolicy/" + namespaceIsolationName: {"network_isolation"},
+	}
+	for _, role := range namespaceRoles {
+		inputs["RoleBinding/tempest-"+role] = []string{role + "_groups"}
+	}
+
+	// Only objects with the project label are carried over, so objects that were in
+	// the namespace before Tempest managed it are left alone. The labels are those
+	// of the Namespace, or of these objects when Tempest didn't create it.
+	current := map[string]any{"network_isolation": false}
+	if live.GetLabels()[projectLabel] != "" {
+		current["labels"] = toAnySlice(keyValueStrings(userLabels(live)))
+	}
+	applier := newNamespaceApplier(dc, live.GetName())
+	for _, ref := range namespaceObjects(live.GetName()) {
+		client, _, err := applier.ResourceClient(ref)
+		if err != nil {
+			return nil, err
+		}
+		obj, err := client.Get(ctx, ref.GetName(), metav1.GetOptions{})
+		switch {
+		case k8serrors.IsNotFound(err):
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("failed to get %s %s/%s: %w", ref.GetKind(), ref.GetNamespace(), ref.GetName(), err)
+		}
+		if obj.GetLabels()[projectLabel] == "" {
+			continue
+		}
+		for _, key := range inputs[ref.GetKind()+"/"+ref.GetName()] {
+			current[key] = resource.Properties[key]
+		}
+		if _, ok := current["labels"]; !ok {
+			current["labels"] = toAnySlice(keyValueStrings(userLabels(obj)))
+		}
+	}
+	// JSON numbers are decoded as float64, which namespaceFromInput expects
+	if pods, ok := current["quota_pods"].(int64); ok {
+		current["quota_pods"] = float64(pods)
+	}
+
+	merged := make(map[string]any, len(input)+len(current))
+	for k, v := range current {
+		merged[k] = v
+	}
+	for k, v := range input {
+		merged[k] = v
+	}
+	return merged, nil
+}
+
+// readNamespaceFn implements the READ operation for the namespace resource type.
+func readNamespaceFn(ctx context.Context, req *app.OperationRequest) (*app.OperationResponse, error) {
+	config, err := getConfigFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := newDynamicClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	_, name, err := parseExternalID(req.Resource.ExternalID)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := getNamespace(ctx, dynamicClient, name)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, fmt.Errorf("namespace %s does not exist", name)
+	}
+
+	var projectID string
+	if req.Metadata != nil {
+		projectID = req.Metadata.ProjectID
+	}
+	if err := checkNamespaceOwner(ctx, dynamicClient, obj, projectID); err != nil {
+		return nil, err
+	}
+
+	resource, err := namespaceResource(ctx, dynamicClient, obj, req.Resource.ExternalID, config.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	return &app.OperationResponse{Resource: resource}, nil
+}
+
+// deleteNamespaceFn implements the DELETE operation for the namespace resource type.
+// It refuses to delete a namespace that Applications still deploy to. Namespaces
+// created by Tempest are deleted along with everything in them; in namespaces that
+// existed before, only the objects Tempest added are deleted.
+func deleteNamespaceFn(ctx context.Context, req *app.OperationRequest) (*app.OperationResponse, error) {
+	config, err := getConfigFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := newDynamicClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	_, name, err := parseExternalID(req.Resource.ExternalID)
+	if err != nil {
+		return nil, err
+	}
+
+	projectID, err := projectIDFromMetadata(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := getNamespace(ctx, dynamicClient, name)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return &app.OperationResponse{Resource: &app.Resource{ExternalID: req.Resource.ExternalID}}, nil
+	}
+	if err := checkNamespaceOwner(ctx, dynamicClient, obj, projectID); err != nil {
+		return nil, err
+	}
+
+	apps, err := namespaceApplications(ctx, dynamicClient, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(apps) > 0 {
+		return nil, fmt.Errorf("namespace %s is still used by applications: %s", name, strings.Join(apps, ", "))
+	}
+
+	objs := namespaceObjects(name)
+	if obj.GetLabels()[projectLabel] == projectID {
+		objs = append(objs, obj)
+	}
+	if err := deleteOwnedObjects(ctx, newNamespaceApplier(dynamicClient, name), objs, projectID); err != nil {
+		return nil, fmt.Errorf("failed to delete namespace %s: %w", name, err)
+	}
+
+	return &app.OperationResponse{
+		Resource: &app.Resource{
+			ExternalID: req.Resource.ExternalID,
+		},
+	}, nil
+}
+
+// listNamespacesFn implements the LIST operation for the namespace resource type.
+// Like listFn, it finds the Namespaces labeled with the requesting Tempest project,
+// so namespaces that existed before Tempest managed them are not listed.
+func listNamespacesFn(ctx context.Context, req *app.ListRequest) (*app.ListResponse, error) {
+	config, err := getConfigFromEnv(processEnvironment())
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := newDynamicClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	projectID, err := projectIDFromMetadata(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := dynamicClient.Resource(namespaceGVR).List(ctx, metav1.ListOptions{
+		LabelSelector: projectLabel + "=" + projectID,
+		Limit:         listPageSize,
+		Continue:      req.Next,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	resources := make([]*app.Resource, 0, len(list.Items))
+	for i := range list.Items {
+		obj := &list.Items[i]
+		externalID := strings.Join([]string{obj.GetName(), obj.GetName(), string(obj.GetUID())}, "/")
+		resource, err := namespaceResource(ctx, dynamicClient, obj, externalID, config.Host)
+		if err != nil {
+			return nil, fmt.Errorf("namespace %s: %w", obj.GetName(), err)
+		}
+		resources = append(resources, resource)
+	}
+
+	return &app.ListResponse{
+		Resources: resources,
+		Next:      list.GetContinue(),
+	}, nil
+}
+
+// namespaceApplications returns the names of the Applications that deploy to a
+// namespace of the cluster ArgoCD runs in, which is the cluster namespaces are
+// provisioned in.
+func namespaceApplications(ctx context.Context, dc dynamic.Interface, name string) ([]string, error) {
+	list, err := dc.Resource(applicationGVR).Namespace("argocd").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applications of namespace %s: %w", name, err)
+	}
+
+	var names []string
+	for _, obj := range list.Items {
+		namespace, _, _ := unstructured.NestedString(obj.Object, "spec", "destination", "namespace")
+		if namespace != name {
+			continue
+		}
+		switch cluster := destinationCluster(&obj); cluster {
+		case "", inClusterName, "https://kubernetes.default.svc":
+			names = append(names, obj.GetName())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// namespaceResource builds the Tempest resource of a Namespace, reporting the
+// objects Tempest added to it.
+func namespaceResource(ctx context.Context, dc dynamic.Interface, obj *unstructured.Unstructured, externalID, host string) (*app.Resource, error) {
+	name := obj.GetName()
+	phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+
+	properties := map[string]any{
+		"name":               name,
+		"phase":              phase,
+		"created_by_tempest": obj.GetLabels()[projectLabel] != "",
+		"quota_pods":         int64(0),
+		"network_isolation":  false,
+		"cluster":            host,
+	}
+	for _, r := range namespaceQuotaInputs {
+		properties["quota_"+r.Property] = ""
+		properties["used_"+r.Property] = ""
+	}
+	for _, key := range []string{"default_cpu_request", "default_memory_request", "default_cpu_limit", "default_memory_limit"} {
+		properties[key] = ""
+	}
+	for _, role := range namespaceRoles {
+		properties[role+"_groups"] = []any{}
+	}
+
+	get := func(gvr schema.GroupVersionResource, objName string) (*unstructured.Unstructured, error) {
+		o, err := dc.Resource(gvr).Namespace(name).Get(ctx, objName, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s %s/%s: %w", gvr.Resource, name, objName, err)
+		}
+		return o, nil
+	}
+
+	quota, err := get(resourceQuotaGVR, namespaceQuotaName)
+	if err != nil {
+		return nil, err
+	}
+	if quota != nil {
+		hard, _, _ := unstructured.NestedStringMap(quota.Object, "spec", "hard")
+		used, _, _ := unstructured.NestedStringMap(quota.Object, "status", "used")
+		for _, r := range namespaceQuotaInputs {
+			properties["quota_"+r.Property] = hard[r.Quota]
+			properties["used_"+r.Property] = used[r.Quota]
+		}
+		if pods, err := resource.ParseQuantity(hard["pods"]); err == nil {
+			properties["quota_pods"] = pods.Value()
+		}
+	}
+
+	limits, err := get(limitRangeGVR, namespaceLimitsName)
+	if err != nil {
+		return nil, err
+	}
+	if limits != nil {
+		items, _, _ := unstructured.NestedSlice(limits.Object, "spec", "limits")
+		for _, item := range items {
+			m, ok := item.(map[string]any)
+			if !ok || m["type"] != "Container" {
+				continue
+			}
+			requests, _, _ := unstructured.NestedStringMap(m, "defaultRequest")
+			defaults, _, _ := unstructured.NestedStringMap(m, "default")
+			for _, r := range []string{"cpu", "memory"} {
+				properties["default_"+r+"_request"] = requests[r]
+				properties["default_"+r+"_limit"] = defaults[r]
+			}
+		}
+	}
+
+	isolation, err := get(networkPolicyGVR, namespaceIsolationName)
+	if err != nil {
+		return nil, err
+	}
+	properties["network_isolation"] = isolation != nil
+
+	for _, role := range namespaceRoles {
+		binding, err := get(roleBindingGVR, "tempest-"+role)
+		if err != nil {
+			return nil, err
+		}
+		if binding == nil {
+			continue
+		}
+		subjects, _, _ := unstructured.NestedSlice(binding.Object, "subjects")
+		groups := make([]string, 0, len(subjects))
+		for _, s := range subjects {
+			if m, ok := s.(map[string]any); ok && m["kind"] == "Group" {
+				groups = append(groups, fmt.Sprint(m["name"]))
+			}
+		}
+		properties[role+"_groups"] = toAnySlice(groups)
+	}
+
+	apps, err := namespaceApplications(ctx, dc, name)
+	if err != nil {
+		return nil, err
+	}
+	properties["applications"] = toAnySlice(apps)
+
+	for k, v := range metadataProperties(obj) {
+		properties[k] = v
+	}
+
+	return &app.Resource{
+		ExternalID:  externalID,
+		DisplayName: name,
+		Properties:  properties,
+	}, nil
+}
@@ -0,0 +1,382 @@
+package appargocd
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tempestdx/sdk-go/app"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestCreateNamespaceFn(t *testing.T) {
+	f := newFakeArgoCD(t)
+
+	res, err := createNamespaceFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Input: namespaceInput(map[string]any{
+			"quota_cpu":           "8",
+			"quota_pods":          float64(50),
+			"default_cpu_request": "100m",
+			"default_cpu_limit":   "1",
+			"edit_groups":         []any{"payments-developers"},
+		}),
+	})
+	if err != nil {
+		t.Fatalf("createNamespaceFn: %v", err)
+	}
+
+	namespace, name, err := parseExternalID(res.Resource.ExternalID)
+	if err != nil || namespace != "payments" || name != "payments" {
+		t.Errorf("ExternalID = %q, want payments/payments/<uid>", res.Resource.ExternalID)
+	}
+	for key, want := range map[string]any{
+		"name":                "payments",
+		"phase":               "Active",
+		"created_by_tempest":  true,
+		"quota_cpu":           "8",
+		"quota_memory":        "",
+		"quota_pods":          int64(50),
+		"default_cpu_request": "100m",
+		"default_cpu_limit":   "1",
+		"network_isolation":   true,
+		"project_id":          testProjectID,
+	} {
+		if got := res.Resource.Properties[key]; got != want {
+			t.Errorf("property %s = %v, want %v", key, got, want)
+		}
+	}
+	if got := res.Resource.Properties["edit_groups"].([]any); len(got) != 1 || got[0] != "payments-developers" {
+		t.Errorf("property edit_groups = %v, want [payments-developers]", got)
+	}
+
+	for gvr, name := range map[schema.GroupVersionResource]string{
+		resourceQuotaGVR: namespaceQuotaName,
+		limitRangeGVR:    namespaceLimitsName,
+		networkPolicyGVR: namespaceIsolationName,
+		roleBindingGVR:   "tempest-edit",
+	} {
+		obj := f.getIn(t, gvr, "payments", name)
+		if got := obj.GetLabels()[projectLabel]; got != testProjectID {
+			t.Errorf("%s %s label %s = %q, want %q", gvr.Resource, name, projectLabel, got, testProjectID)
+		}
+	}
+	if f.existsIn(roleBindingGVR, "payments", "tempest-admin") {
+		t.Error("RoleBinding tempest-admin was created without admin_groups")
+	}
+}
+
+func TestUpdateNamespaceFn(t *testing.T) {
+	f := newFakeArgoCD(t)
+
+	created, err := createNamespaceFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Input: namespaceInput(map[string]any{
+			"quota_memory": "16Gi",
+			"view_groups":  []any{"support"},
+		}),
+	})
+	if err != nil {
+		t.Fatalf("createNamespaceFn: %v", err)
+	}
+
+	// Clearing inputs removes the objects they rendered
+	res, err := updateNamespaceFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Resource:    created.Resource,
+		Input: map[string]any{
+			"quota_memory":      "",
+			"network_isolation": false,
+			"view_groups":       []any{},
+			"admin_groups":      []any{"payments-oncall"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("updateNamespaceFn: %v", err)
+	}
+
+	if res.Resource.ExternalID != created.Resource.ExternalID {
+		t.Errorf("ExternalID = %q, want it unchanged (%q)", res.Resource.ExternalID, created.Resource.ExternalID)
+	}
+	for gvr, name := range map[schema.GroupVersionResource]string{
+		resourceQuotaGVR: namespaceQuotaName,
+		networkPolicyGVR: namespaceIsolationName,
+		roleBindingGVR:   "tempest-view",
+	} {
+		if f.existsIn(gvr, "payments", name) {
+			t.Errorf("%s %s was not removed", gvr.Resource, name)
+		}
+	}
+	if !f.existsIn(roleBindingGVR, "payments", "tempest-admin") {
+		t.Error("RoleBinding tempest-admin was not created")
+	}
+	if got := res.Resource.Properties["quota_memory"]; got != "" {
+		t.Errorf("property quota_memory = %v, want empty", got)
+	}
+}
+
+func TestUpdateNamespaceFnKeepsOmittedInputs(t *testing.T) {
+	f := newFakeArgoCD(t)
+
+	created, err := createNamespaceFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Input: namespaceInput(map[string]any{
+			"quota_cpu":            "8",
+			"quota_pods":           float64(50),
+			"default_memory_limit": "512Mi",
+			"edit_groups":          []any{"payments-developers"},
+			"labels":               []any{"team=payments"},
+		}),
+	})
+	if err != nil {
+		t.Fatalf("createNamespaceFn: %v", err)
+	}
+
+	// Only the view groups are updated, every other input is left out
+	res, err := updateNamespaceFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Resource:    created.Resource,
+		Input:       map[string]any{"view_groups": []any{"support"}},
+	})
+	if err != nil {
+		t.Fatalf("updateNamespaceFn: %v", err)
+	}
+
+	for key, want := range map[string]any{
+		"quota_cpu":            "8",
+		"quota_pods":           int64(50),
+		"default_memory_limit": "512Mi",
+		"network_isolation":    true,
+	} {
+		if got := res.Resource.Properties[key]; got != want {
+			t.Errorf("property %s = %v, want %v", key, got, want)
+		}
+	}
+	for role, want := range map[string]string{"edit": "payments-developers", "view": "support"} {
+		if got := res.Resource.Properties[role+"_groups"].([]any); len(got) != 1 || got[0] != want {
+			t.Errorf("property %s_groups = %v, want [%s]", role, got, want)
+		}
+	}
+	for gvr, name := range map[schema.GroupVersionResource]string{
+		namespaceGVR:   "payments",
+		roleBindingGVR: "tempest-edit",
+	} {
+		namespace := "payments"
+		if gvr == namespaceGVR {
+			namespace = ""
+		}
+		if got := f.getIn(t, gvr, namespace, name).GetLabels()["team"]; got != "payments" {
+			t.Errorf("%s %s label team = %q, want payments", gvr.Resource, name, got)
+		}
+	}
+}
+
+func TestNamespaceExisting(t *testing.T) {
+	// The namespace and an object of its owners exist before Tempest manages it
+	quota := testNamespaced("v1", "ResourceQuota", "payments", namespaceQuotaName, nil)
+	f := newFakeArgoCD(t, testNamespaced("v1", "Namespace", "", "payments", nil), quota)
+
+	created, err := createNamespaceFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Input:       namespaceInput(map[string]any{"view_groups": []any{"support"}}),
+	})
+	if err != nil {
+		t.Fatalf("createNamespaceFn: %v", err)
+	}
+	if got := created.Resource.Properties["created_by_tempest"]; got != false {
+		t.Errorf("property created_by_tempest = %v, want false", got)
+	}
+	if got := f.getIn(t, namespaceGVR, "", "payments").GetLabels()[projectLabel]; got != "" {
+		t.Errorf("existing namespace was labeled with project %q", got)
+	}
+
+	// An update leaving the quota inputs out doesn't take over the existing quota
+	updated, err := updateNamespaceFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Resource:    created.Resource,
+		Input:       map[string]any{"labels": []any{"team=payments"}},
+	})
+	if err != nil {
+		t.Fatalf("updateNamespaceFn: %v", err)
+	}
+	if got := f.getIn(t, resourceQuotaGVR, "payments", namespaceQuotaName).GetLabels()[projectLabel]; got != "" {
+		t.Errorf("existing ResourceQuota was labeled with project %q", got)
+	}
+	if got := updated.Resource.Properties["view_groups"].([]any); len(got) != 1 || got[0] != "support" {
+		t.Errorf("property view_groups = %v, want [support]", got)
+	}
+
+	_, err = deleteNamespaceFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Resource:    created.Resource,
+	})
+	if err != nil {
+		t.Fatalf("deleteNamespaceFn: %v", err)
+	}
+
+	// Only the objects Tempest added are deleted
+	if f.existsIn(roleBindingGVR, "payments", "tempest-view") {
+		t.Error("RoleBinding tempest-view was not deleted")
+	}
+	if !f.existsIn(namespaceGVR, "", "payments") {
+		t.Error("existing namespace was deleted")
+	}
+	if !f.existsIn(resourceQuotaGVR, "payments", namespaceQuotaName) {
+		t.Error("ResourceQuota not created by Tempest was deleted")
+	}
+}
+
+func TestNamespaceOtherProject(t *testing.T) {
+	f := newFakeArgoCD(t, testNamespaced("v1", "Namespace", "", "billing", map[string]any{projectLabel: "proj-2"}))
+
+	_, err := createNamespaceFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Input:       namespaceInput(map[string]any{"name": "billing"}),
+	})
+	if err == nil || !strings.Contains(err.Error(), "managed by another Tempest project") {
+		t.Fatalf("createNamespaceFn error = %v, want managed by another Tempest project", err)
+	}
+
+	_, err = readNamespaceFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Resource:    &app.Resource{ExternalID: "billing/billing/uid-1"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "managed by another Tempest project") {
+		t.Errorf("readNamespaceFn error = %v, want managed by another Tempest project", err)
+	}
+}
+
+func TestDeleteNamespaceFn(t *testing.T) {
+	f := newFakeArgoCD(t)
+
+	created, err := createNamespaceFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Input:       namespaceInput(map[string]any{"quota_cpu": "4"}),
+	})
+	if err != nil {
+		t.Fatalf("createNamespaceFn: %v", err)
+	}
+	if _, err := createFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Input:       applicationInput(map[string]any{"namespace": "payments"}),
+	}); err != nil {
+		t.Fatalf("createFn: %v", err)
+	}
+
+	// Namespaces aren't deleted while Applications deploy to them
+	_, err = deleteNamespaceFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Resource:    created.Resource,
+	})
+	if err == nil || !strings.Contains(err.Error(), "still used by applications: guestbook") {
+		t.Fatalf("deleteNamespaceFn error = %v, want still used by applications: guestbook", err)
+	}
+
+	if err := f.client.Tracker().Delete(applicationGVR, "argocd", "guestbook"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := deleteNamespaceFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Resource:    created.Resource,
+	}); err != nil {
+		t.Fatalf("deleteNamespaceFn: %v", err)
+	}
+	if f.existsIn(namespaceGVR, "", "payments") {
+		t.Error("namespace created by Tempest was not deleted")
+	}
+}
+
+func TestNamespaceFromInput(t *testing.T) {
+	tests := []struct {
+		name  string
+		input map[string]any
+		want  string
+	}{
+		{
+			name:  "reserved namespace",
+			input: namespaceInput(map[string]any{"name": "kube-system"}),
+			want:  "reserved",
+		},
+		{
+			name:  "invalid name",
+			input: namespaceInput(map[string]any{"name": "Payments"}),
+			want:  "invalid namespace name",
+		},
+		{
+			name:  "invalid quantity",
+			input: namespaceInput(map[string]any{"quota_memory": "16 gigabytes"}),
+			want:  "invalid quota_memory",
+		},
+		{
+			name:  "request above limit",
+			input: namespaceInput(map[string]any{"default_memory_request": "1Gi", "default_memory_limit": "512Mi"}),
+			want:  "default_memory_request 1Gi is more than default_memory_limit 512Mi",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := namespaceFromInput(tt.input["name"].(string), testProjectID, tt.input, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("namespaceFromInput error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+
+	in, err := namespaceFromInput("payments", testProjectID, namespaceInput(map[string]any{"admin_groups": []any{"a"}, "view_groups": []any{"b"}}), nil)
+	if err != nil {
+		t.Fatalf("namespaceFromInput: %v", err)
+	}
+	var names []string
+	for _, rb := range in.RoleBindings {
+		names = append(names, rb.Name)
+	}
+	if !slices.Equal(names, []string{"tempest-admin", "tempest-view"}) {
+		t.Errorf("RoleBindings = %v, want tempest-admin and tempest-view", names)
+	}
+}
+
+// namespaceInput returns namespace create input with the defaults of
+// namespace_create.json applied, overridden by overrides.
+func namespaceInput(overrides map[string]any) map[string]any {
+	input := map[string]any{
+		"name":              "payments",
+		"quota_pods":        float64(0),
+		"network_isolation": true,
+	}
+	for k, v := range overrides {
+		input[k] = v
+	}
+	return input
+}
+
+// testNamespaced returns an object of a built-in kind, with labels if not nil.
+func testNamespaced(apiVersion, kind, namespace, name string, labels map[string]any) runtime.Object {
+	metadata := map[string]any{"name": name}
+	if namespace != "" {
+		metadata["namespace"] = namespace
+	}
+	if labels != nil {
+		metadata["labels"] = labels
+	}
+	obj := map[string]any{"apiVersion": apiVersion, "kind": kind, "metadata": metadata}
+	if kind == "Namespace" {
+		obj["status"] = map[string]any{"phase": "Active"}
+	}
+	return &unstructured.Unstructured{Object: obj}
+}
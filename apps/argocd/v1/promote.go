@@ -0,0 +1,284 @@
+package appargocd
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tempestdx/sdk-go/app"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// promotionsAnnotation records the promotions of an Application, newest first,
+// as a JSON array of Promotion, reported as the promotions and promoted_* properties.
+const promotionsAnnotation = "tempest.dev/promotions"
+
+// promotionHistoryLimit is how many promotions an Application remembers, like the
+// deployments ArgoCD keeps by default.
+const promotionHistoryLimit = 10
+
+// Promotion is a promotion of images to an Application from another one.
+type Promotion struct {
+	From     string   `json:"from"`     // ExternalID of the source Application
+	Images   []string `json:"images"`   // Images copied from the source
+	Revision string   `json:"revision"` // Revision the source was synced to
+	At       string   `json:"at"`       // Time of the promotion, in RFC 3339 format
+}
+
+var (
+	// Embed the JSON schema of the promote action
+	//go:embed schema/promote.json
+	promoteSchema []byte
+)
+
+// promoteAction copies the images deployed by a source Application, e.g. in
+// staging, to the Application the action runs against, e.g. in production, and
+// syncs it. The source must be Synced and Healthy, so only images that were
+// rolled out successfully are promoted. Each Application keeps its own
+// source_path, which is typically the overlay of its environment.
+func promoteAction(ctx context.Context, req *app.ActionRequest) (*app.ActionResponse, error) {
+	syncTimeout, err := getSyncTimeoutFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	// Promotions are applied like updates, and handle conflicts the same way
+	applyOpts, err := getApplyOptionsFromEnv(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, name, err := actionClient(req)
+	if err != nil {
+		return nil, err
+	}
+	namespace, _, err := parseExternalID(req.Resource.ExternalID)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceExternalID := stringInput(req.Input, "source_external_id")
+	_, sourceName, err := parseExternalID(sourceExternalID)
+	if err != nil {
+		return nil, fmt.Errorf("source_external_id: %w", err)
+	}
+	if sourceName == name {
+		return nil, errors.New("an application can't be promoted from itself")
+	}
+
+	projectID, err := projectIDFromMetadata(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := getApplication(ctx, dynamicClient, sourceName)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkProjectOwner("application", source, projectID); err != nil {
+		return nil, err
+	}
+	if s := parseApplicationStatus(source); s.Pending || s.Sync != "Synced" || s.Health != "Healthy" {
+		return nil, newSyncError("argocd", sourceName, "must be Synced and Healthy to be promoted", s)
+	}
+
+	target, err := getIdleApplication(ctx, dynamicClient, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkProjectOwner("application", target, projectID); err != nil {
+		return nil, err
+	}
+
+	images, promoted, err := promotedImages(source, target)
+	if err != nil {
+		return nil, err
+	}
+
+	promotions, err := recordPromotion(target, Promotion{
+		From:     sourceExternalID,
+		Images:   imageReferences(promoted),
+		Revision: parseApplicationStatus(source).Revision,
+		At:       time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The images are applied like an update setting the images input, so they are
+	// owned by the same field manager as the rest of the Application, and updates
+	// that leave image and images out keep them
+	input, err := promotionInput(target, images)
+	if err != nil {
+		return nil, err
+	}
+	in, err := updateTemplateInput(namespace, name, projectID, input)
+	if err != nil {
+		return nil, err
+	}
+	keepTempestAnnotations(in.Annotations, target)
+	in.Annotations[promotionsAnnotation] = promotions
+
+	manifest, err := renderTemplate(req.Environment, "application.yaml.tmpl", in)
+	if err != nil {
+		return nil, err
+	}
+
+	// The promoted images are checked like any other update, so the CRD schema
+	// and the restrictions of the AppProject apply to them too
+	obj, err := decodeManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateApplication(ctx, dynamicClient, obj); err != nil {
+		return nil, err
+	}
+	if _, err := apply(ctx, dynamicClient, manifest, applyOpts); err != nil {
+		return nil, fmt.Errorf("failed to promote application %s: %w", name, err)
+	}
+
+	// Sync explicitly rather than waiting for automated sync, which also
+	// deploys the images to manually synced Applications
+	sync := map[string]any{
+		"prune": boolInput(req.Input, "prune"),
+	}
+	if err := startOperation(ctx, dynamicClient, name, initiatorFromMetadata(req.Metadata), sync); err != nil {
+		return nil, err
+	}
+
+	return waitForOperation(ctx, dynamicClient, target, syncTimeout)
+}
+
+// promotedImages returns the Kustomize images of target once the images of source
+// are promoted to it, along with the promoted images. Images of source replace the
+// images of target with the same name, and other images of target are kept. The
+// image named after the source Application, set by its "image" input, is renamed
+// after the target Application, since that's the name the target's "image" input uses.
+func promotedImages(source, target *unstructured.Unstructured) (images, promoted []ImageOverride, err error) {
+	sourceSources, err := promotableSources(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	targetSources, err := promotableSources(target)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	promoted = sourceImageOverrides(sourceSources)
+	if len(promoted) == 0 {
+		return nil, nil, fmt.Errorf("application %s has no Kustomize images to promote", source.GetName())
+	}
+
+	images = sourceImageOverrides(targetSources)
+	for _, o := range promoted {
+		if o.Name == source.GetName() {
+			o.Name = target.GetName()
+		}
+
+		replaced := false
+		for i := range images {
+			if images[i].Name == o.Name {
+				images[i] = o
+				replaced = true
+			}
+		}
+		if !replaced {
+			images = append(images, o)
+		}
+	}
+	return images, promoted, nil
+}
+
+// promotableSources returns the source of an Application that images can be promoted
+// from or to, which must be its only source and deploy with Kustomize.
+func promotableSources(obj *unstructured.Unstructured) ([]ApplicationSource, error) {
+	sources, err := sourcesFromApplication(obj)
+	if err != nil {
+		return nil, err
+	}
+	if len(sources) != 1 {
+		return nil, fmt.Errorf("application %s has multiple sources; only Kustomize applications can be promoted", obj.GetName())
+	}
+	if s := sources[0]; s.Chart != "" || s.Helm != nil || s.Directory != nil {
+		return nil, fmt.Errorf("application %s doesn't use Kustomize; only Kustomize applications can be promoted", obj.GetName())
+	}
+	return sources, nil
+}
+
+// imageReferences returns the images that overrides deploy.
+func imageReferences(overrides []ImageOverride) []string {
+	out := make([]string, 0, len(overrides))
+	for _, o := range overrides {
+		out = append(out, o.Reference())
+	}
+	return out
+}
+
+// promotionInput returns the update input that applies images to the live Application
+// obj, keeping its other inputs, labels and annotations.
+func promotionInput(obj *unstructured.Unstructured, images []ImageOverride) (map[string]any, error) {
+	return updateInput(map[string]any{"images": toAnySlice(imageStrings(images))}, obj)
+}
+
+// recordPromotion returns the promotions annotation of obj with p added, keeping
+// the last promotionHistoryLimit promotions.
+func recordPromotion(obj *unstructured.Unstructured, p Promotion) (string, error) {
+	promotions := append([]Promotion{p}, promotionsFromApplication(obj)...)
+	if len(promotions) > promotionHistoryLimit {
+		promotions = promotions[:promotionHistoryLimit]
+	}
+
+	data, err := json.Marshal(promotions)
+	if err != nil {
+		return "", fmt.Errorf("failed to record promotion: %w", err)
+	}
+	return string(data), nil
+}
+
+// promotionsFromApplication returns the promotions recorded on an Application, newest
+// first. An annotation that can't be parsed was not written by this app, and is ignored.
+func promotionsFromApplication(obj *unstructured.Unstructured) []Promotion {
+	var promotions []Promotion
+	if s := obj.GetAnnotations()[promotionsAnnotation]; s != "" {
+		if err := json.Unmarshal([]byte(s), &promotions); err != nil {
+			return nil
+		}
+	}
+	return promotions
+}
+
+// promotionProperties reports the promotions of an Application, matching the promotions
+// and promoted_* properties of properties.json. Each promotion is flattened into a string
+// of key=value pairs, like deployments, and promoted_* describe the last one. They are
+// empty if it was never promoted:
+//
+//	from=staging/guestbook-staging/uid-1 revision=9f2c1e4 promoted_at=2026-10-18T12:00:00Z images=guestbook:1.1.0
+func promotionProperties(obj *unstructured.Unstructured) map[string]any {
+	promotions := promotionsFromApplication(obj)
+
+	formatted := make([]string, 0, len(promotions))
+	for _, p := range promotions {
+		formatted = append(formatted, strings.Join([]string{
+			"from=" + p.From,
+			"revision=" + p.Revision,
+			"promoted_at=" + p.At,
+			"images=" + strings.Join(p.Images, ","),
+		}, " "))
+	}
+
+	var last Promotion
+	if len(promotions) > 0 {
+		last = promotions[0]
+	}
+	return map[string]any{
+		"promotions":        toAnySlice(formatted),
+		"promoted_from":     last.From,
+		"promoted_images":   toAnySlice(last.Images),
+		"promoted_revision": last.Revision,
+		"promoted_at":       last.At,
+	}
+}
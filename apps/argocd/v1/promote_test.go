@@ -0,0 +1,314 @@
+package appargocd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tempestdx/sdk-go/app"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestPromoteAction(t *testing.T) {
+	f := newFakeArgoCD(t)
+
+	source := createTestApplication(t, f, map[string]any{
+		"name":      "guestbook-staging",
+		"namespace": "staging",
+		"image":     "registry.example.com/guestbook:1.1.0",
+	})
+	target := createTestApplication(t, f, map[string]any{
+		"image":  "registry.example.com/guestbook:1.0.0",
+		"images": []any{"redis=redis:7.2"},
+	})
+
+	promote := func(source *app.Resource) *app.ActionResponse {
+		t.Helper()
+		res, err := promoteAction(t.Context(), &app.ActionRequest{
+			Metadata:    testMetadata(),
+			Environment: f.env(),
+			Resource:    target,
+			Input:       map[string]any{"source_external_id": source.ExternalID, "prune": false},
+		})
+		if err != nil {
+			t.Fatalf("promoteAction: %v", err)
+		}
+		return res
+	}
+
+	res := promote(source)
+	if got := res.Output["health_status"]; got != "Healthy" {
+		t.Errorf("output health_status = %v, want Healthy", got)
+	}
+
+	// The image named after the staging Application replaces the one named after
+	// the target, and other images are kept
+	obj := f.get(t, applicationGVR, "guestbook")
+	images, _, _ := unstructured.NestedStringSlice(obj.Object, "spec", "source", "kustomize", "images")
+	want := []string{"guestbook=registry.example.com/guestbook:1.1.0", "redis=redis:7.2"}
+	if !slices.Equal(images, want) {
+		t.Errorf("spec.source.kustomize.images = %v, want %v", images, want)
+	}
+	if path, _, _ := unstructured.NestedString(obj.Object, "spec", "source", "path"); path != "applications/guestbook" {
+		t.Errorf("spec.source.path = %q, want it unchanged", path)
+	}
+	if _, ok := obj.GetAnnotations()[deployedAtAnnotation]; !ok {
+		t.Error("promotion dropped the deployed-at annotation of the last update")
+	}
+
+	// Promotions are applied by the same field manager as create and update, so a
+	// forced update can't take the promoted images back. Only the sync operation
+	// is started with a merge patch
+	for _, action := range f.client.Actions() {
+		patch, ok := action.(clienttesting.PatchActionImpl)
+		if !ok {
+			continue
+		}
+		if patch.GetPatchType() == types.ApplyPatchType {
+			if patch.PatchOptions.FieldManager != "tempest" {
+				t.Errorf("%s %s applied by %q, want tempest", patch.GetResource().Resource, patch.GetName(), patch.PatchOptions.FieldManager)
+			}
+			continue
+		}
+		var fields map[string]any
+		if err := json.Unmarshal(patch.GetPatch(), &fields); err != nil || len(fields) != 1 || fields["operation"] == nil {
+			t.Errorf("%s %s patched with %s", patch.GetResource().Resource, patch.GetName(), patch.GetPatch())
+		}
+	}
+
+	// Promote again from a canary
+	canary := createTestApplication(t, f, map[string]any{
+		"name":      "guestbook-canary",
+		"namespace": "canary",
+		"image":     "registry.example.com/guestbook:1.2.0",
+	})
+	promote(canary)
+
+	// An update leaving the images out keeps the promoted ones, and the promotions
+	env := f.env()
+	env["ARGOCD_CONFLICT_STRATEGY"] = app.EnvironmentVariable{Key: "ARGOCD_CONFLICT_STRATEGY", Value: "force"}
+	if _, err := updateFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: env,
+		Resource:    target,
+		Input:       map[string]any{"target_revision": "main"},
+	}); err != nil {
+		t.Fatalf("updateFn: %v", err)
+	}
+
+	read, err := readFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Resource:    target,
+	})
+	if err != nil {
+		t.Fatalf("readFn: %v", err)
+	}
+	for key, want := range map[string]any{
+		"image":             "registry.example.com/guestbook:1.2.0",
+		"promoted_from":     canary.ExternalID,
+		"promoted_revision": "HEAD",
+	} {
+		if got := read.Resource.Properties[key]; got != want {
+			t.Errorf("property %s = %v, want %v", key, got, want)
+		}
+	}
+	if got := read.Resource.Properties["promoted_images"].([]any); len(got) != 1 || got[0] != "registry.example.com/guestbook:1.2.0" {
+		t.Errorf("property promoted_images = %v, want [registry.example.com/guestbook:1.2.0]", got)
+	}
+	if got := read.Resource.Properties["promoted_at"]; got == "" {
+		t.Error("property promoted_at is empty")
+	}
+
+	// Newest first
+	promotions := read.Resource.Properties["promotions"].([]any)
+	wantPromotions := []string{
+		"from=" + canary.ExternalID + " revision=HEAD promoted_at=* images=registry.example.com/guestbook:1.2.0",
+		"from=" + source.ExternalID + " revision=HEAD promoted_at=* images=registry.example.com/guestbook:1.1.0",
+	}
+	if len(promotions) != len(wantPromotions) {
+		t.Fatalf("property promotions = %v, want %d promotions", promotions, len(wantPromotions))
+	}
+	for i, p := range promotions {
+		prefix, suffix, _ := strings.Cut(wantPromotions[i], "*")
+		if s := p.(string); !strings.HasPrefix(s, prefix) || !strings.HasSuffix(s, suffix) || len(s) <= len(wantPromotions[i]) {
+			t.Errorf("promotions[%d] = %q, want %q", i, s, wantPromotions[i])
+		}
+	}
+}
+
+func TestRecordPromotion(t *testing.T) {
+	obj := &unstructured.Unstructured{}
+	for i := range promotionHistoryLimit + 2 {
+		promotions, err := recordPromotion(obj, Promotion{From: fmt.Sprintf("staging/guestbook/uid-%d", i)})
+		if err != nil {
+			t.Fatalf("recordPromotion: %v", err)
+		}
+		obj.SetAnnotations(map[string]string{promotionsAnnotation: promotions})
+	}
+
+	promotions := promotionsFromApplication(obj)
+	if len(promotions) != promotionHistoryLimit || promotions[0].From != "staging/guestbook/uid-11" {
+		t.Errorf("promotions = %v, want the last %d, newest first", promotions, promotionHistoryLimit)
+	}
+}
+
+func TestPromoteActionRefused(t *testing.T) {
+	f := newFakeArgoCD(t, testApplication("billing", "proj-2"))
+
+	source := createTestApplication(t, f, map[string]any{
+		"name":  "guestbook-staging",
+		"image": "registry.example.com/guestbook:1.1.0",
+	})
+	target := createTestApplication(t, f, map[string]any{"image": "registry.example.com/guestbook:1.0.0"})
+	chart := createTestApplication(t, f, map[string]any{
+		"name":          "redis",
+		"source_type":   "helm",
+		"repo_url":      "https://charts.bitnami.com/bitnami",
+		"chart":         "redis",
+		"chart_version": "19.0.0",
+	})
+
+	// A failed rollout leaves the canary Degraded
+	failing := createTestApplication(t, f, map[string]any{"name": "guestbook-canary", "image": "registry.example.com/guestbook:2.0.0"})
+	f.setHealth("guestbook-canary", "Degraded")
+	if _, err := syncAction(t.Context(), &app.ActionRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Resource:    failing,
+		Input:       map[string]any{"prune": false},
+	}); err == nil {
+		t.Fatal("syncAction of a Degraded Application succeeded")
+	}
+
+	tests := []struct {
+		name   string
+		target *app.Resource
+		source string
+		want   string
+	}{
+		{
+			name:   "unhealthy source",
+			target: target,
+			source: failing.ExternalID,
+			want:   "must be Synced and Healthy to be promoted",
+		},
+		{
+			name:   "source of another project",
+			target: target,
+			source: "default/billing/uid-1",
+			want:   "managed by another Tempest project",
+		},
+		{
+			name:   "helm target",
+			target: chart,
+			source: source.ExternalID,
+			want:   "doesn't use Kustomize",
+		},
+		{
+			name:   "target of another project",
+			target: &app.Resource{ExternalID: "default/billing/uid-1"},
+			source: source.ExternalID,
+			want:   "managed by another Tempest project",
+		},
+		{
+			name:   "itself",
+			target: target,
+			source: target.ExternalID,
+			want:   "can't be promoted from itself",
+		},
+		{
+			name:   "invalid source",
+			target: target,
+			source: "guestbook-staging",
+			want:   "invalid external ID",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := promoteAction(t.Context(), &app.ActionRequest{
+				Metadata:    testMetadata(),
+				Environment: f.env(),
+				Resource:    tt.target,
+				Input:       map[string]any{"source_external_id": tt.source, "prune": false},
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("promoteAction error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+
+	// Refused promotions leave the target alone
+	obj := f.get(t, applicationGVR, "guestbook")
+	if _, promoted := obj.GetAnnotations()[promotionsAnnotation]; promoted {
+		t.Error("refused promotion was recorded")
+	}
+
+	var syncErr *SyncError
+	_, err := promoteAction(t.Context(), &app.ActionRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Resource:    target,
+		Input:       map[string]any{"source_external_id": failing.ExternalID, "prune": false},
+	})
+	if !errors.As(err, &syncErr) || syncErr.Health != "Degraded" {
+		t.Errorf("promoteAction error = %v, want a SyncError for the Degraded source", err)
+	}
+}
+
+func TestPromoteActionValidated(t *testing.T) {
+	f := newFakeArgoCD(t, testAppProject("payments",
+		[]any{"https://github.com/tempestdx/*"},
+		[]any{map[string]any{"server": "https://kubernetes.default.svc", "namespace": "*"}},
+	))
+	source := createTestApplication(t, f, map[string]any{
+		"name":  "guestbook-staging",
+		"image": "registry.example.com/guestbook:1.1.0",
+	})
+	target := createTestApplication(t, f, map[string]any{
+		"argocd_project": "payments",
+		"image":          "registry.example.com/guestbook:1.0.0",
+	})
+
+	// The AppProject stops permitting the target's repository
+	project := f.getIn(t, appProjectGVR, "argocd", "payments")
+	_ = unstructured.SetNestedStringSlice(project.Object, []string{"https://github.com/other/*"}, "spec", "sourceRepos")
+	if err := f.client.Tracker().Update(appProjectGVR, project, "argocd"); err != nil {
+		t.Fatalf("update AppProject: %v", err)
+	}
+
+	_, err := promoteAction(t.Context(), &app.ActionRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Resource:    target,
+		Input:       map[string]any{"source_external_id": source.ExternalID, "prune": false},
+	})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("promoteAction error = %v, want a ValidationError", err)
+	}
+	images, _, _ := unstructured.NestedStringSlice(f.get(t, applicationGVR, "guestbook").Object, "spec", "source", "kustomize", "images")
+	if !slices.Equal(images, []string{"guestbook=registry.example.com/guestbook:1.0.0"}) {
+		t.Errorf("spec.source.kustomize.images = %v, want the refused promotion not applied", images)
+	}
+}
+
+// createTestApplication creates an Application from applicationInput(overrides),
+// failing the test if it doesn't become Synced and Healthy.
+func createTestApplication(t *testing.T, f *fakeArgoCD, overrides map[string]any) *app.Resource {
+	t.Helper()
+	res, err := createFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Input:       applicationInput(overrides),
+	})
+	if err != nil {
+		t.Fatalf("createFn: %v", err)
+	}
+	return res.Resource
+}
@@ -0,0 +1,177 @@
+package appargocd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/tempestdx/sdk-go/app"
+)
+
+// templateFuncs are the helper functions available to manifest templates, including
+// templates from ARGOCD_TEMPLATES_DIR. Their names follow Helm's, so they look
+// familiar to anyone who has written a chart.
+var templateFuncs = template.FuncMap{
+	"quote":    quoteYAML,
+	"toYaml":   toYAML,
+	"b64enc":   toBase64,
+	"default":  defaultValue,
+	"required": requiredValue,
+	"nindent":  nindent,
+	"join":     join,
+}
+
+// templateCache holds parsed templates, so they are only parsed once rather than on
+// every operation. Templates from ARGOCD_TEMPLATES_DIR are parsed again when their
+// file changes, so updated templates are picked up without restarting the app.
+var templateCache = struct {
+	sync.Mutex
+	entries map[string]cachedTemplate // Keyed by directory and template name, the directory is empty for embedded templates
+}{entries: map[string]cachedTemplate{}}
+
+type cachedTemplate struct {
+	tmpl    *template.Template
+	modTime time.Time // Modification time of the override file, zero for embedded templates
+}
+
+// loadTemplate returns the parsed template called name. When ARGOCD_TEMPLATES_DIR
+// is set and contains a file called name, that file replaces the embedded template.
+// Templates that are not overridden fall back to the embedded ones.
+func loadTemplate(env map[string]app.EnvironmentVariable, name string) (*template.Template, error) {
+	dir := envValue(env, "ARGOCD_TEMPLATES_DIR")
+
+	var modTime time.Time
+	if dir != "" {
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("invalid ARGOCD_TEMPLATES_DIR %q: not a directory", dir)
+		}
+
+		info, err = os.Stat(filepath.Join(dir, name))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			dir = ""
+		case err != nil:
+			return nil, fmt.Errorf("failed to read template %s: %w", name, err)
+		default:
+			modTime = info.ModTime()
+		}
+	}
+
+	key := dir + "/" + name
+	templateCache.Lock()
+	defer templateCache.Unlock()
+
+	if cached, ok := templateCache.entries[key]; ok && cached.modTime.Equal(modTime) {
+		return cached.tmpl, nil
+	}
+
+	var fsys fs.FS = os.DirFS(dir)
+	if dir == "" {
+		var err error
+		if fsys, err = fs.Sub(templatesFS, "templates"); err != nil {
+			return nil, err
+		}
+	}
+
+	tmpl, err := template.New(name).Funcs(templateFuncs).ParseFS(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+	}
+
+	templateCache.entries[key] = cachedTemplate{tmpl: tmpl, modTime: modTime}
+	return tmpl, nil
+}
+
+// renderTemplate executes a template loaded with loadTemplate.
+func renderTemplate(env map[string]app.EnvironmentVariable, name string, data any) ([]byte, error) {
+	tmpl, err := loadTemplate(env, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return nil, fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+	return b.Bytes(), nil
+}
+
+// quoteYAML renders a value as a double-quoted YAML string, so values containing
+// characters such as ':' or '#' can't break the manifest.
+// JSON strings are valid YAML double-quoted strings.
+func quoteYAML(v any) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		s = fmt.Sprint(v)
+	}
+
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// toYAML renders a value as a YAML document, without the trailing newline.
+// Use it with nindent to embed the document in a manifest.
+func toYAML(v any) (string, error) {
+	s, err := encodeYAML(v)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(s, "\n"), nil
+}
+
+// defaultValue returns v, or def if v is empty, e.g. {{ .Project | default "default" }}.
+func defaultValue(def, v any) any {
+	if isEmptyValue(v) {
+		return def
+	}
+	return v
+}
+
+// requiredValue returns v, or fails rendering with msg if v is empty,
+// e.g. {{ required "name is required" .Name }}.
+func requiredValue(msg string, v any) (any, error) {
+	if isEmptyValue(v) {
+		return nil, errors.New(msg)
+	}
+	return v, nil
+}
+
+// nindent indents every line of s by n spaces and starts it on a new line.
+func nindent(n int, s string) string {
+	pad := strings.Repeat(" ", n)
+	return "\n" + pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+// join joins a list of strings with sep, e.g. {{ join "," .Namespaces }}.
+func join(sep string, items []string) string {
+	return strings.Join(items, sep)
+}
+
+// isEmptyValue reports whether v is nil, a zero value, or an empty slice or map.
+func isEmptyValue(v any) bool {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return true
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map:
+		return rv.Len() == 0
+	default:
+		return rv.IsZero()
+	}
+}
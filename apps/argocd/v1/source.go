@@ -0,0 +1,355 @@
+package appargocd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Source types accepted by the "source_type" input.
+const (
+	sourceTypeKustomize   = "kustomize"
+	sourceTypeHelm        = "helm"
+	sourceTypeDirectory   = "directory"
+	sourceTypeMultiSource = "multi_source"
+)
+
+// ApplicationSource describes where ArgoCD gets an Application's manifests from.
+// The JSON tags match the ArgoCD Application spec so the same struct is used to
+// render templates, parse the "sources" input and read back spec.source(s).
+// See: https://argo-cd.readthedocs.io/en/stable/user-guide/application-specification/
+type ApplicationSource struct {
+	RepoURL        string           `json:"repoURL"`                  // Git or Helm repository URL
+	Path           string           `json:"path,omitempty"`           // Directory within a Git repository
+	Chart          string           `json:"chart,omitempty"`          // Chart name within a Helm repository
+	TargetRevision string           `json:"targetRevision,omitempty"` // Git revision, or chart version for Helm repositories
+	Ref            string           `json:"ref,omitempty"`            // Name other sources use to reference this one ($ref/values.yaml)
+	Kustomize      *KustomizeSource `json:"kustomize,omitempty"`
+	Helm           *HelmSource      `json:"helm,omitempty"`
+	Directory      *DirectorySource `json:"directory,omitempty"`
+}
+
+// KustomizeSource holds Kustomize specific options.
+type KustomizeSource struct {
+	Images []string `json:"images,omitempty"` // Image overrides in Kustomize syntax
+}
+
+// HelmSource holds Helm specific options.
+type HelmSource struct {
+	ReleaseName string          `json:"releaseName,omitempty"`
+	ValueFiles  []string        `json:"valueFiles,omitempty"`
+	Values      string          `json:"values,omitempty"` // Inline values.yaml content
+	Parameters  []HelmParameter `json:"parameters,omitempty"`
+}
+
+// HelmParameter overrides a single Helm value, like --set name=value.
+type HelmParameter struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// DirectorySource holds options for plain directories of manifests.
+type DirectorySource struct {
+	Recurse bool   `json:"recurse,omitempty"`
+	Include string `json:"include,omitempty"` // Glob of files to include
+	Exclude string `json:"exclude,omitempty"` // Glob of files to exclude
+}
+
+// sourcesFromInput builds the Application's sources from create or update input.
+// name is the Application name, used as the Kustomize image name.
+func sourcesFromInput(name string, input map[string]any) ([]ApplicationSource, error) {
+	sourceType := stringInput(input, "source_type")
+	if sourceType == "" {
+		sourceType = sourceTypeKustomize
+	}
+
+	if sourceType == sourceTypeMultiSource {
+		return parseSources(stringInput(input, "sources"))
+	}
+
+	source := ApplicationSource{
+		RepoURL:        stringInput(input, "repo_url"),
+		Path:           stringInput(input, "source_path"),
+		TargetRevision: stringInput(input, "target_revision"),
+	}
+	if source.RepoURL == "" {
+		return nil, errors.New("repo_url is required")
+	}
+
+	switch sourceType {
+	case sourceTypeKustomize:
+		if source.Path == "" {
+			return nil, errors.New("source_path is required for kustomize sources")
+		}
+		// Image overrides are stored using Kustomize syntax: "name=newImage"
+		overrides, err := imageOverridesFromInput(name, input)
+		if err != nil {
+			return nil, err
+		}
+		source.Kustomize = &KustomizeSource{Images: imageStrings(overrides)}
+	case sourceTypeHelm:
+		helm, err := helmFromInput(input)
+		if err != nil {
+			return nil, err
+		}
+		source.Helm = helm
+
+		// A chart is pulled from a Helm repository, where the target revision is the chart version.
+		// Without a chart, the Helm chart lives at source_path in a Git repository.
+		if chart := stringInput(input, "chart"); chart != "" {
+			source.Chart = chart
+			source.Path = ""
+			source.TargetRevision = stringInput(input, "chart_version")
+			if source.TargetRevision == "" {
+				return nil, errors.New("chart_version is required when chart is set")
+			}
+		} else if source.Path == "" {
+			return nil, errors.New("source_path or chart is required for helm sources")
+		}
+	case sourceTypeDirectory:
+		if source.Path == "" {
+			return nil, errors.New("source_path is required for directory sources")
+		}
+		source.Directory = &DirectorySource{
+			Recurse: boolInput(input, "directory_recurse"),
+			Include: stringInput(input, "directory_include"),
+			Exclude: stringInput(input, "directory_exclude"),
+		}
+	default:
+		return nil, fmt.Errorf("unsupported source_type %q", sourceType)
+	}
+
+	return []ApplicationSource{source}, nil
+}
+
+// helmFromInput reads the helm_* inputs.
+func helmFromInput(input map[string]any) (*HelmSource, error) {
+	helm := &HelmSource{
+		ReleaseName: stringInput(input, "helm_release_name"),
+		ValueFiles:  stringSliceInput(input, "helm_value_files"),
+		Values:      stringInput(input, "helm_values"),
+	}
+
+	for _, p := range stringSliceInput(input, "helm_parameters") {
+		name, value, ok := strings.Cut(p, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid helm parameter %q: expected name=value", p)
+		}
+		helm.Parameters = append(helm.Parameters, HelmParameter{Name: name, Value: value})
+	}
+
+	return helm, nil
+}
+
+// parseSources decodes the "sources" input, a YAML list of ArgoCD sources
+// using the same fields as spec.sources in an Application manifest.
+func parseSources(in string) ([]ApplicationSource, error) {
+	if strings.TrimSpace(in) == "" {
+		return nil, errors.New("sources is required for multi_source applications")
+	}
+
+	var sources []ApplicationSource
+	if err := decodeYAML([]byte(in), &sources); err != nil {
+		return nil, fmt.Errorf("invalid sources: %w", err)
+	}
+	if len(sources) == 0 {
+		return nil, errors.New("sources must contain at least one source")
+	}
+
+	for i, s := range sources {
+		if s.RepoURL == "" {
+			return nil, fmt.Errorf("invalid sources: source %d is missing repoURL", i)
+		}
+		if s.Path == "" && s.Chart == "" && s.Ref == "" {
+			return nil, fmt.Errorf("invalid sources: source %d needs a path, chart or ref", i)
+		}
+		if s.Kustomize != nil {
+			for _, image := range s.Kustomize.Images {
+				if _, err := parseImageOverride(image); err != nil {
+					return nil, fmt.Errorf("invalid sources: source %d: %w", i, err)
+				}
+			}
+		}
+	}
+
+	return sources, nil
+}
+
+// yamlToJSON converts a YAML document to JSON.
+// We use gopkg.in/yaml.v3 which is compatible with the rest of our dependencies,
+// and convert through JSON so Kubernetes field names and types apply.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var yamlObj any
+	if err := yaml.Unmarshal(data, &yamlObj); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(yamlObj)
+}
+
+// decodeYAML decodes YAML into v using v's JSON tags.
+func decodeYAML(data []byte, v any) error {
+	jsonBytes, err := yamlToJSON(data)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(jsonBytes, v)
+}
+
+// sourcesFromApplication reads spec.sources, or spec.source, from an ArgoCD Application.
+func sourcesFromApplication(obj *unstructured.Unstructured) ([]ApplicationSource, error) {
+	if raw, found, _ := unstructured.NestedSlice(obj.Object, "spec", "sources"); found && len(raw) > 0 {
+		sources := make([]ApplicationSource, 0, len(raw))
+		for _, r := range raw {
+			m, ok := r.(map[string]any)
+			if !ok {
+				continue
+			}
+			var s ApplicationSource
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, &s); err != nil {
+				return nil, fmt.Errorf("failed to decode spec.sources: %w", err)
+			}
+			sources = append(sources, s)
+		}
+		return sources, nil
+	}
+
+	raw, found, _ := unstructured.NestedMap(obj.Object, "spec", "source")
+	if !found {
+		return nil, nil
+	}
+	var s ApplicationSource
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, &s); err != nil {
+		return nil, fmt.Errorf("failed to decode spec.source: %w", err)
+	}
+	return []ApplicationSource{s}, nil
+}
+
+// sourceTypeOf infers the "source_type" of an Application from its sources.
+func sourceTypeOf(sources []ApplicationSource) string {
+	switch {
+	case len(sources) > 1:
+		return sourceTypeMultiSource
+	case len(sources) == 0:
+		return ""
+	case sources[0].Chart != "" || sources[0].Helm != nil:
+		return sourceTypeHelm
+	case sources[0].Kustomize != nil:
+		return sourceTypeKustomize
+	default:
+		return sourceTypeDirectory
+	}
+}
+
+// gitRepoURLs returns the distinct Git repositories referenced by sources.
+// Helm chart repositories are skipped, since they don't use Git repository credentials.
+func gitRepoURLs(sources []ApplicationSource) []string {
+	var urls []string
+	seen := map[string]bool{}
+	for _, s := range sources {
+		if s.Chart != "" || seen[s.RepoURL] {
+			continue
+		}
+		seen[s.RepoURL] = true
+		urls = append(urls, s.RepoURL)
+	}
+	return urls
+}
+
+// sourceProperties returns the source related properties exposed in the Tempest catalog.
+// The first source is reported through the single-source properties, and multi-source
+// Applications additionally report every source as YAML in "sources".
+func sourceProperties(sources []ApplicationSource) (map[string]any, error) {
+	props := map[string]any{
+		"source_type":       sourceTypeOf(sources),
+		"repo_url":          "",
+		"source_path":       "",
+		"target_revision":   "",
+		"chart":             "",
+		"chart_version":     "",
+		"helm_values":       "",
+		"helm_value_files":  []any{},
+		"helm_parameters":   []any{},
+		"directory_recurse": false,
+		"directory_include": "",
+		"directory_exclude": "",
+		"sources":           "",
+	}
+	if len(sources) == 0 {
+		return props, nil
+	}
+
+	s := sources[0]
+	props["repo_url"] = s.RepoURL
+	props["source_path"] = s.Path
+	if s.Chart != "" {
+		props["chart"] = s.Chart
+		props["chart_version"] = s.TargetRevision
+	} else {
+		props["target_revision"] = s.TargetRevision
+	}
+	if s.Helm != nil {
+		props["helm_values"] = s.Helm.Values
+		props["helm_value_files"] = toAnySlice(s.Helm.ValueFiles)
+		params := make([]string, 0, len(s.Helm.Parameters))
+		for _, p := range s.Helm.Parameters {
+			params = append(params, p.Name+"="+p.Value)
+		}
+		props["helm_parameters"] = toAnySlice(params)
+	}
+	if s.Directory != nil {
+		props["directory_recurse"] = s.Directory.Recurse
+		props["directory_include"] = s.Directory.Include
+		props["directory_exclude"] = s.Directory.Exclude
+	}
+
+	if len(sources) > 1 {
+		out, err := encodeYAML(sources)
+		if err != nil {
+			return nil, err
+		}
+		props["sources"] = out
+	}
+
+	return props, nil
+}
+
+// encodeYAML encodes v as YAML using v's JSON tags, the inverse of decodeYAML.
+func encodeYAML(v any) (string, error) {
+	jsonBytes, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	var obj any
+	if err := json.Unmarshal(jsonBytes, &obj); err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(obj); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// toAnySlice converts a string slice into the []any form expected by
+// Tempest properties, which are serialized as a protobuf Struct.
+func toAnySlice(in []string) []any {
+	out := make([]any, 0, len(in))
+	for _, s := range in {
+		out = append(out, s)
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+package appargocd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/tempestdx/sdk-go/app"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+// getApplication fetches an Application, so its status can be reported after create and update.
+func getApplication(ctx context.Context, dc dynamic.Interface, name string) (*unstructured.Unstructured, error) {
+	obj, err := dc.Resource(applicationGVR).Namespace("argocd").Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get application %s: %w", name, err)
+	}
+	return obj, nil
+}
+
+// statusProperties exposes the status ArgoCD reports for an Application in the Tempest catalog.
+// Managed resources and conditions are flattened into strings, since properties can't hold objects.
+func statusProperties(obj *unstructured.Unstructured) map[string]any {
+	s := parseApplicationStatus(obj)
+
+	resources := make([]string, 0, len(s.Resources))
+	for _, r := range s.Resources {
+		resources = append(resources, formatResource(r))
+	}
+
+	conditions := make([]string, 0, len(s.Conditions))
+	for _, c := range s.Conditions {
+		conditions = append(conditions, fmt.Sprintf("%s: %s", c.Type, c.Message))
+	}
+
+	return map[string]any{
+		"sync_status":           s.Sync,
+		"health_status":         s.Health,
+		"health_message":        s.HealthMsg,
+		"synced_revision":       s.Revision,
+		"operation_phase":       s.Phase,
+		"operation_message":     s.Message,
+		"operation_finished_at": s.FinishedAt,
+		"resources":             toAnySlice(resources),
+		"conditions":            toAnySlice(conditions),
+	}
+}
+
+// formatResource describes a managed object and its status,
+// e.g. "Deployment guestbook/guestbook-ui: Healthy, Synced".
+func formatResource(r resourceStatus) string {
+	ref := r.Kind + " " + r.Name
+	if r.Namespace != "" {
+		ref = r.Kind + " " + r.Namespace + "/" + r.Name
+	}
+
+	// ArgoCD doesn't assess the health of every kind, e.g. ConfigMaps
+	var status []string
+	if r.Health != "" {
+		status = append(status, r.Health)
+	}
+	if r.Status != "" {
+		status = append(status, r.Status)
+	}
+	if len(status) == 0 {
+		return ref
+	}
+	return ref + ": " + strings.Join(status, ", ")
+}
+
+// applicationLinks links to the Application in the ArgoCD UI, using the optional
+// ARGOCD_URL from environment variables, e.g. https://argocd.example.com.
+// No links are returned when ARGOCD_URL is not set.
+func applicationLinks(env map[string]app.EnvironmentVariable, name string) ([]*app.Link, error) {
+	return argocdLinks(env, "applications", "argocd", name)
+}
+
+// argocdLinks links to a page of the ArgoCD UI, given by its path under ARGOCD_URL.
+func argocdLinks(env map[string]app.EnvironmentVariable, path ...string) ([]*app.Link, error) {
+	argocdURL := envValue(env, "ARGOCD_URL")
+	if argocdURL == "" {
+		return nil, nil
+	}
+
+	base, err := url.Parse(strings.TrimSuffix(argocdURL, "/"))
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("invalid ARGOCD_URL %q: expected an absolute URL", argocdURL)
+	}
+
+	return []*app.Link{
+		{
+			URL:   base.JoinPath(path...).String(),
+			Title: "ArgoCD",
+			Type:  app.LinkTypeExternal,
+		},
+	}, nil
+}
@@ -0,0 +1,142 @@
+package appargocd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Sync modes accepted by the "sync_mode" input.
+const (
+	syncModeAutomated = "automated"
+	syncModeManual    = "manual"
+)
+
+// SyncPolicy controls when and how ArgoCD syncs an Application.
+// The JSON tags match spec.syncPolicy in the ArgoCD Application spec.
+// See: https://argo-cd.readthedocs.io/en/stable/user-guide/auto_sync/
+type SyncPolicy struct {
+	Automated   *AutomatedSync `json:"automated,omitempty"`   // Nil for manual sync
+	SyncOptions []string       `json:"syncOptions,omitempty"` // e.g. CreateNamespace=true
+	Retry       *RetryStrategy `json:"retry,omitempty"`
+}
+
+// AutomatedSync holds the options of automated sync.
+type AutomatedSync struct {
+	Prune      bool `json:"prune,omitempty"`      // Delete resources that are no longer defined in Git
+	SelfHeal   bool `json:"selfHeal,omitempty"`   // Revert manual changes to match Git state
+	AllowEmpty bool `json:"allowEmpty,omitempty"` // Allow syncing an Application with no resources
+}
+
+// RetryStrategy controls how failed syncs are retried.
+type RetryStrategy struct {
+	Limit   int64         `json:"limit,omitempty"`
+	Backoff *RetryBackoff `json:"backoff,omitempty"`
+}
+
+// RetryBackoff controls the delay between sync retries.
+type RetryBackoff struct {
+	Duration    string `json:"duration,omitempty"`    // Initial delay, e.g. 5s
+	Factor      int64  `json:"factor,omitempty"`      // Multiplier applied after each retry
+	MaxDuration string `json:"maxDuration,omitempty"` // Maximum delay, e.g. 3m
+}
+
+// syncPolicyFromInput reads the sync_* inputs.
+func syncPolicyFromInput(input map[string]any) (SyncPolicy, error) {
+	var policy SyncPolicy
+
+	switch mode := stringInput(input, "sync_mode"); mode {
+	case "", syncModeAutomated:
+		policy.Automated = &AutomatedSync{
+			Prune:      boolInput(input, "sync_prune"),
+			SelfHeal:   boolInput(input, "sync_self_heal"),
+			AllowEmpty: boolInput(input, "sync_allow_empty"),
+		}
+	case syncModeManual:
+	default:
+		return SyncPolicy{}, fmt.Errorf("unsupported sync_mode %q", mode)
+	}
+
+	for _, opt := range stringSliceInput(input, "sync_options") {
+		if key, _, ok := strings.Cut(opt, "="); !ok || key == "" {
+			return SyncPolicy{}, fmt.Errorf("invalid sync option %q: expected Name=value", opt)
+		}
+		policy.SyncOptions = append(policy.SyncOptions, opt)
+	}
+
+	// JSON numbers are decoded as float64
+	limit, _ := input["sync_retry_limit"].(float64)
+	if limit > 0 {
+		policy.Retry = &RetryStrategy{Limit: int64(limit)}
+
+		backoff := RetryBackoff{
+			Duration:    stringInput(input, "sync_retry_backoff_duration"),
+			MaxDuration: stringInput(input, "sync_retry_backoff_max_duration"),
+		}
+		if factor, _ := input["sync_retry_backoff_factor"].(float64); factor > 0 {
+			backoff.Factor = int64(factor)
+		}
+		for _, d := range []string{backoff.Duration, backoff.MaxDuration} {
+			if d == "" {
+				continue
+			}
+			if _, err := time.ParseDuration(d); err != nil {
+				return SyncPolicy{}, fmt.Errorf("invalid sync retry backoff duration %q: %w", d, err)
+			}
+		}
+		if backoff != (RetryBackoff{}) {
+			policy.Retry.Backoff = &backoff
+		}
+	}
+
+	return policy, nil
+}
+
+// syncPolicyFromApplication reads spec.syncPolicy from an ArgoCD Application.
+func syncPolicyFromApplication(obj *unstructured.Unstructured) (SyncPolicy, error) {
+	var policy SyncPolicy
+	raw, found, _ := unstructured.NestedMap(obj.Object, "spec", "syncPolicy")
+	if !found {
+		return policy, nil
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, &policy); err != nil {
+		return policy, fmt.Errorf("failed to decode spec.syncPolicy: %w", err)
+	}
+	return policy, nil
+}
+
+// syncPolicyProperties returns the sync policy properties exposed in the Tempest catalog.
+func syncPolicyProperties(policy SyncPolicy) map[string]any {
+	props := map[string]any{
+		"sync_mode":                       syncModeManual,
+		"sync_prune":                      false,
+		"sync_self_heal":                  false,
+		"sync_allow_empty":                false,
+		"sync_options":                    toAnySlice(policy.SyncOptions),
+		"sync_retry_limit":                int64(0),
+		"sync_retry_backoff_duration":     "",
+		"sync_retry_backoff_factor":       int64(0),
+		"sync_retry_backoff_max_duration": "",
+	}
+
+	if a := policy.Automated; a != nil {
+		props["sync_mode"] = syncModeAutomated
+		props["sync_prune"] = a.Prune
+		props["sync_self_heal"] = a.SelfHeal
+		props["sync_allow_empty"] = a.AllowEmpty
+	}
+
+	if r := policy.Retry; r != nil {
+		props["sync_retry_limit"] = r.Limit
+		if b := r.Backoff; b != nil {
+			props["sync_retry_backoff_duration"] = b.Duration
+			props["sync_retry_backoff_factor"] = b.Factor
+			props["sync_retry_backoff_max_duration"] = b.MaxDuration
+		}
+	}
+
+	return props
+}
@@ -0,0 +1,129 @@
+package appargocd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// rollbackTimeout bounds how long we spend cleaning up after a failed create.
+// Rolling back uses its own deadline, since the create may have failed because
+// its own context was cancelled or timed out.
+const rollbackTimeout = time.Minute
+
+// applyFunc applies a manifest and returns the UID of the applied object.
+type applyFunc func(ctx context.Context, manifest []byte) (string, error)
+
+// objectRef identifies an object applied to the cluster.
+type objectRef struct {
+	GVR       schema.GroupVersionResource
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (r objectRef) String() string {
+	return fmt.Sprintf("%s %s/%s", r.Kind, r.Namespace, r.Name)
+}
+
+// transaction applies manifests and records the objects it created, so they can
+// be deleted again if a later step fails. Objects that already existed, such as
+// repository Secrets shared with other Applications, are never recorded.
+type transaction struct {
+	dc      dynamic.Interface
+	opts    applyOptions
+	created []objectRef
+}
+
+func newTransaction(dc dynamic.Interface, opts applyOptions) *transaction {
+	return &transaction{dc: dc, opts: opts}
+}
+
+// apply applies a manifest like apply does, recording the object if it didn't exist yet.
+func (t *transaction) apply(ctx context.Context, manifest []byte) (string, error) {
+	obj, err := decodeManifest(manifest)
+	if err != nil {
+		return "", err
+	}
+
+	client, mapping, err := newApplier(t.dc).ResourceClient(obj)
+	if err != nil {
+		return "", err
+	}
+
+	ref := objectRef{GVR: mapping.Resource, Kind: obj.GetKind(), Namespace: obj.GetNamespace(), Name: obj.GetName()}
+	_, err = client.Get(ctx, ref.Name, metav1.GetOptions{})
+	existed := err == nil
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return "", fmt.Errorf("failed to check if %s exists: %w", ref, err)
+	}
+
+	uid, err := apply(ctx, t.dc, manifest, t.opts)
+	if err != nil {
+		return "", err
+	}
+
+	if !existed {
+		t.created = append(t.created, ref)
+	}
+	return uid, nil
+}
+
+// rollback deletes the objects created by the transaction, most recent first,
+// and returns a RollbackError wrapping cause.
+// Deleting the Application lets ArgoCD's resources finalizer remove anything it already deployed.
+func (t *transaction) rollback(ctx context.Context, cause error) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rollbackTimeout)
+	defer cancel()
+
+	rbErr := &RollbackError{Cause: cause}
+	propagation := metav1.DeletePropagationBackground
+	for i := len(t.created) - 1; i >= 0; i-- {
+		ref := t.created[i]
+		err := t.dc.Resource(ref.GVR).Namespace(ref.Namespace).Delete(ctx, ref.Name, metav1.DeleteOptions{
+			PropagationPolicy: &propagation,
+		})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			rbErr.Failed = append(rbErr.Failed, fmt.Sprintf("%s (%v)", ref, err))
+			continue
+		}
+		rbErr.Deleted = append(rbErr.Deleted, ref.String())
+	}
+	t.created = nil
+
+	return rbErr
+}
+
+// RollbackError is returned when creating an Application fails after some objects
+// were already created. It lists the objects that were deleted again, and those
+// that could not be deleted and need to be cleaned up by hand.
+type RollbackError struct {
+	Cause   error    // Why the create failed
+	Deleted []string // Objects created by the failed operation and deleted again
+	Failed  []string // Objects created by the failed operation that could not be deleted
+}
+
+func (e *RollbackError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Cause.Error())
+	if len(e.Deleted) > 0 {
+		fmt.Fprintf(&b, "; rolled back: %s", strings.Join(e.Deleted, ", "))
+	} else {
+		b.WriteString("; nothing to roll back")
+	}
+	if len(e.Failed) > 0 {
+		fmt.Fprintf(&b, "; failed to roll back: %s", strings.Join(e.Failed, ", "))
+	}
+	return b.String()
+}
+
+// Unwrap returns the cause, so callers can still check for a SyncError.
+func (e *RollbackError) Unwrap() error {
+	return e.Cause
+}
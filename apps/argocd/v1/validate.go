@@ -0,0 +1,516 @@
+package appargocd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// crdGVR identifies CustomResourceDefinitions, which hold the OpenAPI schema of Applications.
+var crdGVR = schema.GroupVersionResource{
+	Group:    "apiextensions.k8s.io",
+	Version:  "v1",
+	Resource: "customresourcedefinitions",
+}
+
+// applicationCRDName is the name of the CustomResourceDefinition of Applications.
+const applicationCRDName = "applications.argoproj.io"
+
+// scpLikeURLRegexp matches SSH repository URLs in scp form, e.g. git@github.com:org/repo.git.
+var scpLikeURLRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]+@[A-Za-z0-9.-]+:[^/].*$`)
+
+// applicationInputFields maps fields of a rendered Application to the input they
+// are rendered from. The first entry whose path is a prefix of a field wins, so
+// more specific paths come first.
+var applicationInputFields = []struct {
+	Path  string
+	Input string
+}{
+	{"metadata.name", "name"},
+	{"metadata.labels", "labels"},
+	{"metadata.annotations", "annotations"},
+	{"spec.project", "argocd_project"},
+	{"spec.destination.namespace", "namespace"},
+	{"spec.destination", "destination_cluster"},
+	{"spec.source.repoURL", "repo_url"},
+	{"spec.source.path", "source_path"},
+	{"spec.source.targetRevision", "target_revision"},
+	{"spec.source.chart", "chart"},
+	{"spec.source.kustomize", "images"},
+	{"spec.source.helm.releaseName", "helm_release_name"},
+	{"spec.source.helm.valueFiles", "helm_value_files"},
+	{"spec.source.helm.values", "helm_values"},
+	{"spec.source.helm.parameters", "helm_parameters"},
+	{"spec.source.directory.recurse", "directory_recurse"},
+	{"spec.source.directory.include", "directory_include"},
+	{"spec.source.directory.exclude", "directory_exclude"},
+	{"spec.source", "source_type"},
+	{"spec.sources", "sources"},
+	{"spec.syncPolicy.automated.prune", "sync_prune"},
+	{"spec.syncPolicy.automated.selfHeal", "sync_self_heal"},
+	{"spec.syncPolicy.automated.allowEmpty", "sync_allow_empty"},
+	{"spec.syncPolicy.syncOptions", "sync_options"},
+	{"spec.syncPolicy.retry.limit", "sync_retry_limit"},
+	{"spec.syncPolicy.retry.backoff.duration", "sync_retry_backoff_duration"},
+	{"spec.syncPolicy.retry.backoff.factor", "sync_retry_backoff_factor"},
+	{"spec.syncPolicy.retry.backoff.maxDuration", "sync_retry_backoff_max_duration"},
+	{"spec.syncPolicy", "sync_mode"},
+}
+
+// FieldError is a problem with a field of a rendered Application.
+type FieldError struct {
+	Input   string // Input the field is rendered from, e.g. repo_url
+	Field   string // Path of the field in the Application, e.g. spec.source.repoURL
+	Message string
+}
+
+// ValidationError is returned when a rendered Application would be rejected by
+// the API server or fail to sync, before anything is applied.
+type ValidationError struct {
+	Name   string
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		problems = append(problems, fmt.Sprintf("%s (%s): %s", fe.Input, fe.Field, fe.Message))
+	}
+	return fmt.Sprintf("application %s is invalid: %s", e.Name, strings.Join(problems, "; "))
+}
+
+// validateApplication checks a rendered Application before it is applied: against
+// the OpenAPI schema of the Application CRD, for repository URLs and paths that
+// can't work, and against the AppProject it joins. Problems are reported as a
+// ValidationError naming the inputs to fix.
+func validateApplication(ctx context.Context, dc dynamic.Interface, obj *unstructured.Unstructured) error {
+	var errs []FieldError
+	add := func(field, message string) {
+		errs = append(errs, FieldError{Input: inputForField(obj, field), Field: field, Message: message})
+	}
+
+	schemaProblems, err := validateAgainstCRD(ctx, dc, obj)
+	if err != nil {
+		return err
+	}
+	for _, p := range schemaProblems {
+		add(p.Field, p.Message)
+	}
+
+	sources := applicationSourceFields(obj)
+	for field, source := range sources {
+		repoURL, _ := source["repoURL"].(string)
+		chart, _ := source["chart"].(string)
+		if msg := checkRepoURL(repoURL, chart != ""); msg != "" {
+			add(field+".repoURL", msg)
+		}
+		if path, _ := source["path"].(string); path != "" {
+			if msg := checkSourcePath(path); msg != "" {
+				add(field+".path", msg)
+			}
+		}
+	}
+
+	projectProblems, err := validateAgainstProject(ctx, dc, obj, sources)
+	if err != nil {
+		return err
+	}
+	for _, p := range projectProblems {
+		add(p.Field, p.Message)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return &ValidationError{Name: obj.GetName(), Errors: errs}
+}
+
+// inputForField returns the input a field of the Application is rendered from.
+// The targetRevision of a Helm chart is its chart_version.
+func inputForField(obj *unstructured.Unstructured, field string) string {
+	if field == "spec.source.targetRevision" {
+		if chart, _, _ := unstructured.NestedString(obj.Object, "spec", "source", "chart"); chart != "" {
+			return "chart_version"
+		}
+	}
+	for _, f := range applicationInputFields {
+		if field == f.Path || strings.HasPrefix(field, f.Path+".") || strings.HasPrefix(field, f.Path+"[") {
+			return f.Input
+		}
+	}
+	return field
+}
+
+// applicationSourceFields returns the sources of an Application by their path,
+// spec.source or spec.sources[i].
+func applicationSourceFields(obj *unstructured.Unstructured) map[string]map[string]any {
+	out := map[string]map[string]any{}
+	if source, found, _ := unstructured.NestedMap(obj.Object, "spec", "source"); found {
+		out["spec.source"] = source
+	}
+	sources, _, _ := unstructured.NestedSlice(obj.Object, "spec", "sources")
+	for i, s := range sources {
+		if m, ok := s.(map[string]any); ok {
+			out[fmt.Sprintf("spec.sources[%d]", i)] = m
+		}
+	}
+	return out
+}
+
+// checkRepoURL returns why ArgoCD can't fetch from a repository URL, or "" if it
+// looks valid. Git repositories are reached over HTTPS, SSH or the git protocol.
+// Helm charts also come from OCI registries, which may be given without a scheme.
+func checkRepoURL(repoURL string, chart bool) string {
+	if repoURL == "" || scpLikeURLRegexp.MatchString(repoURL) {
+		return ""
+	}
+	if strings.ContainsAny(repoURL, " \t\n") {
+		return fmt.Sprintf("%q must not contain whitespace", repoURL)
+	}
+
+	if chart && !strings.Contains(repoURL, "://") {
+		if host, _, ok := strings.Cut(repoURL, "/"); ok && strings.Contains(host, ".") {
+			return ""
+		}
+	}
+
+	u, err := url.Parse(repoURL)
+	if err != nil || u.Host == "" {
+		return fmt.Sprintf("%q is not a repository URL", repoURL)
+	}
+
+	schemes := []string{"https", "http", "ssh", "git"}
+	if chart {
+		schemes = []string{"https", "http", "oci"}
+	}
+	if !slices.Contains(schemes, u.Scheme) {
+		return fmt.Sprintf("%q uses unsupported scheme %q, expected one of %s", repoURL, u.Scheme, strings.Join(schemes, ", "))
+	}
+	return ""
+}
+
+// checkSourcePath returns why a path can't be a directory of a repository, or "" if it looks valid.
+func checkSourcePath(path string) string {
+	switch {
+	case strings.Contains(path, "://"):
+		return fmt.Sprintf("%q is a URL; repo_url holds the repository and source_path a directory within it", path)
+	case strings.HasPrefix(path, "/"):
+		return fmt.Sprintf("%q must be relative to the repository root", path)
+	case slices.Contains(strings.Split(path, "/"), ".."):
+		return fmt.Sprintf("%q must not leave the repository with ..", path)
+	}
+	return ""
+}
+
+// validateAgainstCRD validates the spec of an Application against the OpenAPI
+// schema of the Application CRD installed in the cluster, so the validation
+// matches the ArgoCD version that runs there. It is skipped, with a warning, if
+// the CRD can't be read, e.g. because the credentials may not read CRDs.
+func validateAgainstCRD(ctx context.Context, dc dynamic.Interface, obj *unstructured.Unstructured) ([]FieldError, error) {
+	crd, err := dc.Resource(crdGVR).Get(ctx, applicationCRDName, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) || k8serrors.IsForbidden(err) {
+		slog.Warn("skipping schema validation of application", "name", obj.GetName(), "error", err)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the %s CRD: %w", applicationCRDName, err)
+	}
+
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	for _, v := range versions {
+		version, _ := v.(map[string]any)
+		if version["name"] != applicationGVR.Version {
+			continue
+		}
+		spec, found, _ := unstructured.NestedMap(version, "schema", "openAPIV3Schema", "properties", "spec")
+		if !found {
+			break
+		}
+		return validateSchema("spec", obj.Object["spec"], spec), nil
+	}
+
+	slog.Warn("skipping schema validation of application: the CRD has no schema", "name", obj.GetName(), "version", applicationGVR.Version)
+	return nil, nil
+}
+
+// validateSchema validates a value against a structural OpenAPI v3 schema, the
+// subset of OpenAPI CRDs use. Fields of the value missing from the schema are
+// reported as unknown, as the API server would prune or reject them.
+func validateSchema(field string, value any, s map[string]any) []FieldError {
+	// The API server drops null fields that aren't nullable instead of rejecting
+	// them, e.g. the empty "helm:" of a chart without options
+	if value == nil {
+		return nil
+	}
+
+	if intOrString, _ := s["x-kubernetes-int-or-string"].(bool); intOrString {
+		if _, ok := value.(string); ok || isInteger(value) {
+			return nil
+		}
+		return []FieldError{{Field: field, Message: "must be an integer or a string"}}
+	}
+
+	typ, _ := s["type"].(string)
+	if msg := checkSchemaType(typ, value); msg != "" {
+		return []FieldError{{Field: field, Message: msg}}
+	}
+	if enum, ok := s["enum"].([]any); ok && !slices.Contains(enum, value) {
+		return []FieldError{{Field: field, Message: fmt.Sprintf("must be one of %v", enum)}}
+	}
+
+	var errs []FieldError
+	switch v := value.(type) {
+	case map[string]any:
+		properties, _ := s["properties"].(map[string]any)
+		preserveUnknown, _ := s["x-kubernetes-preserve-unknown-fields"].(bool)
+		additional := s["additionalProperties"]
+
+		if required, ok := s["required"].([]any); ok {
+			for _, r := range required {
+				if name, _ := r.(string); name != "" && v[name] == nil {
+					errs = append(errs, FieldError{Field: field + "." + name, Message: "required field is missing"})
+				}
+			}
+		}
+
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			child := field + "." + k
+			if p, ok := properties[k].(map[string]any); ok {
+				errs = append(errs, validateSchema(child, v[k], p)...)
+				continue
+			}
+			switch a := additional.(type) {
+			case map[string]any:
+				errs = append(errs, validateSchema(child, v[k], a)...)
+			case bool:
+				if !a {
+					errs = append(errs, FieldError{Field: child, Message: "unknown field"})
+				}
+			default:
+				if !preserveUnknown {
+					errs = append(errs, FieldError{Field: child, Message: "unknown field"})
+				}
+			}
+		}
+
+	case []any:
+		if items, ok := s["items"].(map[string]any); ok {
+			for i, item := range v {
+				errs = append(errs, validateSchema(fmt.Sprintf("%s[%d]", field, i), item, items)...)
+			}
+		}
+	}
+	return errs
+}
+
+// checkSchemaType returns why a decoded YAML value doesn't have an OpenAPI type,
+// or "" if it does. An empty type accepts any value.
+func checkSchemaType(typ string, value any) string {
+	var ok bool
+	switch typ {
+	case "":
+		ok = true
+	case "object":
+		_, ok = value.(map[string]any)
+	case "array":
+		_, ok = value.([]any)
+	case "string":
+		_, ok = value.(string)
+	case "boolean":
+		_, ok = value.(bool)
+	case "integer":
+		ok = isInteger(value)
+	case "number":
+		switch value.(type) {
+		case int64, float64:
+			ok = true
+		}
+	default:
+		ok = true
+	}
+	if ok {
+		return ""
+	}
+	return fmt.Sprintf("must be of type %s, got %T", typ, value)
+}
+
+// isInteger reports whether a decoded YAML value is an integer. Manifests decoded
+// through JSON hold every number as a float64.
+func isInteger(value any) bool {
+	switch v := value.(type) {
+	case int64:
+		return true
+	case float64:
+		return v == math.Trunc(v)
+	}
+	return false
+}
+
+// validateAgainstProject checks that the AppProject an Application joins exists,
+// and permits its destination and source repositories, which ArgoCD otherwise
+// only reports once it tries to sync.
+func validateAgainstProject(ctx context.Context, dc dynamic.Interface, obj *unstructured.Unstructured, sources map[string]map[string]any) ([]FieldError, error) {
+	projectName, _, _ := unstructured.NestedString(obj.Object, "spec", "project")
+	project, err := dc.Resource(appProjectGVR).Namespace("argocd").Get(ctx, projectName, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return []FieldError{{Field: "spec.project", Message: fmt.Sprintf("AppProject %q doesn't exist", projectName)}}, nil
+	}
+	if k8serrors.IsForbidden(err) {
+		slog.Warn("skipping AppProject validation of application", "name", obj.GetName(), "project", projectName, "error", err)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get AppProject %s: %w", projectName, err)
+	}
+
+	var errs []FieldError
+
+	dest, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "destination")
+	name, server, err := resolveDestination(ctx, dc, dest["name"], dest["server"])
+	if err != nil {
+		return nil, err
+	}
+	destinations, _, _ := unstructured.NestedSlice(project.Object, "spec", "destinations")
+	if !destinationPermitted(destinations, name, server, dest["namespace"]) {
+		cluster := dest["name"]
+		if cluster == "" {
+			cluster = dest["server"]
+		}
+		errs = append(errs, FieldError{
+			Field:   "spec.destination.namespace",
+			Message: fmt.Sprintf("namespace %q of cluster %q is not a destination of AppProject %q", dest["namespace"], cluster, projectName),
+		})
+	}
+
+	sourceRepos, _, _ := unstructured.NestedStringSlice(project.Object, "spec", "sourceRepos")
+	for field, source := range sources {
+		repoURL, _ := source["repoURL"].(string)
+		if repoURL != "" && !sourcePermitted(sourceRepos, repoURL) {
+			errs = append(errs, FieldError{
+				Field:   field + ".repoURL",
+				Message: fmt.Sprintf("%q is not a source repository of AppProject %q", repoURL, projectName),
+			})
+		}
+	}
+	return errs, nil
+}
+
+// resolveDestination returns both the name and the API server URL of an
+// Application's destination cluster, since AppProject destinations may refer to
+// either. Registered clusters are looked up in their Secrets; an unregistered
+// one, which checkDestinationCluster reports, has an empty server.
+func resolveDestination(ctx context.Context, dc dynamic.Interface, name, server string) (string, string, error) {
+	const inClusterServer = "https://kubernetes.default.svc"
+	switch {
+	case name == inClusterName || (name == "" && strings.TrimSuffix(server, "/") == inClusterServer):
+		return inClusterName, inClusterServer, nil
+	case name == "":
+		return "", server, nil
+	}
+
+	secrets, err := clusterSecrets(ctx, dc, "")
+	if err != nil {
+		return "", "", err
+	}
+	for i := range secrets {
+		if data := secretData(&secrets[i]); data["name"] == name {
+			return name, data["server"], nil
+		}
+	}
+	return name, "", nil
+}
+
+// destinationPermitted reports whether the destinations of an AppProject permit a
+// cluster and namespace, following ArgoCD: a destination permits it if both its
+// cluster, by name or server, and its namespace match. A destination with patterns
+// starting with "!" also denies the cluster and namespace it matches once the "!" is
+// stripped from them, even if another destination permits it.
+func destinationPermitted(destinations []any, name, server, namespace string) bool {
+	permitted := false
+	for _, d := range destinations {
+		dest, _ := d.(map[string]any)
+		destName, _ := dest["name"].(string)
+		destServer, _ := dest["server"].(string)
+		destNamespace, _ := dest["namespace"].(string)
+
+		if destinationMatches(destName, destServer, destNamespace, name, server, namespace) {
+			permitted = true
+		} else if isDenyPattern(destName) || isDenyPattern(destServer) || isDenyPattern(destNamespace) {
+			strip := func(p string) string { return strings.TrimPrefix(p, "!") }
+			if destinationMatches(strip(destName), strip(destServer), strip(destNamespace), name, server, namespace) {
+				return false
+			}
+		}
+	}
+	return permitted
+}
+
+// destinationMatches reports whether the name, server and namespace patterns of an
+// AppProject destination match a cluster and namespace. An unresolved name or server
+// matches no pattern.
+func destinationMatches(destName, destServer, destNamespace, name, server, namespace string) bool {
+	nameMatched := name != "" && globMatch(destName, name)
+	serverMatched := server != "" && globMatch(destServer, server)
+	return (nameMatched || serverMatched) && globMatch(destNamespace, namespace)
+}
+
+// sourcePermitted reports whether the source repositories of an AppProject permit
+// a repository URL. Patterns starting with "!" deny the repositories they match.
+func sourcePermitted(patterns []string, repoURL string) bool {
+	repoURL = normalizeRepoURL(repoURL)
+	permitted := false
+	for _, p := range patterns {
+		if isDenyPattern(p) {
+			if !globMatch(normalizeRepoURL(p), repoURL) {
+				return false
+			}
+			continue
+		}
+		if globMatch(normalizeRepoURL(p), repoURL) {
+			permitted = true
+		}
+	}
+	return permitted
+}
+
+// normalizeRepoURL makes equivalent forms of a repository URL equal, as ArgoCD does.
+func normalizeRepoURL(repoURL string) string {
+	repoURL = strings.ToLower(strings.TrimSpace(repoURL))
+	repoURL = strings.TrimSuffix(repoURL, "/")
+	return strings.TrimSuffix(repoURL, ".git")
+}
+
+func isDenyPattern(pattern string) bool {
+	return strings.HasPrefix(pattern, "!")
+}
+
+// globMatch matches a value against an ArgoCD glob pattern, where "*" matches any
+// characters, including "/", and "?" a single one. A pattern starting with "!"
+// matches the values its remainder doesn't match.
+func globMatch(pattern, value string) bool {
+	if rest, ok := strings.CutPrefix(pattern, "!"); ok {
+		return !globMatch(rest, value)
+	}
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	return regexp.MustCompile("^" + expr + "$").MatchString(value)
+}
@@ -0,0 +1,278 @@
+package appargocd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tempestdx/sdk-go/app"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestCreateFnValidation(t *testing.T) {
+	f := newFakeArgoCD(t, testAppProject("payments",
+		[]any{"https://github.com/tempestdx/*"},
+		[]any{map[string]any{"server": "https://kubernetes.default.svc", "namespace": "payments-*"}},
+	))
+
+	tests := []struct {
+		name      string
+		overrides map[string]any
+		input     string
+		want      string
+	}{
+		{
+			name:      "repository without scheme",
+			overrides: map[string]any{"repo_url": "github.com/tempestdx/example-repository"},
+			input:     "repo_url",
+			want:      "is not a repository URL",
+		},
+		{
+			name:      "unsupported scheme",
+			overrides: map[string]any{"repo_url": "ftp://example.com/repo.git"},
+			input:     "repo_url",
+			want:      `unsupported scheme "ftp"`,
+		},
+		{
+			name:      "absolute path",
+			overrides: map[string]any{"source_path": "/applications/guestbook"},
+			input:     "source_path",
+			want:      "must be relative to the repository root",
+		},
+		{
+			name:      "path outside the repository",
+			overrides: map[string]any{"source_path": "applications/../../guestbook"},
+			input:     "source_path",
+			want:      "must not leave the repository",
+		},
+		{
+			name:      "missing AppProject",
+			overrides: map[string]any{"argocd_project": "billing"},
+			input:     "argocd_project",
+			want:      `AppProject "billing" doesn't exist`,
+		},
+		{
+			name:      "namespace not permitted",
+			overrides: map[string]any{"argocd_project": "payments", "namespace": "default", "repo_url": "https://github.com/tempestdx/payments.git"},
+			input:     "namespace",
+			want:      `namespace "default" of cluster "https://kubernetes.default.svc" is not a destination of AppProject "payments"`,
+		},
+		{
+			name:      "repository not permitted",
+			overrides: map[string]any{"argocd_project": "payments", "namespace": "payments-api", "repo_url": "https://gitlab.com/other/payments.git"},
+			input:     "repo_url",
+			want:      `is not a source repository of AppProject "payments"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := createFn(t.Context(), &app.OperationRequest{
+				Metadata:    testMetadata(),
+				Environment: f.env(),
+				Input:       applicationInput(tt.overrides),
+			})
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("createFn error = %v, want a ValidationError", err)
+			}
+			if len(validationErr.Errors) != 1 {
+				t.Fatalf("ValidationError has %d errors, want 1: %v", len(validationErr.Errors), err)
+			}
+			if fe := validationErr.Errors[0]; fe.Input != tt.input || !strings.Contains(fe.Message, tt.want) {
+				t.Errorf("error = %s: %s, want %s: %s", fe.Input, fe.Message, tt.input, tt.want)
+			}
+
+			// Nothing is applied when validation fails
+			if f.exists(applicationGVR, "guestbook") {
+				t.Error("invalid Application was applied")
+			}
+		})
+	}
+
+	// A permitted Application passes
+	if _, err := createFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Input: applicationInput(map[string]any{
+			"argocd_project": "payments",
+			"namespace":      "payments-api",
+			"repo_url":       "https://github.com/tempestdx/payments.git",
+		}),
+	}); err != nil {
+		t.Fatalf("createFn: %v", err)
+	}
+}
+
+func TestCreateFnValidationCRDSchema(t *testing.T) {
+	f := newFakeArgoCD(t)
+
+	// ArgoCD versions without plain directory options don't know spec.source.directory
+	crd := f.getIn(t, crdGVR, "", applicationCRDName)
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	unstructured.RemoveNestedField(versions[0].(map[string]any), "schema", "openAPIV3Schema", "properties", "spec", "properties", "source", "properties", "directory")
+	if err := unstructured.SetNestedSlice(crd.Object, versions, "spec", "versions"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.client.Tracker().Update(crdGVR, crd, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := createFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Input:       applicationInput(map[string]any{"source_type": "directory", "directory_recurse": true}),
+	})
+	want := "application guestbook is invalid: source_type (spec.source.directory): unknown field"
+	if err == nil || err.Error() != want {
+		t.Fatalf("createFn error = %v, want %s", err, want)
+	}
+}
+
+func TestUpdateFnValidation(t *testing.T) {
+	f := newFakeArgoCD(t)
+	created := createTestApplication(t, f, nil)
+
+	_, err := updateFn(t.Context(), &app.OperationRequest{
+		Metadata:    testMetadata(),
+		Environment: f.env(),
+		Resource:    created,
+		Input:       applicationInput(map[string]any{"source_path": "https://github.com/tempestdx/example-repository/tree/main/guestbook"}),
+	})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Errors[0].Input != "source_path" {
+		t.Fatalf("updateFn error = %v, want a ValidationError of source_path", err)
+	}
+
+	obj := f.get(t, applicationGVR, "guestbook")
+	if path, _, _ := unstructured.NestedString(obj.Object, "spec", "source", "path"); path != "applications/guestbook" {
+		t.Errorf("spec.source.path = %q, want it unchanged", path)
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	s := map[string]any{
+		"type":     "object",
+		"required": []any{"limit"},
+		"properties": map[string]any{
+			"limit":  map[string]any{"type": "integer"},
+			"mode":   map[string]any{"type": "string", "enum": []any{"Sync", "Hook"}},
+			"port":   map[string]any{"x-kubernetes-int-or-string": true},
+			"labels": map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}},
+			"values": map[string]any{"type": "object", "x-kubernetes-preserve-unknown-fields": true},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		value map[string]any
+		want  []string
+	}{
+		{
+			name:  "valid",
+			value: map[string]any{"limit": float64(5), "mode": "Hook", "port": "http", "labels": map[string]any{"a": "b"}, "values": map[string]any{"any": true}},
+		},
+		{
+			name:  "missing required field",
+			value: map[string]any{"mode": "Sync"},
+			want:  []string{"spec.limit: required field is missing"},
+		},
+		{
+			name:  "wrong types",
+			value: map[string]any{"limit": 1.5, "port": true, "labels": map[string]any{"a": int64(1)}},
+			want: []string{
+				"spec.labels.a: must be of type string, got int64",
+				"spec.limit: must be of type integer, got float64",
+				"spec.port: must be an integer or a string",
+			},
+		},
+		{
+			name:  "enum and unknown field",
+			value: map[string]any{"limit": int64(1), "mode": "Replace", "prune": true},
+			want:  []string{"spec.mode: must be one of [Sync Hook]", "spec.prune: unknown field"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, fe := range validateSchema("spec", tt.value, s) {
+				got = append(got, fe.Field+": "+fe.Message)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("validateSchema = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDestinationPermitted(t *testing.T) {
+	destinations := []any{
+		map[string]any{"server": "*", "namespace": "team-*"},
+		map[string]any{"name": "prod", "namespace": "payments"},
+	}
+	// A deny pattern permits everything else it matches
+	denied := append(destinations, map[string]any{"server": "https://kubernetes.default.svc", "namespace": "!team-secrets"})
+	// Only on the clusters the deny entry names
+	deniedOnCluster := append(destinations, map[string]any{"server": "https://a.example.com", "namespace": "!team-secrets"})
+	deniedServer := append(destinations, map[string]any{"server": "!https://x.example.com", "namespace": "*"})
+
+	tests := []struct {
+		destinations            []any
+		name, server, namespace string
+		want                    bool
+	}{
+		{destinations, "", "https://kubernetes.default.svc", "team-a", true},
+		{destinations, "prod", "https://prod.example.com", "payments", true},
+		{destinations, "staging", "https://staging.example.com", "payments", false},
+		{destinations, "", "https://kubernetes.default.svc", "team-secrets", true},
+		{denied, "", "https://kubernetes.default.svc", "team-secrets", false},
+		{denied, "", "https://kubernetes.default.svc", "default", true},
+		{deniedOnCluster, "", "https://a.example.com", "team-secrets", false},
+		{deniedOnCluster, "", "https://b.example.com", "team-secrets", true},
+		{deniedServer, "", "https://x.example.com", "team-a", false},
+		{deniedServer, "", "https://y.example.com", "team-a", true},
+		// A registered cluster whose server couldn't be resolved
+		{deniedServer, "prod", "", "payments", true},
+	}
+	for _, tt := range tests {
+		if got := destinationPermitted(tt.destinations, tt.name, tt.server, tt.namespace); got != tt.want {
+			t.Errorf("destinationPermitted(%v, %q, %q, %q) = %v, want %v", tt.destinations, tt.name, tt.server, tt.namespace, got, tt.want)
+		}
+	}
+}
+
+func TestCheckRepoURL(t *testing.T) {
+	tests := []struct {
+		url   string
+		chart bool
+		valid bool
+	}{
+		{"https://github.com/tempestdx/examples.git", false, true},
+		{"git@github.com:tempestdx/examples.git", false, true},
+		{"ssh://git@github.com/tempestdx/examples.git", false, true},
+		{"github.com/tempestdx/examples", false, false},
+		{"oci://ghcr.io/tempestdx/charts", false, false},
+		{"oci://ghcr.io/tempestdx/charts", true, true},
+		{"registry-1.docker.io/bitnamicharts", true, true},
+		{"https://charts.example.com/stable ", true, false},
+	}
+	for _, tt := range tests {
+		if msg := checkRepoURL(tt.url, tt.chart); (msg == "") != tt.valid {
+			t.Errorf("checkRepoURL(%q, %v) = %q, want valid %v", tt.url, tt.chart, msg, tt.valid)
+		}
+	}
+}
+
+// testAppProject returns an AppProject permitting sourceRepos and destinations.
+func testAppProject(name string, sourceRepos, destinations []any) runtime.Object {
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "argoproj.io/v1alpha1",
+		"kind":       "AppProject",
+		"metadata":   map[string]any{"name": name, "namespace": "argocd"},
+		"spec": map[string]any{
+			"sourceRepos":  sourceRepos,
+			"destinations": destinations,
+		},
+	}}
+}
@@ -0,0 +1,408 @@
+package appargocd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/tempestdx/sdk-go/app"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+)
+
+// defaultSyncTimeout is how long we wait for an Application to become Synced and Healthy
+// when ARGOCD_SYNC_TIMEOUT is not set in the Tempest environment.
+const defaultSyncTimeout = 10 * time.Minute
+
+// applicationGVR identifies ArgoCD Applications for the dynamic client.
+var applicationGVR = schema.GroupVersionResource{
+	Group:    "argoproj.io",
+	Version:  "v1alpha1",
+	Resource: "applications",
+}
+
+// applicationCondition mirrors an entry of an Application's status.conditions.
+type applicationCondition struct {
+	Type    string
+	Message string
+}
+
+// resourceStatus mirrors an entry of an Application's status.resources,
+// which describes a single Kubernetes object managed by the Application.
+type resourceStatus struct {
+	Group     string
+	Kind      string
+	Namespace string
+	Name      string
+	Status    string // Sync status of the object
+	Health    string // Health status of the object, empty if ArgoCD does not assess it
+	Message   string // Health message reported by ArgoCD
+}
+
+func (r resourceStatus) String() string {
+	ref := r.Kind + "/" + r.Name
+	if r.Namespace != "" {
+		ref = r.Namespace + "/" + ref
+	}
+	if r.Message != "" {
+		return fmt.Sprintf("%s (%s: %s)", ref, r.Health, r.Message)
+	}
+	return fmt.Sprintf("%s (%s)", ref, r.Health)
+}
+
+// applicationStatus is the subset of an Application's status that we care about
+// when deciding whether a deployment landed.
+type applicationStatus struct {
+	Sync       string // status.sync.status, e.g. Synced or OutOfSync
+	Revision   string // status.sync.revision, the commit SHA or chart version ArgoCD compared against
+	Health     string // status.health.status, e.g. Healthy, Progressing or Degraded
+	HealthMsg  string // status.health.message, set when ArgoCD explains the health status
+	Phase      string // status.operationState.phase, e.g. Running, Succeeded or Failed
+	Message    string // status.operationState.message
+	StartedAt  string // status.operationState.startedAt, which identifies the last operation
+	FinishedAt string // status.operationState.finishedAt, empty while an operation is running
+	// status.reconciledAt, when ArgoCD last compared the Application with its sources
+	ReconciledAt string
+	Pending      bool // An operation has been requested and the controller hasn't completed it yet
+	Conditions   []applicationCondition
+	Resources    []resourceStatus
+}
+
+// parseApplicationStatus extracts the status of an ArgoCD Application
+// from its unstructured representation.
+func parseApplicationStatus(obj *unstructured.Unstructured) applicationStatus {
+	var s applicationStatus
+	s.Sync, _, _ = unstructured.NestedString(obj.Object, "status", "sync", "status")
+	s.Revision, _, _ = unstructured.NestedString(obj.Object, "status", "sync", "revision")
+	s.Health, _, _ = unstructured.NestedString(obj.Object, "status", "health", "status")
+	s.HealthMsg, _, _ = unstructured.NestedString(obj.Object, "status", "health", "message")
+	s.Phase, _, _ = unstructured.NestedString(obj.Object, "status", "operationState", "phase")
+	s.Message, _, _ = unstructured.NestedString(obj.Object, "status", "operationState", "message")
+	s.StartedAt, _, _ = unstructured.NestedString(obj.Object, "status", "operationState", "startedAt")
+	s.FinishedAt, _, _ = unstructured.NestedString(obj.Object, "status", "operationState", "finishedAt")
+	s.ReconciledAt, _, _ = unstructured.NestedString(obj.Object, "status", "reconciledAt")
+	_, s.Pending, _ = unstructured.NestedMap(obj.Object, "operation")
+
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		m, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+		t, _, _ := unstructured.NestedString(m, "type")
+		msg, _, _ := unstructured.NestedString(m, "message")
+		s.Conditions = append(s.Conditions, applicationCondition{Type: t, Message: msg})
+	}
+
+	resources, _, _ := unstructured.NestedSlice(obj.Object, "status", "resources")
+	for _, r := range resources {
+		m, ok := r.(map[string]any)
+		if !ok {
+			continue
+		}
+		var rs resourceStatus
+		rs.Group, _, _ = unstructured.NestedString(m, "group")
+		rs.Kind, _, _ = unstructured.NestedString(m, "kind")
+		rs.Namespace, _, _ = unstructured.NestedString(m, "namespace")
+		rs.Name, _, _ = unstructured.NestedString(m, "name")
+		rs.Status, _, _ = unstructured.NestedString(m, "status")
+		rs.Health, _, _ = unstructured.NestedString(m, "health", "status")
+		rs.Message, _, _ = unstructured.NestedString(m, "health", "message")
+		s.Resources = append(s.Resources, rs)
+	}
+
+	return s
+}
+
+// unhealthyResources returns the managed objects whose health has been assessed
+// and is anything other than Healthy.
+func (s applicationStatus) unhealthyResources() []resourceStatus {
+	var out []resourceStatus
+	for _, r := range s.Resources {
+		if r.Health != "" && r.Health != "Healthy" {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// SyncError is returned when an ArgoCD Application fails to become Synced and Healthy.
+// It carries the last observed status so callers can see why the deployment did not land.
+type SyncError struct {
+	Namespace  string
+	Name       string
+	Reason     string // Short description of why waiting stopped
+	Sync       string
+	Health     string
+	Phase      string
+	Message    string
+	Conditions []applicationCondition
+	Unhealthy  []resourceStatus
+}
+
+func (e *SyncError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "application %s/%s %s (sync: %s, health: %s", e.Namespace, e.Name, e.Reason, e.Sync, e.Health)
+	if e.Phase != "" {
+		fmt.Fprintf(&b, ", operation: %s", e.Phase)
+	}
+	b.WriteString(")")
+	if e.Message != "" {
+		fmt.Fprintf(&b, ": %s", e.Message)
+	}
+	for _, c := range e.Conditions {
+		fmt.Fprintf(&b, "; %s: %s", c.Type, c.Message)
+	}
+	if len(e.Unhealthy) > 0 {
+		refs := make([]string, 0, len(e.Unhealthy))
+		for _, r := range e.Unhealthy {
+			refs = append(refs, r.String())
+		}
+		fmt.Fprintf(&b, "; unhealthy resources: %s", strings.Join(refs, ", "))
+	}
+	return b.String()
+}
+
+func newSyncError(namespace, name, reason string, s applicationStatus) *SyncError {
+	return &SyncError{
+		Namespace:  namespace,
+		Name:       name,
+		Reason:     reason,
+		Sync:       s.Sync,
+		Health:     s.Health,
+		Phase:      s.Phase,
+		Message:    s.Message,
+		Conditions: s.Conditions,
+		Unhealthy:  s.unhealthyResources(),
+	}
+}
+
+// waitOptions controls how waitForApplication waits for an Application.
+type waitOptions struct {
+	// Timeout bounds the total time spent waiting. Zero means wait until ctx is done.
+	Timeout time.Duration
+	// Progress, if set, is called every time the observed status changes.
+	Progress func(namespace, name string, s applicationStatus)
+	// Condition decides whether waiting is done. It defaults to evaluateApplication,
+	// which waits for the Application to become Synced and Healthy.
+	Condition func(obj *unstructured.Unstructured, s applicationStatus) (bool, error)
+}
+
+// logProgress reports status transitions on the default structured logger.
+func logProgress(namespace, name string, s applicationStatus) {
+	slog.Info("waiting for application", "namespace", namespace, "name", name, "sync", s.Sync, "health", s.Health, "phase", s.Phase)
+}
+
+// getSyncTimeoutFromEnv reads the optional ARGOCD_SYNC_TIMEOUT duration (e.g. "15m")
+// from environment variables, falling back to defaultSyncTimeout.
+func getSyncTimeoutFromEnv(env map[string]app.EnvironmentVariable) (time.Duration, error) {
+	timeout, ok := env["ARGOCD_SYNC_TIMEOUT"]
+	if !ok || timeout.Value == "" {
+		return defaultSyncTimeout, nil
+	}
+
+	d, err := time.ParseDuration(timeout.Value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ARGOCD_SYNC_TIMEOUT: %w", err)
+	}
+
+	return d, nil
+}
+
+// evaluateApplication decides whether waiting is done, should continue, or must fail fast.
+// Degraded health and failed sync operations are terminal. Missing health is only terminal
+// once ArgoCD reports the Application as synced, because freshly created Applications are
+// briefly Missing before their first sync.
+func evaluateApplication(namespace, name string, s applicationStatus) (bool, error) {
+	switch {
+	case s.Phase == "Failed" || s.Phase == "Error":
+		return false, newSyncError(namespace, name, "sync operation failed", s)
+	case s.Health == "Degraded":
+		return false, newSyncError(namespace, name, "is degraded", s)
+	case s.Health == "Missing" && (s.Sync == "Synced" || s.Phase == "Succeeded"):
+		return false, newSyncError(namespace, name, "has missing resources", s)
+	case s.Sync == "Synced" && s.Health == "Healthy":
+		return true, nil
+	}
+	return false, nil
+}
+
+// evaluateOperation decides whether an operation (sync or rollback), requested after
+// the operation that started at startedBefore, is done. It goes by the result of the
+// operation, then by health, rather than by sync status: an Application synced to
+// another revision than its target revision, like after a rollback, stays OutOfSync.
+// Until the controller starts the operation, the status still describes the previous one.
+func evaluateOperation(namespace, name, startedBefore string) func(*unstructured.Unstructured, applicationStatus) (bool, error) {
+	return func(_ *unstructured.Unstructured, s applicationStatus) (bool, error) {
+		if s.Pending || s.StartedAt == startedBefore {
+			return false, nil
+		}
+		switch {
+		case s.Phase == "Failed" || s.Phase == "Error":
+			return false, newSyncError(namespace, name, "sync operation failed", s)
+		case s.Phase != "Succeeded":
+			return false, nil
+		case s.Health == "Degraded":
+			return false, newSyncError(namespace, name, "is degraded", s)
+		case s.Health == "Missing":
+			return false, newSyncError(namespace, name, "has missing resources", s)
+		}
+		return s.Health == "Healthy", nil
+	}
+}
+
+// reconcileMark is what the status of an Application says about the last time
+// ArgoCD compared it with its spec, and the last operation ArgoCD ran. It is
+// taken before an update is applied, to tell the status ArgoCD reports for the
+// update from the status it reported before.
+type reconcileMark struct {
+	ReconciledAt string
+	StartedAt    string
+	ComparedTo   map[string]any
+}
+
+func markReconcile(obj *unstructured.Unstructured) reconcileMark {
+	s := parseApplicationStatus(obj)
+	comparedTo, _, _ := unstructured.NestedMap(obj.Object, "status", "sync", "comparedTo")
+	return reconcileMark{ReconciledAt: s.ReconciledAt, StartedAt: s.StartedAt, ComparedTo: comparedTo}
+}
+
+// comparedToSpec reports whether ArgoCD compared the Application with the sources
+// in its spec, rather than with the sources of an earlier spec.
+func comparedToSpec(obj *unstructured.Unstructured) bool {
+	spec, _, _ := unstructured.NestedMap(obj.Object, "spec")
+	comparedTo, _, _ := unstructured.NestedMap(obj.Object, "status", "sync", "comparedTo")
+	return equality.Semantic.DeepEqual(spec["source"], comparedTo["source"]) &&
+		equality.Semantic.DeepEqual(spec["sources"], comparedTo["sources"])
+}
+
+// evaluateUpdate is like evaluateApplication, but first waits for ArgoCD to
+// reconcile an update applied after before was taken. Until then the status
+// still describes the previous spec: an Application that was Synced and Healthy
+// would pass at once, and one whose last sync failed would fail at once.
+func evaluateUpdate(namespace, name string, before reconcileMark) func(*unstructured.Unstructured, applicationStatus) (bool, error) {
+	return func(obj *unstructured.Unstructured, s applicationStatus) (bool, error) {
+		if !comparedToSpec(obj) {
+			return false, nil
+		}
+		// An update that didn't change the sources was compared before too, so
+		// wait for a comparison made since
+		comparedTo, _, _ := unstructured.NestedMap(obj.Object, "status", "sync", "comparedTo")
+		if equality.Semantic.DeepEqual(comparedTo, before.ComparedTo) && s.ReconciledAt == before.ReconciledAt {
+			return false, nil
+		}
+		// The result of the operation ArgoCD ran before the update says nothing about it
+		if s.StartedAt == before.StartedAt {
+			s.Phase, s.Message = "", ""
+		}
+		return evaluateApplication(namespace, name, s)
+	}
+}
+
+// waitForApplication watches an ArgoCD Application until it is Synced and Healthy,
+// or until opts.Condition is met.
+// It uses an informer-backed watch instead of polling, so it reacts to status changes
+// as soon as ArgoCD reports them, and stops as soon as ctx is cancelled or the timeout elapses.
+// The last observed Application is returned along with the error, if any was seen.
+func waitForApplication(ctx context.Context, dc dynamic.Interface, namespace, name string, opts waitOptions) (*unstructured.Unstructured, error) {
+	ctx, cancel := watchtools.ContextWithOptionalTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	condition := opts.Condition
+	if condition == nil {
+		condition = func(_ *unstructured.Unstructured, s applicationStatus) (bool, error) {
+			return evaluateApplication(namespace, name, s)
+		}
+	}
+
+	var last applicationStatus
+	seen := false
+	lastObj, err := watchObject(ctx, dc, applicationGVR, namespace, name,
+		func(u *unstructured.Unstructured) (bool, error) {
+			s := parseApplicationStatus(u)
+			if opts.Progress != nil && (!seen || s.Sync != last.Sync || s.Health != last.Health || s.Phase != last.Phase) {
+				opts.Progress(namespace, name, s)
+			}
+			last, seen = s, true
+			return condition(u, s)
+		},
+		func() error {
+			return newSyncError(namespace, name, "was deleted", last)
+		},
+	)
+	if err == nil {
+		return lastObj, nil
+	}
+
+	var syncErr *SyncError
+	if errors.As(err, &syncErr) {
+		return lastObj, syncErr
+	}
+	if ctx.Err() != nil {
+		return lastObj, newSyncError(namespace, name, fmt.Sprintf("did not become ready: %v", context.Cause(ctx)), last)
+	}
+	return lastObj, fmt.Errorf("failed to watch application %s/%s: %w", namespace, name, err)
+}
+
+// watchObject watches a single object until check reports that waiting is done or fails,
+// or until ctx is done. deleted is called for the error to return if the object is deleted.
+// The last observed object is returned along with the error, if any was seen.
+func watchObject(ctx context.Context, dc dynamic.Interface, gvr schema.GroupVersionResource, namespace, name string,
+	check func(obj *unstructured.Unstructured) (bool, error), deleted func() error,
+) (*unstructured.Unstructured, error) {
+	client := dc.Resource(gvr).Namespace(namespace)
+	selector := fields.OneTermEqualSelector("metadata.name", name).String()
+	// Let the reflector know whether the client supports WatchList semantics,
+	// so it falls back to list and watch for clients that don't.
+	lw := cache.ToListWatcherWithWatchListSemantics(&cache.ListWatch{
+		ListWithContextFunc: func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return client.List(ctx, options)
+		},
+		WatchFuncWithContext: func(ctx context.Context, options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return client.Watch(ctx, options)
+		},
+	}, dc)
+
+	var lastObj *unstructured.Unstructured
+	observe := func(obj any) (bool, error) {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok || u.GetName() != name {
+			return false, nil
+		}
+		lastObj = u
+		return check(u)
+	}
+
+	_, err := watchtools.UntilWithSync(ctx, lw, &unstructured.Unstructured{},
+		func(store cache.Store) (bool, error) {
+			obj, exists, err := store.GetByKey(namespace + "/" + name)
+			if err != nil || !exists {
+				return false, err
+			}
+			return observe(obj)
+		},
+		func(event watch.Event) (bool, error) {
+			switch event.Type {
+			case watch.Deleted:
+				return false, deleted()
+			case watch.Added, watch.Modified:
+				return observe(event.Object)
+			}
+			return false, nil
+		},
+	)
+	return lastObj, err
+}
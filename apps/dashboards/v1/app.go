@@ -3,6 +3,7 @@ package dashboards
 import (
 	"context"
 	_ "embed"
+	"fmt"
 	"strconv"
 
 	"github.com/tempestdx/examples/deps/dashboards/server/client"
@@ -14,14 +15,6 @@ var (
 	//go:embed schema/properties.json
 	propertiesSchema []byte
 
-	resourceDefinition = app.ResourceDefinition{
-		Type:             "dashboard",
-		Description:      "A dashboard resource represents a Dashboard object in the Server.",
-		DisplayName:      "Dashboard",
-		LifecycleStage:   app.LifecycleStageMonitor,
-		PropertiesSchema: app.MustParseJSONSchema(propertiesSchema),
-	}
-
 	baseURL = "http://localhost:8080"
 )
 
@@ -42,24 +35,35 @@ func createFn(ctx context.Context, req *app.OperationRequest) (*app.OperationRes
 		dashboard.Description = description
 	}
 
+	// The tags, time range, variables and panels are optional too, and the panels and variables
+	// are YAML lists. The JSON Schema can't check how panels fit together, or that their queries
+	// only use defined variables, so the dashboard is validated before it is sent to the server.
+	if err := contentFromInput(&dashboard, req.Input); err != nil {
+		return nil, err
+	}
+	dashboard.SetDefaults()
+	if err := dashboard.Validate(); err != nil {
+		return nil, err
+	}
+
 	// Call the client to create the dashboard.
 	res, err := client.CreateDashboard(ctx, dashboard)
 	if err != nil {
 		return nil, err
 	}
 
+	properties, err := dashboardProperties(res)
+	if err != nil {
+		return nil, err
+	}
+
 	// resource is the SDK representation of the resource that was created,
 	// and the properties will be stored in the Tempest server.
 	// The properties returned will be validated against the ResourceDefinition's properties schema.
 	resource := &app.Resource{
 		ExternalID:  res.ID,
 		DisplayName: res.Name,
-		Properties: map[string]any{
-			"id":          res.ID,
-			"name":        res.Name,
-			"description": res.Description,
-			"project_id":  res.Project,
-		},
+		Properties:  properties,
 	}
 
 	return &app.OperationResponse{
@@ -70,19 +74,25 @@ func createFn(ctx context.Context, req *app.OperationRequest) (*app.OperationRes
 func updateFn(ctx context.Context, req *app.OperationRequest) (*app.OperationResponse, error) {
 	client := client.NewClient(baseURL)
 
-	dashboard := models.Dashboard{}
-
-	// There are no required fields in the Update input schema, so we need to check if the fields are present.
-	if description, ok := req.Input["description"].(string); ok {
-		dashboard.Description = description
+	// The patch only holds the fields present in the input, so the server keeps the others.
+	// There are no required fields in the Update input schema, so patchFromInput checks if the fields are present.
+	// The server validates the updated dashboard, since its panels may use variables that aren't in the input.
+	patch, err := patchFromInput(req.Input)
+	if err != nil {
+		return nil, err
 	}
 
-	if name, ok := req.Input["name"].(string); ok {
-		dashboard.Name = name
+	// The version in the properties is the one Tempest last saw. Sending it makes the server
+	// refuse the update if someone else changed the dashboard since, instead of overwriting their change.
+	version := versionProperty(req.Resource.Properties)
+
+	// Call the client to apply the patch to the dashboard.
+	res, err := client.PatchDashboard(ctx, req.Resource.ExternalID, patch, version)
+	if err != nil {
+		return nil, conflictError(req.Resource.ExternalID, version, err)
 	}
 
-	// Call the client to update the dashboard with the new model.
-	res, err := client.UpdateDashboard(ctx, req.Resource.ExternalID, dashboard)
+	properties, err := dashboardProperties(res)
 	if err != nil {
 		return nil, err
 	}
@@ -93,12 +103,7 @@ func updateFn(ctx context.Context, req *app.OperationRequest) (*app.OperationRes
 	resource := &app.Resource{
 		ExternalID:  res.ID,
 		DisplayName: res.Name,
-		Properties: map[string]any{
-			"id":          res.ID,
-			"name":        res.Name,
-			"description": res.Description,
-			"project_id":  res.Project,
-		},
+		Properties:  properties,
 	}
 
 	return &app.OperationResponse{
@@ -106,6 +111,31 @@ func updateFn(ctx context.Context, req *app.OperationRequest) (*app.OperationRes
 	}, nil
 }
 
+// versionProperty returns the version in the properties of a dashboard, or 0 if
+// it has none, like resources stored before versions were introduced.
+// Numbers in properties may come back from Tempest as float64.
+func versionProperty(properties map[string]any) int64 {
+	switch v := properties["version"].(type) {
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	case float64:
+		return int64(v)
+	default:
+		return 0
+	}
+}
+
+// conflictError explains an update refused because the dashboard changed since
+// the version Tempest knows of. Other errors are returned as is.
+func conflictError(id string, version int64, err error) error {
+	if !client.IsPreconditionFailed(err) {
+		return err
+	}
+	return fmt.Errorf("dashboard %s was changed since version %d, read it again to refresh its properties and retry the update: %w", id, version, err)
+}
+
 func deleteFn(ctx context.Context, req *app.OperationRequest) (*app.OperationResponse, error) {
 	client := client.NewClient(baseURL)
 
@@ -127,18 +157,18 @@ func readFn(ctx context.Context, req *app.OperationRequest) (*app.OperationRespo
 		return nil, err
 	}
 
+	properties, err := dashboardProperties(res)
+	if err != nil {
+		return nil, err
+	}
+
 	// resource is the SDK representation of the resource that was read,
 	// and the properties will be stored in the Tempest server.
 	// The properties returned will be validated against the ResourceDefinition's properties schema.
 	resource := &app.Resource{
 		ExternalID:  res.ID,
 		DisplayName: res.Name,
-		Properties: map[string]any{
-			"id":          res.ID,
-			"name":        res.Name,
-			"description": res.Description,
-			"project_id":  res.Project,
-		},
+		Properties:  properties,
 	}
 
 	return &app.OperationResponse{
@@ -163,15 +193,15 @@ func listFn(ctx context.Context, req *app.ListRequest) (*app.ListResponse, error
 	// The properties returned will be validated against the ResourceDefinition's properties schema.
 	resources := make([]*app.Resource, 0, len(res.Dashboards))
 	for _, r := range res.Dashboards {
+		properties, err := dashboardProperties(&r)
+		if err != nil {
+			return nil, err
+		}
+
 		resources = append(resources, &app.Resource{
 			ExternalID:  r.ID,
 			DisplayName: r.Name,
-			Properties: map[string]any{
-				"id":          r.ID,
-				"name":        r.Name,
-				"description": r.Description,
-				"project_id":  r.Project,
-			},
+			Properties:  properties,
 		})
 	}
 
@@ -209,6 +239,14 @@ var (
 
 // Each App must have a function called App() that returns an *app.App.
 func App() *app.App {
+	resourceDefinition := app.ResourceDefinition{
+		Type:             "dashboard",
+		Description:      "A dashboard resource represents a Dashboard object in the Server.",
+		DisplayName:      "Dashboard",
+		LifecycleStage:   app.LifecycleStageMonitor,
+		PropertiesSchema: app.MustParseJSONSchema(propertiesSchema),
+	}
+
 	// Add various operations to the resource definition.
 	resourceDefinition.CreateFn(
 		createFn,
@@ -0,0 +1,186 @@
+package dashboards
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tempestdx/examples/deps/dashboards/server/models"
+	"gopkg.in/yaml.v3"
+)
+
+// The content of a dashboard is set with flat inputs, since Tempest inputs and
+// properties can't hold objects: the tags as a string array, the time range as
+// strings, and the variables and panels as YAML lists, using the field names of
+// the server's JSON API.
+
+// contentFromInput sets the tags, time range, variables and panels present in
+// the input on a dashboard.
+func contentFromInput(dashboard *models.Dashboard, input map[string]any) error {
+	patch, err := patchFromInput(input)
+	if err != nil {
+		return err
+	}
+
+	if patch.Tags != nil {
+		dashboard.Tags = *patch.Tags
+	}
+	if tr := patch.TimeRange; tr != nil {
+		if tr.From != nil {
+			dashboard.TimeRange.From = *tr.From
+		}
+		if tr.To != nil {
+			dashboard.TimeRange.To = *tr.To
+		}
+		if tr.Refresh != nil {
+			dashboard.TimeRange.Refresh = *tr.Refresh
+		}
+	}
+	if patch.Variables != nil {
+		dashboard.Variables = *patch.Variables
+	}
+	if patch.Panels != nil {
+		dashboard.Panels = *patch.Panels
+	}
+	return nil
+}
+
+// patchFromInput returns a patch setting the fields present in the input, and
+// keeping the others.
+func patchFromInput(input map[string]any) (models.DashboardPatch, error) {
+	var patch models.DashboardPatch
+
+	if name, ok := input["name"].(string); ok {
+		patch.Name = &name
+	}
+	if description, ok := input["description"].(string); ok {
+		patch.Description = &description
+	}
+
+	if _, ok := input["tags"]; ok {
+		tags := stringSliceInput(input, "tags")
+		patch.Tags = &tags
+	}
+
+	// An empty time_from or time_to resets it to its default, and an empty refresh stops reloading
+	var timeRange models.TimeRangePatch
+	if from, ok := input["time_from"].(string); ok {
+		timeRange.From = &from
+	}
+	if to, ok := input["time_to"].(string); ok {
+		timeRange.To = &to
+	}
+	if refresh, ok := input["refresh"].(string); ok {
+		timeRange.Refresh = &refresh
+	}
+	if timeRange != (models.TimeRangePatch{}) {
+		patch.TimeRange = &timeRange
+	}
+
+	if data, ok := input["variables"].(string); ok {
+		variables := []models.Variable{}
+		if err := decodeYAMLList(data, &variables); err != nil {
+			return models.DashboardPatch{}, fmt.Errorf("invalid variables: %w", err)
+		}
+		patch.Variables = &variables
+	}
+	if data, ok := input["panels"].(string); ok {
+		panels := []models.Panel{}
+		if err := decodeYAMLList(data, &panels); err != nil {
+			return models.DashboardPatch{}, fmt.Errorf("invalid panels: %w", err)
+		}
+		patch.Panels = &panels
+	}
+
+	return patch, nil
+}
+
+// stringSliceInput returns a string array input, empty if it isn't set.
+func stringSliceInput(input map[string]any, key string) []string {
+	raw, _ := input[key].([]any)
+	out := make([]string, 0, len(raw))
+	for _, r := range raw {
+		if s, ok := r.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// decodeYAMLList decodes a YAML list into v using v's JSON tags, rejecting unknown
+// fields. An empty string is an empty list.
+func decodeYAMLList(data string, v any) error {
+	if strings.TrimSpace(data) == "" {
+		return nil
+	}
+
+	var yamlObj any
+	if err := yaml.Unmarshal([]byte(data), &yamlObj); err != nil {
+		return err
+	}
+	if _, ok := yamlObj.([]any); !ok {
+		return fmt.Errorf("expected a YAML list")
+	}
+	jsonBytes, err := json.Marshal(yamlObj)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(jsonBytes))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// encodeYAMLList encodes a list as YAML using the JSON tags of its elements, in
+// the format of the variables and panels inputs. An empty list is an empty string.
+func encodeYAMLList[T any](list []T) (string, error) {
+	if len(list) == 0 {
+		return "", nil
+	}
+
+	jsonBytes, err := json.Marshal(list)
+	if err != nil {
+		return "", err
+	}
+	var obj any
+	if err := json.Unmarshal(jsonBytes, &obj); err != nil {
+		return "", err
+	}
+	out, err := yaml.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// dashboardProperties returns the properties of a dashboard.
+func dashboardProperties(d *models.Dashboard) (map[string]any, error) {
+	variables, err := encodeYAMLList(d.Variables)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the variables of dashboard %s: %w", d.ID, err)
+	}
+	panels, err := encodeYAMLList(d.Panels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the panels of dashboard %s: %w", d.ID, err)
+	}
+
+	tags := d.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return map[string]any{
+		"id":          d.ID,
+		"name":        d.Name,
+		"description": d.Description,
+		"project_id":  d.Project,
+		"version":     d.Version,
+		"tags":        tags,
+		"time_from":   d.TimeRange.From,
+		"time_to":     d.TimeRange.To,
+		"refresh":     d.TimeRange.Refresh,
+		"variables":   variables,
+		"panels":      panels,
+	}, nil
+}
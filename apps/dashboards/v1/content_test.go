@@ -0,0 +1,81 @@
+package dashboards
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tempestdx/examples/deps/dashboards/server/models"
+)
+
+func TestContentFromInput(t *testing.T) {
+	input := map[string]any{
+		"name":      "checkout",
+		"tags":      []any{"team-payments"},
+		"time_from": "now-24h",
+		"variables": "- name: instance\n  type: query\n  query: label_values(instance)\n  datasource:\n    type: prometheus\n    uid: prom-eu\n",
+		"panels":    "- type: stat\n  title: Up\n  query: up{instance=\"$instance\"}\n  datasource: {type: prometheus, uid: prom-eu}\n  grid_pos: {x: 0, y: 0, w: 6, h: 4}\n",
+	}
+
+	var d models.Dashboard
+	if err := contentFromInput(&d, input); err != nil {
+		t.Fatalf("contentFromInput: %v", err)
+	}
+	d.SetDefaults()
+	if err := d.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if d.TimeRange != (models.TimeRange{From: "now-24h", To: "now"}) {
+		t.Errorf("time range = %+v", d.TimeRange)
+	}
+	want := models.Panel{
+		Type:       models.PanelTypeStat,
+		Title:      "Up",
+		Query:      `up{instance="$instance"}`,
+		Datasource: models.DatasourceRef{Type: "prometheus", UID: "prom-eu"},
+		GridPos:    models.GridPos{W: 6, H: 4},
+	}
+	if len(d.Panels) != 1 || d.Panels[0] != want {
+		t.Errorf("panels = %+v, want %+v", d.Panels, want)
+	}
+
+	// The properties can be used as the input of another dashboard
+	properties, err := dashboardProperties(&d)
+	if err != nil {
+		t.Fatalf("dashboardProperties: %v", err)
+	}
+	var copied models.Dashboard
+	if err := contentFromInput(&copied, map[string]any{
+		"tags":      []any{"team-payments"},
+		"time_from": properties["time_from"],
+		"time_to":   properties["time_to"],
+		"variables": properties["variables"],
+		"panels":    properties["panels"],
+	}); err != nil {
+		t.Fatalf("contentFromInput of the properties: %v", err)
+	}
+	if !reflect.DeepEqual(copied, d) {
+		t.Errorf("dashboard from the properties = %+v, want %+v", copied, d)
+	}
+}
+
+func TestContentFromInputErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input map[string]any
+		want  string
+	}{
+		{name: "not a list", input: map[string]any{"panels": "type: stat"}, want: "invalid panels: expected a YAML list"},
+		{name: "unknown field", input: map[string]any{"panels": "- type: stat\n  gridPos: {x: 0}"}, want: `invalid panels: json: unknown field "gridPos"`},
+		{name: "invalid YAML", input: map[string]any{"variables": "- name: [a"}, want: "invalid variables:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d models.Dashboard
+			err := contentFromInput(&d, tt.input)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("contentFromInput() error = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
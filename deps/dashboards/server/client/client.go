@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/tempestdx/examples/deps/dashboards/server/models"
@@ -23,150 +26,150 @@ func NewClient(baseURL string) *Client {
 	}
 }
 
-func (c *Client) CreateDashboard(ctx context.Context, dashboard models.Dashboard) (*models.Dashboard, error) {
-	url := fmt.Sprintf("%s/dashboard/create", c.BaseURL)
-
-	jsonData, err := json.Marshal(dashboard)
-	if err != nil {
-		return nil, err
-	}
+// APIError is returned when the server responds with an error status.
+type APIError struct {
+	StatusCode int
+	Code       string // One of the models.ErrorCode constants, empty if the body wasn't an ErrorResponse
+	Message    string
+	// Details lists the invalid fields of an invalid dashboard.
+	Details []models.FieldError
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("status %d: %s", e.StatusCode, e.Message)
 	}
-	req.Header.Set("Content-Type", "application/json")
+	return fmt.Sprintf("status %d: %s: %s", e.StatusCode, e.Code, e.Message)
+}
 
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+// IsNotFound reports whether err is an APIError for a dashboard that doesn't exist.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
 
-	if resp.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("failed to create dashboard, status: %s", resp.Status)
-	}
+// IsPreconditionFailed reports whether err is an APIError for an update that was
+// refused because the dashboard changed since the version it was conditioned on.
+func IsPreconditionFailed(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusPreconditionFailed
+}
 
+func (c *Client) CreateDashboard(ctx context.Context, dashboard models.Dashboard) (*models.Dashboard, error) {
 	var createdDashboard models.Dashboard
-	if err := json.NewDecoder(resp.Body).Decode(&createdDashboard); err != nil {
-		return nil, err
+	if err := c.do(ctx, http.MethodPost, "/v1/dashboards", nil, dashboard, http.StatusCreated, &createdDashboard); err != nil {
+		return nil, fmt.Errorf("failed to create dashboard: %w", err)
 	}
 
 	return &createdDashboard, nil
 }
 
 func (c *Client) GetDashboard(ctx context.Context, id string) (*models.Dashboard, error) {
-	url := fmt.Sprintf("%s/dashboard/get?id=%s", c.BaseURL, id)
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get dashboard, status: %s", resp.Status)
-	}
-
 	var dashboard models.Dashboard
-	if err := json.NewDecoder(resp.Body).Decode(&dashboard); err != nil {
-		return nil, err
+	if err := c.do(ctx, http.MethodGet, dashboardPath(id), nil, nil, http.StatusOK, &dashboard); err != nil {
+		return nil, fmt.Errorf("failed to get dashboard: %w", err)
 	}
 
 	return &dashboard, nil
 }
 
+// UpdateDashboard replaces the content of a dashboard: its name, description,
+// tags, time range, variables and panels. Unless
+// dashboard.Version is 0, the update fails with a precondition failed APIError
+// if the dashboard isn't at that version anymore.
 func (c *Client) UpdateDashboard(ctx context.Context, id string, dashboard models.Dashboard) (*models.Dashboard, error) {
-	url := fmt.Sprintf("%s/dashboard/update?id=%s", c.BaseURL, id)
-
-	jsonData, err := json.Marshal(dashboard)
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to update dashboard, status: %s", resp.Status)
-	}
-
 	var updatedDashboard models.Dashboard
-	if err := json.NewDecoder(resp.Body).Decode(&updatedDashboard); err != nil {
-		return nil, err
+	if err := c.do(ctx, http.MethodPut, dashboardPath(id), ifMatch(dashboard.Version), dashboard, http.StatusOK, &updatedDashboard); err != nil {
+		return nil, fmt.Errorf("failed to update dashboard: %w", err)
 	}
 
 	return &updatedDashboard, nil
 }
 
-func (c *Client) DeleteDashboard(ctx context.Context, id string) error {
-	url := fmt.Sprintf("%s/dashboard/delete?id=%s", c.BaseURL, id)
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, nil)
-	if err != nil {
-		return err
+// PatchDashboard changes the fields of a dashboard that are set in the patch, and
+// keeps the others. Unless version is 0, the patch fails with a precondition
+// failed APIError if the dashboard isn't at that version anymore.
+func (c *Client) PatchDashboard(ctx context.Context, id string, patch models.DashboardPatch, version int64) (*models.Dashboard, error) {
+	header := ifMatch(version)
+	if header == nil {
+		header = http.Header{}
 	}
+	header.Set("Content-Type", "application/merge-patch+json")
 
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return err
+	var patchedDashboard models.Dashboard
+	if err := c.do(ctx, http.MethodPatch, dashboardPath(id), header, patch, http.StatusOK, &patchedDashboard); err != nil {
+		return nil, fmt.Errorf("failed to patch dashboard: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("failed to delete dashboard, status: %s", resp.Status)
+	return &patchedDashboard, nil
+}
+
+func (c *Client) DeleteDashboard(ctx context.Context, id string) error {
+	if err := c.do(ctx, http.MethodDelete, dashboardPath(id), nil, nil, http.StatusNoContent, nil); err != nil {
+		return fmt.Errorf("failed to delete dashboard: %w", err)
 	}
 
 	return nil
 }
 
 func (c *Client) ListDashboards(ctx context.Context, next string) (*models.DashboardList, error) {
-	url := fmt.Sprintf("%s/dashboard/list?next=%s", c.BaseURL, next)
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to list dashboards, status: %s", resp.Status)
+	path := "/v1/dashboards"
+	if next != "" {
+		path += "?next=" + url.QueryEscape(next)
 	}
 
 	var dashboards models.DashboardList
-	if err := json.NewDecoder(resp.Body).Decode(&dashboards); err != nil {
-		return nil, err
+	if err := c.do(ctx, http.MethodGet, path, nil, nil, http.StatusOK, &dashboards); err != nil {
+		return nil, fmt.Errorf("failed to list dashboards: %w", err)
 	}
 
 	return &dashboards, nil
 }
 
 func (c *Client) Healthz(ctx context.Context) error {
-	url := fmt.Sprintf("%s/healthz", c.BaseURL)
+	if err := c.do(ctx, http.MethodGet, "/healthz", nil, nil, http.StatusOK, nil); err != nil {
+		return fmt.Errorf("server is not healthy: %w", err)
+	}
+
+	return nil
+}
+
+func dashboardPath(id string) string {
+	return "/v1/dashboards/" + url.PathEscape(id)
+}
+
+// ifMatch returns the header conditioning a request on a version of a
+// dashboard, or nil for version 0.
+func ifMatch(version int64) http.Header {
+	if version == 0 {
+		return nil
+	}
+	return http.Header{"If-Match": {models.ETag(version)}}
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+// do sends a request with the given header and body encoded as JSON, unless it
+// is nil, and decodes the response into out, unless it is nil. Responses with
+// another status than wantStatus are returned as an *APIError.
+func (c *Client) do(ctx context.Context, method, path string, header http.Header, body any, wantStatus int, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reqBody)
 	if err != nil {
 		return err
 	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if body != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
@@ -174,9 +177,25 @@ func (c *Client) Healthz(ctx context.Context) error {
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("server is not healthy, status: %s", resp.Status)
+	if resp.StatusCode != wantStatus {
+		return responseError(resp)
+	}
+	if out == nil {
+		return nil
 	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
 
-	return nil
+// responseError builds the APIError of an error response.
+func responseError(resp *http.Response) error {
+	apiErr := &APIError{StatusCode: resp.StatusCode, Message: resp.Status}
+
+	var errResp models.ErrorResponse
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if json.Unmarshal(data, &errResp) == nil && errResp.Error.Code != "" {
+		apiErr.Code = errResp.Error.Code
+		apiErr.Message = errResp.Error.Message
+		apiErr.Details = errResp.Error.Details
+	}
+	return apiErr
 }
@@ -1,131 +1,322 @@
 package main
 
 import (
+	"bytes"
+	"cmp"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"log/slog"
-	"math/rand/v2"
+	"mime"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
-	"sync"
+	"strings"
 
 	"github.com/tempestdx/examples/deps/dashboards/server/models"
 )
 
 var (
-	dashboards = []*models.Dashboard{}
-	mu         sync.Mutex
+	store Store = newMemoryStore()
 
 	logger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
 )
 
-// Generate a unique alphanumeric ExternalID.
-func generateExternalID() string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	const length = 8
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[rand.Int()%len(charset)]
+// writeJSON writes v as the JSON body of a response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Error("Failed to encode response", "error", err)
 	}
+}
+
+// writeError writes an error response, with a models.ErrorResponse body.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, models.ErrorResponse{Error: models.Error{Code: code, Message: message}})
+}
+
+// writeDashboard writes a dashboard as the body of a response, with its ETag.
+func writeDashboard(w http.ResponseWriter, status int, dashboard models.Dashboard) {
+	w.Header().Set("ETag", models.ETag(dashboard.Version))
+	writeJSON(w, status, dashboard)
+}
+
+// requestError is returned by the functions handlers pass to Store.Update when a
+// request can't be applied to the dashboard, and is written as the response.
+type requestError struct {
+	status  int
+	code    string
+	message string
+	details []models.FieldError
+}
 
-	return string(b)
+func (e *requestError) Error() string {
+	return e.message
 }
 
-func lookupDashboard(externalID string) *models.Dashboard {
-	for _, dashboard := range dashboards {
-		if dashboard.ID == externalID {
-			return dashboard
+// storeError writes the error response of a failed store operation.
+func storeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errNotFound) {
+		writeError(w, http.StatusNotFound, models.ErrorCodeNotFound, "Dashboard not found")
+		return
+	}
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		writeJSON(w, reqErr.status, models.ErrorResponse{Error: models.Error{
+			Code:    reqErr.code,
+			Message: reqErr.message,
+			Details: reqErr.details,
+		}})
+		return
+	}
+	logger.Error("Store operation failed", "error", err)
+	writeError(w, http.StatusInternalServerError, models.ErrorCodeInternal, "Failed to store dashboard")
+}
+
+// decodeBody decodes the JSON body of a request into v, rejecting unknown fields.
+// It writes an error response and returns false if the body is invalid.
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, models.ErrorCodeInvalidRequest, "Invalid request payload: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// validateDashboard fills in the defaults of a dashboard and validates it. It
+// returns a requestError listing the invalid fields.
+func validateDashboard(dashboard *models.Dashboard) error {
+	dashboard.SetDefaults()
+
+	var validationErr models.ValidationError
+	if err := dashboard.Validate(); errors.As(err, &validationErr) {
+		return &requestError{
+			status:  http.StatusBadRequest,
+			code:    models.ErrorCodeInvalidRequest,
+			message: validationErr.Error(),
+			details: validationErr,
 		}
 	}
 	return nil
 }
 
+// checkIfMatch returns a requestError if the request has an If-Match header
+// that doesn't match the ETag of the dashboard. Weak ETags never match.
+func checkIfMatch(r *http.Request, dashboard models.Dashboard) error {
+	values := r.Header.Values("If-Match")
+	if len(values) == 0 {
+		return nil
+	}
+
+	etag := models.ETag(dashboard.Version)
+	for _, tag := range strings.Split(strings.Join(values, ","), ",") {
+		if tag = strings.TrimSpace(tag); tag == "*" || tag == etag {
+			return nil
+		}
+	}
+	return &requestError{
+		status:  http.StatusPreconditionFailed,
+		code:    models.ErrorCodePreconditionFailed,
+		message: fmt.Sprintf("Dashboard was changed, it is now at version %d", dashboard.Version),
+	}
+}
+
+// dashboardID returns the ID of the dashboard a request is about, from the path
+// of /v1/dashboards/{id}, or the id query parameter of the legacy paths.
+func dashboardID(r *http.Request) string {
+	return cmp.Or(r.PathValue("id"), r.URL.Query().Get("id"))
+}
+
 func createDashboard(w http.ResponseWriter, r *http.Request) {
 	var dashboard models.Dashboard
-	err := json.NewDecoder(r.Body).Decode(&dashboard)
-	if err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+	if !decodeBody(w, r, &dashboard) {
 		return
 	}
 
 	if dashboard.Project == "" {
-		http.Error(w, "Project is required", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, models.ErrorCodeInvalidRequest, "Project is required")
+		return
+	}
+	if err := validateDashboard(&dashboard); err != nil {
+		storeError(w, err)
 		return
 	}
 
-	mu.Lock()
-	defer mu.Unlock()
-	dashboard.ID = generateExternalID()
-	dashboards = append(dashboards, &dashboard)
-
-	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(dashboard); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	dashboard, err := store.Create(dashboard)
+	if err != nil {
+		storeError(w, err)
 		return
 	}
+
+	w.Header().Set("Location", "/v1/dashboards/"+dashboard.ID)
+	writeDashboard(w, http.StatusCreated, dashboard)
 }
 
 func getDashboard(w http.ResponseWriter, r *http.Request) {
-	externalID := r.URL.Query().Get("id")
-	mu.Lock()
-	defer mu.Unlock()
+	dashboard, err := store.Get(dashboardID(r))
+	if err != nil {
+		storeError(w, err)
+		return
+	}
 
-	dashboard := lookupDashboard(externalID)
-	if dashboard == nil {
-		http.Error(w, "Dashboard not found", http.StatusNotFound)
+	writeDashboard(w, http.StatusOK, dashboard)
+}
+
+// replaceDashboard replaces the content of a dashboard: its name, description,
+// tags, time range, variables and panels. Fields left out of the body are
+// cleared, or reset to their default. The ID, project and version in the body
+// are ignored; send an If-Match header to only replace a known version.
+func replaceDashboard(w http.ResponseWriter, r *http.Request) {
+	var updatedDashboard models.Dashboard
+	if !decodeBody(w, r, &updatedDashboard) {
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(dashboard); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	dashboard, err := store.Update(dashboardID(r), func(dashboard *models.Dashboard) error {
+		if err := checkIfMatch(r, *dashboard); err != nil {
+			return err
+		}
+		updatedDashboard.ID = dashboard.ID
+		updatedDashboard.Project = dashboard.Project
+		updatedDashboard.Version = dashboard.Version
+		if err := validateDashboard(&updatedDashboard); err != nil {
+			return err
+		}
+		*dashboard = updatedDashboard
+		return nil
+	})
+	if err != nil {
+		storeError(w, err)
 		return
 	}
-}
 
-func updateDashboard(w http.ResponseWriter, r *http.Request) {
-	externalID := r.URL.Query().Get("id")
+	writeDashboard(w, http.StatusOK, dashboard)
+}
 
+// updateDashboardLegacy serves the deprecated /dashboard/update path, which only
+// ever changed the name and description of a dashboard. Unlike replaceDashboard,
+// it keeps the tags, time range, variables and panels that its clients don't know.
+func updateDashboardLegacy(w http.ResponseWriter, r *http.Request) {
 	var updatedDashboard models.Dashboard
-	err := json.NewDecoder(r.Body).Decode(&updatedDashboard)
+	if !decodeBody(w, r, &updatedDashboard) {
+		return
+	}
+
+	dashboard, err := store.Update(dashboardID(r), func(dashboard *models.Dashboard) error {
+		if err := checkIfMatch(r, *dashboard); err != nil {
+			return err
+		}
+		dashboard.Name = updatedDashboard.Name
+		dashboard.Description = updatedDashboard.Description
+		return validateDashboard(dashboard)
+	})
 	if err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		storeError(w, err)
 		return
 	}
 
-	mu.Lock()
-	defer mu.Unlock()
-	dashboard := lookupDashboard(externalID)
-	if dashboard == nil {
-		http.Error(w, "Dashboard not found", http.StatusNotFound)
+	writeDashboard(w, http.StatusOK, dashboard)
+}
+
+// patchDashboard applies the JSON merge patch (RFC 7396) in the body to a
+// dashboard: fields present in the patch are set, fields set to null are cleared,
+// and other fields are kept. Lists, such as the panels, are replaced as a whole.
+// The ID, project and version can't be changed.
+func patchDashboard(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "" && mediaType != "application/merge-patch+json" && mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, models.ErrorCodeInvalidRequest,
+			"Content-Type must be application/merge-patch+json")
 		return
 	}
 
-	dashboard.Name = updatedDashboard.Name
-	dashboard.Description = updatedDashboard.Description
+	var patch map[string]any
+	if !decodeBody(w, r, &patch) {
+		return
+	}
 
-	if err := json.NewEncoder(w).Encode(dashboard); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	dashboard, err := store.Update(dashboardID(r), func(dashboard *models.Dashboard) error {
+		if err := checkIfMatch(r, *dashboard); err != nil {
+			return err
+		}
+		if err := applyMergePatch(dashboard, patch); err != nil {
+			return err
+		}
+		return validateDashboard(dashboard)
+	})
+	if err != nil {
+		storeError(w, err)
 		return
 	}
+
+	writeDashboard(w, http.StatusOK, dashboard)
 }
 
-func deleteDashboard(w http.ResponseWriter, r *http.Request) {
-	externalID := r.URL.Query().Get("id")
-	mu.Lock()
-	defer mu.Unlock()
-	dashboard := lookupDashboard(externalID)
-	if dashboard == nil {
-		http.Error(w, "Dashboard not found", http.StatusNotFound)
-		return
+// applyMergePatch applies a JSON merge patch to a dashboard, through its JSON
+// representation. It returns a requestError if the patched dashboard is invalid.
+func applyMergePatch(dashboard *models.Dashboard, patch map[string]any) error {
+	invalid := func(message string) error {
+		return &requestError{status: http.StatusBadRequest, code: models.ErrorCodeInvalidRequest, message: message}
+	}
+
+	data, err := json.Marshal(dashboard)
+	if err != nil {
+		return err
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if data, err = json.Marshal(mergePatch(doc, patch)); err != nil {
+		return err
+	}
+
+	var patched models.Dashboard
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&patched); err != nil {
+		return invalid("Invalid patch: " + err.Error())
+	}
+	if patched.ID != dashboard.ID || patched.Project != dashboard.Project || patched.Version != dashboard.Version {
+		return invalid("The id, project and version of a dashboard can't be changed")
 	}
 
-	for i, d := range dashboards {
-		if d.ID == externalID {
-			dashboards = append(dashboards[:i], dashboards[i+1:]...)
-			break
+	*dashboard = patched
+	return nil
+}
+
+// mergePatch returns target, a decoded JSON value, with a merge patch applied
+// as described by RFC 7396. It modifies target.
+func mergePatch(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
 		}
+		targetObject[key] = mergePatch(targetObject[key], value)
+	}
+	return targetObject
+}
+
+func deleteDashboard(w http.ResponseWriter, r *http.Request) {
+	if err := store.Delete(dashboardID(r)); err != nil {
+		storeError(w, err)
+		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
@@ -139,54 +330,121 @@ func listDashboards(w http.ResponseWriter, r *http.Request) {
 	if next != "" {
 		var err error
 		nextInt, err = strconv.Atoi(next)
-		if err != nil {
-			http.Error(w, "Invalid next value", http.StatusBadRequest)
+		if err != nil || nextInt < 0 {
+			writeError(w, http.StatusBadRequest, models.ErrorCodeInvalidRequest, "Invalid next value")
 			return
 		}
 	}
 
-	mu.Lock()
-	defer mu.Unlock()
-
-	var dashboardList models.DashboardList
-	for i := nextInt; i < nextInt+maxres; i++ {
-		if i >= len(dashboards) {
-			break
-		}
-		dashboardList.Dashboards = append(dashboardList.Dashboards, *dashboards[i])
+	dashboards, total, err := store.List(nextInt, maxres)
+	if err != nil {
+		storeError(w, err)
+		return
 	}
-	if nextInt+maxres < len(dashboards) {
+
+	dashboardList := models.DashboardList{Dashboards: dashboards}
+	if nextInt+maxres < total {
 		dashboardList.Next = nextInt + maxres
 	}
 
-	if err := json.NewEncoder(w).Encode(dashboardList); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-		return
-	}
+	writeJSON(w, http.StatusOK, dashboardList)
 }
 
 func healthz(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// methodNotAllowed responds to requests for a path with a method it doesn't
+// support. ServeMux only routes them here since patterns with a method take
+// precedence, and its own 405 responses don't have a JSON body.
+func methodNotAllowed(methods ...string) http.HandlerFunc {
+	if slices.Contains(methods, http.MethodGet) {
+		methods = append(methods, http.MethodHead)
+	}
+	allow := strings.Join(methods, ", ")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		writeError(w, http.StatusMethodNotAllowed, models.ErrorCodeMethodNotAllowed,
+			fmt.Sprintf("Method %s is not allowed, expected %s", r.Method, allow))
+	}
+}
+
+func notFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusNotFound, models.ErrorCodeNotFound, "No route for "+r.URL.Path)
+}
+
+// deprecated marks the responses of a legacy path as deprecated, pointing to its
+// successor in the v1 API.
+func deprecated(successor string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger.Warn("Deprecated path used", "path", r.URL.Path, "successor", successor)
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+">; rel=\"successor-version\"")
+		next(w, r)
+	}
+}
+
+// newMux routes the v1 REST API, and the deprecated RPC-style paths it replaces.
+func newMux() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /v1/dashboards", listDashboards)
+	mux.HandleFunc("POST /v1/dashboards", createDashboard)
+	mux.Handle("/v1/dashboards", methodNotAllowed(http.MethodGet, http.MethodPost))
+
+	mux.HandleFunc("GET /v1/dashboards/{id}", getDashboard)
+	mux.HandleFunc("PUT /v1/dashboards/{id}", replaceDashboard)
+	mux.HandleFunc("PATCH /v1/dashboards/{id}", patchDashboard)
+	mux.HandleFunc("DELETE /v1/dashboards/{id}", deleteDashboard)
+	mux.Handle("/v1/dashboards/{id}", methodNotAllowed(http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete))
+
+	mux.HandleFunc("/v1/", notFound)
+
+	// Deprecated: the RPC-style paths, restricted to the methods the client used
+	legacy := []struct {
+		method, path, successor string
+		handler                 http.HandlerFunc
+	}{
+		{http.MethodPost, "/dashboard/create", "/v1/dashboards", createDashboard},
+		{http.MethodGet, "/dashboard/get", "/v1/dashboards/{id}", getDashboard},
+		{http.MethodPut, "/dashboard/update", "/v1/dashboards/{id}", updateDashboardLegacy},
+		{http.MethodDelete, "/dashboard/delete", "/v1/dashboards/{id}", deleteDashboard},
+		{http.MethodGet, "/dashboard/list", "/v1/dashboards", listDashboards},
+	}
+	for _, l := range legacy {
+		mux.HandleFunc(l.method+" "+l.path, deprecated(l.successor, l.handler))
+		mux.Handle(l.path, methodNotAllowed(l.method))
+	}
+
+	mux.HandleFunc("GET /healthz", healthz)
+	mux.Handle("/healthz", methodNotAllowed(http.MethodGet))
+
+	return mux
+}
+
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		logger.Info("Request received", "path", r.URL.Path, "params", r.URL.Query())
+		logger.Info("Request received", "method", r.Method, "path", r.URL.Path, "params", r.URL.Query())
 		next.ServeHTTP(w, r)
 	})
 }
 
 func main() {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/dashboard/create", createDashboard)
-	mux.HandleFunc("/dashboard/get", getDashboard)
-	mux.HandleFunc("/dashboard/update", updateDashboard)
-	mux.HandleFunc("/dashboard/delete", deleteDashboard)
-	mux.HandleFunc("/dashboard/list", listDashboards)
-	mux.HandleFunc("/healthz", healthz)
+	storeKind := flag.String("store", "memory", "Where dashboards are stored: memory, json (a JSON file) or kv (an append-only key-value log)")
+	dataPath := flag.String("data", "", "File the json and kv stores keep dashboards in (default dashboards.json or dashboards.log)")
+	flag.Parse()
+
+	// Both file stores sync every change to disk before responding, so stopping
+	// the server doesn't lose dashboards
+	var err error
+	store, err = openStore(*storeKind, *dataPath)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	loggedMux := loggingMiddleware(mux)
+	loggedMux := loggingMiddleware(newMux())
 
-	fmt.Println("Server started at :8080")
+	fmt.Printf("Server started at :8080 (store: %s)\n", *storeKind)
 	log.Fatal(http.ListenAndServe(":8080", loggedMux))
 }
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tempestdx/examples/deps/dashboards/server/client"
+	"github.com/tempestdx/examples/deps/dashboards/server/models"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	store = newMemoryStore()
+	srv := httptest.NewServer(newMux())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	c := client.NewClient(newTestServer(t).URL)
+
+	if err := c.Healthz(ctx); err != nil {
+		t.Fatalf("Healthz: %v", err)
+	}
+
+	created, err := c.CreateDashboard(ctx, models.Dashboard{Name: "a", Description: "first", Project: "proj-1"})
+	if err != nil {
+		t.Fatalf("CreateDashboard: %v", err)
+	}
+	for _, name := range []string{"b", "c"} {
+		if _, err := c.CreateDashboard(ctx, models.Dashboard{Name: name, Project: "proj-1"}); err != nil {
+			t.Fatalf("CreateDashboard: %v", err)
+		}
+	}
+
+	updated, err := c.UpdateDashboard(ctx, created.ID, models.Dashboard{Name: "renamed"})
+	if err != nil {
+		t.Fatalf("UpdateDashboard: %v", err)
+	}
+	if updated.Name != "renamed" || updated.Description != "" || updated.Project != "proj-1" {
+		t.Errorf("UpdateDashboard = %+v, want the name replaced and the description cleared", updated)
+	}
+
+	list, err := c.ListDashboards(ctx, "")
+	if err != nil {
+		t.Fatalf("ListDashboards: %v", err)
+	}
+	if len(list.Dashboards) != 2 || list.Next != 2 {
+		t.Fatalf("ListDashboards = %+v, want a page of 2 with next 2", list)
+	}
+	list, err = c.ListDashboards(ctx, "2")
+	if err != nil {
+		t.Fatalf("ListDashboards: %v", err)
+	}
+	if len(list.Dashboards) != 1 || list.Next != 0 {
+		t.Fatalf("ListDashboards = %+v, want the last dashboard", list)
+	}
+
+	if err := c.DeleteDashboard(ctx, created.ID); err != nil {
+		t.Fatalf("DeleteDashboard: %v", err)
+	}
+	_, err = c.GetDashboard(ctx, created.ID)
+	if !client.IsNotFound(err) {
+		t.Fatalf("GetDashboard of a deleted dashboard error = %v, want not found", err)
+	}
+}
+
+func TestPatchDashboard(t *testing.T) {
+	ctx := context.Background()
+	c := client.NewClient(newTestServer(t).URL)
+
+	created, err := c.CreateDashboard(ctx, models.Dashboard{Name: "a", Description: "first", Project: "proj-1"})
+	if err != nil {
+		t.Fatalf("CreateDashboard: %v", err)
+	}
+	if created.Version != 1 {
+		t.Fatalf("version = %d, want 1", created.Version)
+	}
+
+	description := "second"
+	patched, err := c.PatchDashboard(ctx, created.ID, models.DashboardPatch{Description: &description}, created.Version)
+	if err != nil {
+		t.Fatalf("PatchDashboard: %v", err)
+	}
+	if patched.Name != "a" || patched.Description != "second" || patched.Version != 2 {
+		t.Errorf("PatchDashboard = %+v, want the name kept, the description changed and version 2", patched)
+	}
+
+	// A second writer still holding version 1 must not overwrite the change
+	name := "b"
+	_, err = c.PatchDashboard(ctx, created.ID, models.DashboardPatch{Name: &name}, created.Version)
+	if !client.IsPreconditionFailed(err) {
+		t.Fatalf("PatchDashboard of a stale version error = %v, want precondition failed", err)
+	}
+	_, err = c.UpdateDashboard(ctx, created.ID, *created)
+	if !client.IsPreconditionFailed(err) {
+		t.Fatalf("UpdateDashboard of a stale version error = %v, want precondition failed", err)
+	}
+
+	got, err := c.GetDashboard(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetDashboard: %v", err)
+	}
+	if got.Name != "a" || got.Version != 2 {
+		t.Errorf("GetDashboard = %+v, want the refused changes not applied", got)
+	}
+}
+
+func TestDashboardContent(t *testing.T) {
+	ctx := context.Background()
+	c := client.NewClient(newTestServer(t).URL)
+
+	prometheus := models.DatasourceRef{Type: "prometheus", UID: "prom-eu"}
+	created, err := c.CreateDashboard(ctx, models.Dashboard{
+		Name:      "checkout",
+		Project:   "proj-1",
+		Tags:      []string{"payments"},
+		Variables: []models.Variable{{Name: "instance", Type: models.VariableTypeQuery, Query: "label_values(instance)", Datasource: &prometheus}},
+		Panels: []models.Panel{
+			{Type: models.PanelTypeTimeSeries, Title: "Requests", Query: `rate(http_requests_total{instance="$instance"}[5m])`, Datasource: prometheus, GridPos: models.GridPos{W: 24, H: 8}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateDashboard: %v", err)
+	}
+	if created.TimeRange != models.DefaultTimeRange {
+		t.Errorf("time range = %+v, want the default", created.TimeRange)
+	}
+	if len(created.Panels) != 1 || created.Panels[0].Title != "Requests" {
+		t.Errorf("panels = %+v, want the Requests panel", created.Panels)
+	}
+
+	// Replacing the variables leaves the panel referencing an undefined one
+	variables := []models.Variable{}
+	_, err = c.PatchDashboard(ctx, created.ID, models.DashboardPatch{Variables: &variables}, created.Version)
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != models.ErrorCodeInvalidRequest {
+		t.Fatalf("PatchDashboard error = %v, want invalid_request", err)
+	}
+	if len(apiErr.Details) != 1 || apiErr.Details[0].Field != "panels[0].query" {
+		t.Errorf("details = %+v, want panels[0].query", apiErr.Details)
+	}
+
+	refresh := "1m"
+	timeRange := models.TimeRangePatch{Refresh: &refresh}
+	patched, err := c.PatchDashboard(ctx, created.ID, models.DashboardPatch{TimeRange: &timeRange}, created.Version)
+	if err != nil {
+		t.Fatalf("PatchDashboard: %v", err)
+	}
+	if want := (models.TimeRange{From: "now-6h", To: "now", Refresh: "1m"}); patched.TimeRange != want {
+		t.Errorf("time range = %+v, want %+v", patched.TimeRange, want)
+	}
+	if len(patched.Variables) != 1 || len(patched.Panels) != 1 {
+		t.Errorf("PatchDashboard = %+v, want the variables and panels kept", patched)
+	}
+}
+
+func TestLegacyUpdate(t *testing.T) {
+	ctx := context.Background()
+	srv := newTestServer(t)
+	c := client.NewClient(srv.URL)
+
+	prometheus := models.DatasourceRef{Type: "prometheus", UID: "prom-eu"}
+	created, err := c.CreateDashboard(ctx, models.Dashboard{
+		Name:    "checkout",
+		Project: "proj-1",
+		Tags:    []string{"payments"},
+		Panels:  []models.Panel{{Type: models.PanelTypeTimeSeries, Title: "Requests", Query: "rate(http_requests_total[5m])", Datasource: prometheus, GridPos: models.GridPos{W: 24, H: 8}}},
+	})
+	if err != nil {
+		t.Fatalf("CreateDashboard: %v", err)
+	}
+
+	// Clients of the deprecated path only know the name and description
+	req, err := http.NewRequest(http.MethodPut, srv.URL+"/dashboard/update?id="+created.ID, strings.NewReader(`{"name": "checkout-v2", "description": "Checkout service"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("PUT /dashboard/update: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Deprecation") != "true" {
+		t.Fatalf("PUT /dashboard/update = %d, Deprecation %q, want a deprecated 200", resp.StatusCode, resp.Header.Get("Deprecation"))
+	}
+
+	updated, err := c.GetDashboard(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetDashboard: %v", err)
+	}
+	if updated.Name != "checkout-v2" || updated.Description != "Checkout service" {
+		t.Errorf("name, description = %q, %q, want them updated", updated.Name, updated.Description)
+	}
+	if !reflect.DeepEqual(updated.Tags, created.Tags) || !reflect.DeepEqual(updated.Panels, created.Panels) || updated.TimeRange != created.TimeRange {
+		t.Errorf("GetDashboard = %+v, want the tags, time range and panels kept", updated)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	srv := newTestServer(t)
+	d, err := store.Create(models.Dashboard{Name: "a", Description: "first", Project: "proj-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		patch      string
+		ifMatch    string
+		wantStatus int
+		want       models.Dashboard
+	}{
+		{name: "empty", patch: `{}`, wantStatus: http.StatusOK, want: models.Dashboard{Name: "a", Description: "first"}},
+		{name: "set", patch: `{"name":"b"}`, wantStatus: http.StatusOK, want: models.Dashboard{Name: "b", Description: "first"}},
+		{name: "clear", patch: `{"description":null}`, wantStatus: http.StatusOK, want: models.Dashboard{Name: "b"}},
+		{name: "matching If-Match", patch: `{"description":"x"}`, ifMatch: `"4", "5"`, wantStatus: http.StatusOK, want: models.Dashboard{Name: "b", Description: "x"}},
+		{name: "any If-Match", patch: `{"description":"y"}`, ifMatch: `*`, wantStatus: http.StatusOK, want: models.Dashboard{Name: "b", Description: "y"}},
+		{name: "stale If-Match", patch: `{"description":"z"}`, ifMatch: `"1"`, wantStatus: http.StatusPreconditionFailed},
+		{name: "weak If-Match", patch: `{"description":"z"}`, ifMatch: `W/"6"`, wantStatus: http.StatusPreconditionFailed},
+		{name: "same project", patch: `{"project":"proj-1"}`, wantStatus: http.StatusOK, want: models.Dashboard{Name: "b", Description: "y"}},
+		{name: "project", patch: `{"project":"proj-2"}`, wantStatus: http.StatusBadRequest},
+		{name: "version", patch: `{"version":1}`, wantStatus: http.StatusBadRequest},
+		{name: "unknown field", patch: `{"title":"x"}`, wantStatus: http.StatusBadRequest},
+		{name: "wrong type", patch: `{"name":1}`, wantStatus: http.StatusBadRequest},
+		{name: "not an object", patch: `["name"]`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, err := store.Get(d.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, err := http.NewRequest(http.MethodPatch, srv.URL+"/v1/dashboards/"+d.ID, strings.NewReader(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			after, err := store.Get(d.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				if !reflect.DeepEqual(after, before) {
+					t.Errorf("refused patch changed the dashboard to %+v", after)
+				}
+				return
+			}
+
+			if after.Name != tt.want.Name || after.Description != tt.want.Description || after.Project != "proj-1" {
+				t.Errorf("dashboard = %+v, want %+v", after, tt.want)
+			}
+			if after.Version != before.Version+1 {
+				t.Errorf("version = %d, want %d", after.Version, before.Version+1)
+			}
+			if got, want := resp.Header.Get("ETag"), models.ETag(after.Version); got != want {
+				t.Errorf("ETag = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	srv := newTestServer(t)
+	d, err := store.Create(models.Dashboard{Name: "a", Project: "proj-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantCode   string
+		wantAllow  string
+		deprecated bool
+	}{
+		{name: "get", method: http.MethodGet, path: "/v1/dashboards/" + d.ID, wantStatus: http.StatusOK},
+		{name: "patch", method: http.MethodPatch, path: "/v1/dashboards/" + d.ID, body: `{"description":"patched"}`, wantStatus: http.StatusOK},
+		{name: "unknown field", method: http.MethodPatch, path: "/v1/dashboards/" + d.ID, body: `{"title":"x"}`, wantStatus: http.StatusBadRequest, wantCode: models.ErrorCodeInvalidRequest},
+		{name: "missing project", method: http.MethodPost, path: "/v1/dashboards", body: `{"name":"x"}`, wantStatus: http.StatusBadRequest, wantCode: models.ErrorCodeInvalidRequest},
+		{name: "invalid next", method: http.MethodGet, path: "/v1/dashboards?next=-1", wantStatus: http.StatusBadRequest, wantCode: models.ErrorCodeInvalidRequest},
+		{name: "not found", method: http.MethodGet, path: "/v1/dashboards/missing", wantStatus: http.StatusNotFound, wantCode: models.ErrorCodeNotFound},
+		{name: "unknown route", method: http.MethodGet, path: "/v1/panels", wantStatus: http.StatusNotFound, wantCode: models.ErrorCodeNotFound},
+		{name: "collection method", method: http.MethodDelete, path: "/v1/dashboards", wantStatus: http.StatusMethodNotAllowed, wantCode: models.ErrorCodeMethodNotAllowed, wantAllow: "GET, POST, HEAD"},
+		{name: "item method", method: http.MethodPost, path: "/v1/dashboards/" + d.ID, wantStatus: http.StatusMethodNotAllowed, wantCode: models.ErrorCodeMethodNotAllowed, wantAllow: "GET, PUT, PATCH, DELETE, HEAD"},
+		{name: "legacy get", method: http.MethodGet, path: "/dashboard/get?id=" + d.ID, wantStatus: http.StatusOK, deprecated: true},
+		{name: "legacy delete with GET", method: http.MethodGet, path: "/dashboard/delete?id=" + d.ID, wantStatus: http.StatusMethodNotAllowed, wantCode: models.ErrorCodeMethodNotAllowed, wantAllow: "DELETE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := resp.Header.Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+			if got := resp.Header.Get("Deprecation") == "true"; got != tt.deprecated {
+				t.Errorf("deprecated = %v, want %v", got, tt.deprecated)
+			}
+			if tt.wantCode != "" {
+				var errResp models.ErrorResponse
+				if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
+					t.Fatalf("decoding error body: %v", err)
+				}
+				if errResp.Error.Code != tt.wantCode {
+					t.Errorf("code = %q, want %q", errResp.Error.Code, tt.wantCode)
+				}
+			}
+		})
+	}
+
+	// The legacy delete refused the GET
+	if _, err := store.Get(d.ID); err != nil {
+		t.Errorf("Get: %v", err)
+	}
+}
@@ -1,13 +1,161 @@
 package models
 
+import (
+	"cmp"
+	"strconv"
+)
+
 type Dashboard struct {
 	ID          string `json:"id"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	Project     string `json:"project"`
+	// Tags group dashboards, e.g. by team or service.
+	Tags []string `json:"tags,omitempty"`
+	// TimeRange is the time range the dashboard shows when it is opened.
+	TimeRange TimeRange `json:"time_range"`
+	// Variables are template variables, which panel queries reference as $name or ${name}.
+	Variables []Variable `json:"variables,omitempty"`
+	Panels    []Panel    `json:"panels,omitempty"`
+	// Version is set by the server, and incremented on every change. Its ETag
+	// can be sent in an If-Match header to only update the dashboard if it
+	// wasn't changed since it was read.
+	Version int64 `json:"version"`
+}
+
+// TimeRange is a time range, with times relative to now such as now-6h, now or
+// now/d, or RFC 3339 timestamps.
+type TimeRange struct {
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// Refresh is how often the dashboard reloads, e.g. 30s. It doesn't when empty.
+	Refresh string `json:"refresh,omitempty"`
+}
+
+// DefaultTimeRange is the time range of dashboards that don't set one.
+var DefaultTimeRange = TimeRange{From: "now-6h", To: "now"}
+
+// SetDefaults fills in the empty fields of a dashboard that have a default.
+func (d *Dashboard) SetDefaults() {
+	d.TimeRange.From = cmp.Or(d.TimeRange.From, DefaultTimeRange.From)
+	d.TimeRange.To = cmp.Or(d.TimeRange.To, DefaultTimeRange.To)
+}
+
+// TimeRangePatch is a JSON merge patch of a TimeRange. Setting a field to an
+// empty string clears it, resetting From and To to their default.
+type TimeRangePatch struct {
+	From    *string `json:"from,omitempty"`
+	To      *string `json:"to,omitempty"`
+	Refresh *string `json:"refresh,omitempty"`
+}
+
+// Panel types.
+const (
+	PanelTypeTimeSeries = "timeseries"
+	PanelTypeStat       = "stat"
+	PanelTypeGauge      = "gauge"
+	PanelTypeBarChart   = "bar_chart"
+	PanelTypeTable      = "table"
+	PanelTypeHeatmap    = "heatmap"
+	PanelTypeLogs       = "logs"
+)
+
+// Panel is a visualization of the result of a query.
+type Panel struct {
+	Type       string        `json:"type"`
+	Title      string        `json:"title"`
+	Query      string        `json:"query"`
+	Datasource DatasourceRef `json:"datasource"`
+	GridPos    GridPos       `json:"grid_pos"`
+}
+
+// DatasourceRef references the datasource a query runs against.
+type DatasourceRef struct {
+	// Type is the kind of datasource, e.g. prometheus or loki.
+	Type string `json:"type"`
+	// UID identifies the datasource. It can reference a variable, such as ${datasource}.
+	UID string `json:"uid"`
+}
+
+// GridColumns is the width of the grid panels are laid out on.
+const GridColumns = 24
+
+// GridPos is the position and size of a panel on the grid, which is GridColumns
+// wide, and extends downwards as needed.
+type GridPos struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// Variable types.
+const (
+	// Options are the results of a query.
+	VariableTypeQuery = "query"
+	// Options are listed in the variable.
+	VariableTypeCustom = "custom"
+	// A hidden variable with a fixed value, its default.
+	VariableTypeConstant = "constant"
+	// Options are durations such as 1m or 1h.
+	VariableTypeInterval = "interval"
+)
+
+// Variable is a template variable of a dashboard.
+type Variable struct {
+	Name  string `json:"name"`
+	Label string `json:"label,omitempty"`
+	Type  string `json:"type"`
+	// Query lists the options of query variables.
+	Query      string         `json:"query,omitempty"`
+	Datasource *DatasourceRef `json:"datasource,omitempty"`
+	// Options of custom and interval variables.
+	Options []string `json:"options,omitempty"`
+	// Default is the value selected when the dashboard is opened.
+	Default string `json:"default,omitempty"`
+}
+
+// ETag returns the entity tag of a version of a dashboard.
+func ETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// DashboardPatch is a JSON merge patch (RFC 7396) of a dashboard. Only the fields
+// that are set are changed. Lists are replaced as a whole.
+type DashboardPatch struct {
+	Name        *string         `json:"name,omitempty"`
+	Description *string         `json:"description,omitempty"`
+	Tags        *[]string       `json:"tags,omitempty"`
+	TimeRange   *TimeRangePatch `json:"time_range,omitempty"`
+	Variables   *[]Variable     `json:"variables,omitempty"`
+	Panels      *[]Panel        `json:"panels,omitempty"`
 }
 
 type DashboardList struct {
 	Dashboards []Dashboard `json:"dashboards"`
 	Next       int         `json:"next"`
 }
+
+// Error codes of the Error responses of the server.
+const (
+	ErrorCodeInvalidRequest   = "invalid_request"
+	ErrorCodeNotFound         = "not_found"
+	ErrorCodeMethodNotAllowed = "method_not_allowed"
+	// The dashboard was changed since the version in the If-Match header.
+	ErrorCodePreconditionFailed = "precondition_failed"
+	ErrorCodeInternal           = "internal"
+)
+
+// Error describes why a request failed. Code is one of the ErrorCode constants,
+// and Message is meant for humans.
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Details lists the invalid fields of an invalid_request error, if known.
+	Details []FieldError `json:"details,omitempty"`
+}
+
+// ErrorResponse is the body of every response with an error status.
+type ErrorResponse struct {
+	Error Error `json:"error"`
+}
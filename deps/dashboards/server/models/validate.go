@@ -0,0 +1,213 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// FieldError describes an invalid field of a dashboard. Field is its JSON path,
+// such as panels[0].grid_pos.w.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists the invalid fields of a dashboard.
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Field+": "+fe.Message)
+	}
+	return "invalid dashboard: " + strings.Join(msgs, "; ")
+}
+
+var (
+	PanelTypes    = []string{PanelTypeTimeSeries, PanelTypeStat, PanelTypeGauge, PanelTypeBarChart, PanelTypeTable, PanelTypeHeatmap, PanelTypeLogs}
+	VariableTypes = []string{VariableTypeQuery, VariableTypeCustom, VariableTypeConstant, VariableTypeInterval}
+
+	variableNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// $name, or ${name} with an optional :format or .field suffix. Names starting
+	// with __ are built in, such as $__interval.
+	variableRefRegexp = regexp.MustCompile(`\$(?:\{([A-Za-z0-9_]+)(?:[:.][^}]*)?\}|([A-Za-z_][A-Za-z0-9_]*))`)
+	durationRegexp    = regexp.MustCompile(`^[1-9][0-9]*[smhdwMy]$`)
+	relativeRegexp    = regexp.MustCompile(`^now(-[1-9][0-9]*[smhdwMy])?(/[smhdwMy])?$`)
+)
+
+const maxTagLength = 50
+
+// Validate checks the content of a dashboard: its tags, time range, variables
+// and panels. It returns a ValidationError listing every invalid field.
+// Panels must fit the grid without overlapping, and only reference variables
+// the dashboard defines.
+func (d Dashboard) Validate() error {
+	var errs ValidationError
+	add := func(field, format string, args ...any) {
+		errs = append(errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for i, tag := range d.Tags {
+		field := fmt.Sprintf("tags[%d]", i)
+		switch {
+		case strings.TrimSpace(tag) == "":
+			add(field, "must not be empty")
+		case len(tag) > maxTagLength:
+			add(field, "must be at most %d characters", maxTagLength)
+		case slices.Index(d.Tags, tag) < i:
+			add(field, "duplicate tag %q", tag)
+		}
+	}
+
+	validateTimeRange(d.TimeRange, add)
+
+	variables := map[string]bool{}
+	for i, v := range d.Variables {
+		field := fmt.Sprintf("variables[%d]", i)
+		switch {
+		case !variableNameRegexp.MatchString(v.Name) || strings.HasPrefix(v.Name, "__"):
+			add(field+".name", "%q must be letters, digits and underscores, and not start with a digit or __", v.Name)
+		case variables[v.Name]:
+			add(field+".name", "duplicate variable %q", v.Name)
+		}
+		variables[v.Name] = true
+	}
+	for i, v := range d.Variables {
+		validateVariable(fmt.Sprintf("variables[%d]", i), v, variables, add)
+	}
+
+	for i, p := range d.Panels {
+		validatePanel(fmt.Sprintf("panels[%d]", i), p, variables, add)
+		for j, other := range d.Panels[:i] {
+			if p.GridPos.overlaps(other.GridPos) {
+				add(fmt.Sprintf("panels[%d].grid_pos", i), "overlaps panels[%d] %q", j, other.Title)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateTimeRange(tr TimeRange, add func(field, format string, args ...any)) {
+	from, fromOK := parseTime(tr.From)
+	if !fromOK {
+		add("time_range.from", "%q must be now, a relative time such as now-6h or now/d, or an RFC 3339 timestamp", tr.From)
+	}
+	to, toOK := parseTime(tr.To)
+	if !toOK {
+		add("time_range.to", "%q must be now, a relative time such as now-6h or now/d, or an RFC 3339 timestamp", tr.To)
+	}
+	if fromOK && toOK && !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		add("time_range", "from must be before to")
+	}
+	if tr.Refresh != "" && !durationRegexp.MatchString(tr.Refresh) {
+		add("time_range.refresh", "%q must be a duration such as 30s or 5m", tr.Refresh)
+	}
+}
+
+// parseTime parses a time of a TimeRange. It returns the zero time for relative times.
+func parseTime(s string) (time.Time, bool) {
+	if relativeRegexp.MatchString(s) {
+		return time.Time{}, true
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	return t, err == nil
+}
+
+func validateVariable(field string, v Variable, variables map[string]bool, add func(field, format string, args ...any)) {
+	switch v.Type {
+	case VariableTypeQuery:
+		if strings.TrimSpace(v.Query) == "" {
+			add(field+".query", "is required for query variables")
+		}
+		if v.Datasource == nil {
+			add(field+".datasource", "is required for query variables")
+		} else {
+			validateDatasource(field+".datasource", *v.Datasource, variables, add)
+		}
+	case VariableTypeCustom, VariableTypeInterval:
+		if len(v.Options) == 0 {
+			add(field+".options", "are required for %s variables", v.Type)
+		}
+		for i, option := range v.Options {
+			if v.Type == VariableTypeInterval && !durationRegexp.MatchString(option) {
+				add(fmt.Sprintf("%s.options[%d]", field, i), "%q must be a duration such as 1m or 1h", option)
+			}
+		}
+	case VariableTypeConstant:
+		if v.Default == "" {
+			add(field+".default", "is required for constant variables")
+		}
+	default:
+		add(field+".type", "%q must be one of %s", v.Type, strings.Join(VariableTypes, ", "))
+	}
+
+	if v.Default != "" && len(v.Options) > 0 && !slices.Contains(v.Options, v.Default) {
+		add(field+".default", "%q is not one of the options", v.Default)
+	}
+	// A query can use the other variables, but not its own
+	validateReferences(field+".query", v.Query, variables, v.Name, add)
+}
+
+func validatePanel(field string, p Panel, variables map[string]bool, add func(field, format string, args ...any)) {
+	if !slices.Contains(PanelTypes, p.Type) {
+		add(field+".type", "%q must be one of %s", p.Type, strings.Join(PanelTypes, ", "))
+	}
+	if strings.TrimSpace(p.Title) == "" {
+		add(field+".title", "must not be empty")
+	}
+	if strings.TrimSpace(p.Query) == "" {
+		add(field+".query", "must not be empty")
+	}
+	validateReferences(field+".query", p.Query, variables, "", add)
+	validateDatasource(field+".datasource", p.Datasource, variables, add)
+
+	pos := p.GridPos
+	if pos.X < 0 || pos.Y < 0 {
+		add(field+".grid_pos", "x and y must not be negative")
+	}
+	if pos.W < 1 || pos.W > GridColumns {
+		add(field+".grid_pos.w", "must be between 1 and %d", GridColumns)
+	} else if pos.X+pos.W > GridColumns {
+		add(field+".grid_pos", "x + w must be at most %d", GridColumns)
+	}
+	if pos.H < 1 {
+		add(field+".grid_pos.h", "must be at least 1")
+	}
+}
+
+func validateDatasource(field string, ds DatasourceRef, variables map[string]bool, add func(field, format string, args ...any)) {
+	if ds.Type == "" {
+		add(field+".type", "must not be empty")
+	}
+	if ds.UID == "" {
+		add(field+".uid", "must not be empty")
+	}
+	validateReferences(field+".uid", ds.UID, variables, "", add)
+}
+
+// validateReferences checks that s only references defined variables, other than self.
+func validateReferences(field, s string, variables map[string]bool, self string, add func(field, format string, args ...any)) {
+	for _, m := range variableRefRegexp.FindAllStringSubmatch(s, -1) {
+		name := m[1] + m[2]
+		if strings.HasPrefix(name, "__") {
+			continue
+		}
+		if name == self {
+			add(field, "references its own variable $%s", name)
+		} else if !variables[name] {
+			add(field, "references undefined variable $%s", name)
+		}
+	}
+}
+
+func (p GridPos) overlaps(other GridPos) bool {
+	return p.X < other.X+other.W && other.X < p.X+p.W &&
+		p.Y < other.Y+other.H && other.Y < p.Y+p.H
+}
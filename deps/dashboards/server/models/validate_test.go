@@ -0,0 +1,95 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func testDashboard() Dashboard {
+	prometheus := DatasourceRef{Type: "prometheus", UID: "${datasource}"}
+	return Dashboard{
+		Name:      "checkout",
+		Tags:      []string{"team-payments", "service"},
+		TimeRange: TimeRange{From: "now-1d/d", To: "now", Refresh: "30s"},
+		Variables: []Variable{
+			{Name: "datasource", Type: VariableTypeCustom, Options: []string{"prom-eu", "prom-us"}, Default: "prom-eu"},
+			{Name: "instance", Type: VariableTypeQuery, Query: `label_values(up{job="checkout"}, instance)`, Datasource: &prometheus},
+			{Name: "interval", Type: VariableTypeInterval, Options: []string{"1m", "5m"}},
+		},
+		Panels: []Panel{
+			{Type: PanelTypeTimeSeries, Title: "Requests", Query: `sum(rate(http_requests_total{instance=~"$instance"}[$interval]))`, Datasource: prometheus, GridPos: GridPos{X: 0, Y: 0, W: 12, H: 8}},
+			{Type: PanelTypeStat, Title: "Errors", Query: `sum(rate(http_errors_total[${__range}]))`, Datasource: prometheus, GridPos: GridPos{X: 12, Y: 0, W: 12, H: 8}},
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(d *Dashboard)
+		fields []string // The invalid fields, none if valid
+	}{
+		{name: "valid", modify: func(d *Dashboard) {}},
+		{name: "empty", modify: func(d *Dashboard) { *d = Dashboard{TimeRange: DefaultTimeRange} }},
+		{name: "absolute time range", modify: func(d *Dashboard) {
+			d.TimeRange = TimeRange{From: "2024-01-01T00:00:00Z", To: "2024-01-02T00:00:00Z"}
+		}},
+		{name: "reversed time range", modify: func(d *Dashboard) {
+			d.TimeRange = TimeRange{From: "2024-01-02T00:00:00Z", To: "2024-01-01T00:00:00Z"}
+		}, fields: []string{"time_range"}},
+		{name: "invalid times", modify: func(d *Dashboard) {
+			d.TimeRange = TimeRange{From: "yesterday", To: "", Refresh: "often"}
+		}, fields: []string{"time_range.from", "time_range.to", "time_range.refresh"}},
+		{name: "tags", modify: func(d *Dashboard) {
+			d.Tags = []string{"a", " ", "a"}
+		}, fields: []string{"tags[1]", "tags[2]"}},
+		{name: "variable names", modify: func(d *Dashboard) {
+			d.Variables[1].Name = "datasource"
+			d.Variables[2].Name = "__interval"
+		}, fields: []string{"variables[1].name", "variables[2].name", "panels[0].query", "panels[0].query"}},
+		{name: "variable fields", modify: func(d *Dashboard) {
+			d.Variables[0].Default = "prom-ap"
+			d.Variables[1].Datasource = nil
+			d.Variables[2].Options = []string{"1 minute"}
+		}, fields: []string{"variables[0].default", "variables[1].datasource", "variables[2].options[0]"}},
+		{name: "variable referencing itself", modify: func(d *Dashboard) {
+			d.Variables[1].Query = "label_values($instance)"
+		}, fields: []string{"variables[1].query"}},
+		{name: "variable type", modify: func(d *Dashboard) {
+			d.Variables[0].Type = "list"
+		}, fields: []string{"variables[0].type"}},
+		{name: "undefined variable", modify: func(d *Dashboard) {
+			d.Panels[1].Query = "up{job=\"$job\"}"
+		}, fields: []string{"panels[1].query"}},
+		{name: "panel fields", modify: func(d *Dashboard) {
+			d.Panels[0] = Panel{Type: "pie", GridPos: GridPos{X: 0, Y: 20, W: 12, H: 8}}
+		}, fields: []string{"panels[0].type", "panels[0].title", "panels[0].query", "panels[0].datasource.type", "panels[0].datasource.uid"}},
+		{name: "panel outside the grid", modify: func(d *Dashboard) {
+			d.Panels[0].GridPos = GridPos{X: 20, Y: 8, W: 8, H: 0}
+			d.Panels[1].GridPos = GridPos{X: -1, Y: 0, W: 25, H: 8}
+		}, fields: []string{"panels[0].grid_pos", "panels[0].grid_pos.h", "panels[1].grid_pos", "panels[1].grid_pos.w"}},
+		{name: "overlapping panels", modify: func(d *Dashboard) {
+			d.Panels[1].GridPos = GridPos{X: 6, Y: 4, W: 12, H: 8}
+		}, fields: []string{"panels[1].grid_pos"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := testDashboard()
+			tt.modify(&d)
+
+			var fields []string
+			var validationErr ValidationError
+			if err := d.Validate(); errors.As(err, &validationErr) {
+				for _, fe := range validationErr {
+					fields = append(fields, fe.Field)
+				}
+			} else if err != nil {
+				t.Fatalf("Validate() = %v, want a ValidationError", err)
+			}
+			if !slices.Equal(fields, tt.fields) {
+				t.Errorf("invalid fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
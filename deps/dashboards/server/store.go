@@ -0,0 +1,219 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+
+	"github.com/tempestdx/examples/deps/dashboards/server/models"
+)
+
+var errNotFound = errors.New("dashboard not found")
+
+// Store persists dashboards. Implementations are safe for concurrent use, and
+// list dashboards in the order they were created.
+type Store interface {
+	// Create stores a new dashboard under a generated ID at version 1, and returns it.
+	Create(dashboard models.Dashboard) (models.Dashboard, error)
+	// Get returns the dashboard with the given ID, or errNotFound.
+	Get(id string) (models.Dashboard, error)
+	// Update applies fn to the dashboard with the given ID and stores the result
+	// under the next version, unless fn returns an error. Other updates wait
+	// until fn returns, so fn can check the version it is given.
+	Update(id string, fn func(*models.Dashboard) error) (models.Dashboard, error)
+	// Delete removes the dashboard with the given ID, or returns errNotFound.
+	Delete(id string) error
+	// List returns up to limit dashboards starting at offset, and the total number of dashboards.
+	List(offset, limit int) ([]models.Dashboard, int, error)
+	// Close releases the files of the store.
+	Close() error
+}
+
+// openStore opens the store of the given kind. path is the file of the json and
+// kv stores, dashboards.json or dashboards.log in the working directory if empty.
+func openStore(kind, path string) (Store, error) {
+	switch kind {
+	case "memory":
+		return newMemoryStore(), nil
+	case "json":
+		return openJSONStore(cmp.Or(path, "dashboards.json"))
+	case "kv":
+		return openKVStore(cmp.Or(path, "dashboards.log"))
+	default:
+		return nil, fmt.Errorf("unknown store %q, expected memory, json or kv", kind)
+	}
+}
+
+// Generate a unique alphanumeric ExternalID.
+func generateExternalID() string {
+	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	const length = 8
+	b := make([]byte, length)
+	for i := range b {
+		b[i] = charset[rand.Int()%len(charset)]
+	}
+
+	return string(b)
+}
+
+// Operations of a change to the store.
+const (
+	opPut    = "put"
+	opDelete = "delete"
+)
+
+// change is a single write to the store. The kv store logs changes as they are made.
+type change struct {
+	Op        string           `json:"op"`
+	Dashboard models.Dashboard `json:"dashboard"`
+}
+
+// memoryStore keeps dashboards in memory, indexed by ID. The json and kv stores
+// build on it, persisting each change before it is acknowledged.
+type memoryStore struct {
+	mu         sync.Mutex
+	dashboards map[string]*models.Dashboard
+	order      []string // IDs in creation order, for listing
+
+	// persist is called with mu held after a change is applied in memory. If it
+	// fails, the change is undone. It is nil for a store kept only in memory.
+	persist func(change) error
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{dashboards: map[string]*models.Dashboard{}}
+}
+
+func (s *memoryStore) Create(dashboard models.Dashboard) (models.Dashboard, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dashboard.ID = generateExternalID()
+	for s.dashboards[dashboard.ID] != nil {
+		dashboard.ID = generateExternalID()
+	}
+	dashboard.Version = 1
+
+	if err := s.commit(change{Op: opPut, Dashboard: dashboard}); err != nil {
+		return models.Dashboard{}, err
+	}
+	return dashboard, nil
+}
+
+func (s *memoryStore) Get(id string) (models.Dashboard, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dashboard := s.dashboards[id]
+	if dashboard == nil {
+		return models.Dashboard{}, errNotFound
+	}
+	return *dashboard, nil
+}
+
+func (s *memoryStore) Update(id string, fn func(*models.Dashboard) error) (models.Dashboard, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.dashboards[id]
+	if current == nil {
+		return models.Dashboard{}, errNotFound
+	}
+
+	dashboard := *current
+	if err := fn(&dashboard); err != nil {
+		return models.Dashboard{}, err
+	}
+	dashboard.ID = id
+	dashboard.Version = current.Version + 1
+
+	if err := s.commit(change{Op: opPut, Dashboard: dashboard}); err != nil {
+		return models.Dashboard{}, err
+	}
+	return dashboard, nil
+}
+
+func (s *memoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dashboard := s.dashboards[id]
+	if dashboard == nil {
+		return errNotFound
+	}
+	return s.commit(change{Op: opDelete, Dashboard: *dashboard})
+}
+
+func (s *memoryStore) List(offset, limit int) ([]models.Dashboard, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var page []models.Dashboard
+	for i := offset; i < offset+limit && i < len(s.order); i++ {
+		page = append(page, *s.dashboards[s.order[i]])
+	}
+	return page, len(s.order), nil
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
+
+// commit applies a change and persists it, undoing the change if persisting fails.
+// s.mu must be held.
+func (s *memoryStore) commit(c change) error {
+	undo := s.apply(c)
+	if s.persist == nil {
+		return nil
+	}
+	if err := s.persist(c); err != nil {
+		undo()
+		return fmt.Errorf("failed to persist dashboard %s: %w", c.Dashboard.ID, err)
+	}
+	return nil
+}
+
+// apply makes a change in memory, and returns a function undoing it. s.mu must be held.
+func (s *memoryStore) apply(c change) (undo func()) {
+	id := c.Dashboard.ID
+	previous := s.dashboards[id]
+
+	switch c.Op {
+	case opPut:
+		dashboard := c.Dashboard
+		s.dashboards[id] = &dashboard
+		if previous != nil {
+			return func() { s.dashboards[id] = previous }
+		}
+		s.order = append(s.order, id)
+		return func() {
+			delete(s.dashboards, id)
+			s.order = s.order[:len(s.order)-1]
+		}
+
+	case opDelete:
+		if previous == nil {
+			return func() {}
+		}
+		i := slices.Index(s.order, id)
+		delete(s.dashboards, id)
+		s.order = slices.Delete(s.order, i, i+1)
+		return func() {
+			s.dashboards[id] = previous
+			s.order = slices.Insert(s.order, i, id)
+		}
+	}
+	return func() {}
+}
+
+// snapshot returns all dashboards in creation order. s.mu must be held.
+func (s *memoryStore) snapshot() []models.Dashboard {
+	out := make([]models.Dashboard, 0, len(s.order))
+	for _, id := range s.order {
+		out = append(out, *s.dashboards[id])
+	}
+	return out
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/tempestdx/examples/deps/dashboards/server/models"
+)
+
+// openJSONStore opens a store that keeps all dashboards in a single JSON file,
+// rewritten on every change. It is easy to inspect and edit by hand, and fits
+// the small number of dashboards of a dev fixture.
+func openJSONStore(path string) (Store, error) {
+	s := newMemoryStore()
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		var dashboards []models.Dashboard
+		if err := json.Unmarshal(data, &dashboards); err != nil {
+			return nil, fmt.Errorf("failed to read dashboards from %s: %w", path, err)
+		}
+		for _, d := range dashboards {
+			s.apply(change{Op: opPut, Dashboard: d})
+		}
+	}
+
+	s.persist = func(change) error {
+		data, err := json.MarshalIndent(s.snapshot(), "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(path, append(data, '\n'))
+	}
+	return s, nil
+}
+
+// writeFileAtomic replaces the file at path with data, so a crash leaves either
+// the old or the new file behind, never a partial one.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// kvStore is an embedded key-value store keeping dashboards in an append-only
+// log: every change is appended to the file as a JSON line and synced to disk,
+// so writes don't rewrite the whole data set. Opening the store replays the log.
+// The log is compacted into one line per dashboard when it is opened, and when
+// most of its lines are superseded by later changes.
+type kvStore struct {
+	*memoryStore
+
+	path    string
+	file    *os.File
+	entries int // Lines in the log, to decide when to compact it
+}
+
+// openKVStore opens the log at path, creating it if it doesn't exist.
+func openKVStore(path string) (Store, error) {
+	s := &kvStore{memoryStore: newMemoryStore(), path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		var c change
+		if err := json.Unmarshal(line, &c); err != nil {
+			// A crash while appending leaves a partial last line, which is dropped
+			if i == len(lines)-1 {
+				logger.Warn("Dropping partial last line of the store", "path", path, "line", i+1)
+				break
+			}
+			return nil, fmt.Errorf("invalid line %d of %s: %w", i+1, path, err)
+		}
+		s.apply(c)
+	}
+
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	s.persist = s.append
+	return s, nil
+}
+
+// append writes a change to the end of the log. s.mu must be held.
+func (s *kvStore) append(c change) error {
+	line, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	// Cut off whatever part of the line was written if the write fails, so the
+	// next change doesn't follow a partial line
+	info, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		_ = s.file.Truncate(info.Size())
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		_ = s.file.Truncate(info.Size())
+		return err
+	}
+	s.entries++
+
+	// The change is durable at this point, so failing to compact isn't an error
+	if s.entries > 2*len(s.order)+100 {
+		if err := s.compact(); err != nil {
+			logger.Warn("Failed to compact the store", "path", s.path, "error", err)
+		}
+	}
+	return nil
+}
+
+// compact rewrites the log with a single put per dashboard, and reopens it for
+// appending. s.mu must be held, unless the store isn't in use yet.
+func (s *kvStore) compact() error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, d := range s.snapshot() {
+		if err := enc.Encode(change{Op: opPut, Dashboard: d}); err != nil {
+			return err
+		}
+	}
+	if err := writeFileAtomic(s.path, buf.Bytes()); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file = file
+	s.entries = len(s.order)
+	return nil
+}
+
+func (s *kvStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tempestdx/examples/deps/dashboards/server/models"
+)
+
+func TestStores(t *testing.T) {
+	for _, kind := range []string{"memory", "json", "kv"} {
+		t.Run(kind, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "dashboards")
+			s := openTestStore(t, kind, path)
+
+			var ids []string
+			for _, name := range []string{"a", "b", "c"} {
+				d, err := s.Create(models.Dashboard{Name: name, Project: "proj-1"})
+				if err != nil {
+					t.Fatalf("Create: %v", err)
+				}
+				ids = append(ids, d.ID)
+			}
+
+			if _, err := s.Update(ids[0], func(d *models.Dashboard) error {
+				d.Description = "updated"
+				return nil
+			}); err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+			if _, err := s.Update(ids[1], func(d *models.Dashboard) error {
+				return errors.New("refused")
+			}); err == nil {
+				t.Fatal("Update stored a refused change")
+			}
+			if err := s.Delete(ids[1]); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if _, err := s.Get(ids[1]); !errors.Is(err, errNotFound) {
+				t.Errorf("Get of a deleted dashboard error = %v, want errNotFound", err)
+			}
+
+			// The file stores keep dashboards across restarts
+			if kind != "memory" {
+				if err := s.Close(); err != nil {
+					t.Fatal(err)
+				}
+				s = openTestStore(t, kind, path)
+			}
+
+			page, total, err := s.List(0, 10)
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if total != 2 || len(page) != 2 || page[0].ID != ids[0] || page[1].ID != ids[2] {
+				t.Fatalf("List = %v (total %d), want dashboards a and c", page, total)
+			}
+			if page[0].Description != "updated" {
+				t.Errorf("description = %q, want updated", page[0].Description)
+			}
+		})
+	}
+}
+
+func TestKVStorePartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dashboards.log")
+	s := openTestStore(t, "kv", path)
+	d, err := s.Create(models.Dashboard{Name: "a", Project: "proj-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// A crash in the middle of appending a change
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"op":"put","dashboard":{"id":"x`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	s = openTestStore(t, "kv", path)
+	if _, total, _ := s.List(0, 10); total != 1 {
+		t.Errorf("total = %d, want 1", total)
+	}
+	if _, err := s.Get(d.ID); err != nil {
+		t.Errorf("Get: %v", err)
+	}
+}
+
+func openTestStore(t *testing.T, kind, path string) Store {
+	t.Helper()
+	s, err := openStore(kind, path)
+	if err != nil {
+		t.Fatalf("openStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
@@ -0,0 +1,157 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// crdGroupKind identifies CustomResourceDefinitions, which are waited for while
+// applying so the custom resources after them can be resolved.
+var crdGroupKind = schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
+
+// ApplyOptions controls how objects are applied.
+type ApplyOptions struct {
+	Force  bool // Take ownership of fields owned by other field managers instead of failing
+	DryRun bool // Let the API server compute the result without persisting it
+}
+
+// Applied is an object as returned by the API server after applying it.
+type Applied struct {
+	Object   *unstructured.Unstructured
+	Resource schema.GroupVersionResource
+}
+
+// Apply server-side applies objects in dependency order (see Sort). Objects are not
+// modified; namespaces are defaulted on copies.
+//
+// CustomResourceDefinitions are waited for until they are established, so custom
+// resources in the same batch can be applied after them. In dry-run mode they are
+// not persisted, so such custom resources fail to resolve.
+//
+// When applying an object fails, Apply returns the objects applied so far along
+// with the error, so the caller can delete them again.
+func (a *Applier) Apply(ctx context.Context, objs []*unstructured.Unstructured, opts ApplyOptions) ([]Applied, error) {
+	applyOpts := metav1.ApplyOptions{
+		FieldManager: a.fieldManager,
+		Force:        opts.Force,
+	}
+	if opts.DryRun {
+		applyOpts.DryRun = []string{metav1.DryRunAll}
+	}
+
+	applied := make([]Applied, 0, len(objs))
+	for _, obj := range Sort(objs) {
+		obj = obj.DeepCopy()
+		client, mapping, err := a.ResourceClient(obj)
+		if err != nil {
+			return applied, err
+		}
+
+		res, err := client.Apply(ctx, obj.GetName(), obj, applyOpts)
+		if err != nil {
+			return applied, fmt.Errorf("failed to apply %s: %w", describe(obj), err)
+		}
+		applied = append(applied, Applied{Object: res, Resource: mapping.Resource})
+
+		if obj.GroupVersionKind().GroupKind() == crdGroupKind && !opts.DryRun {
+			if err := a.Wait(ctx, applied[len(applied)-1:]); err != nil {
+				return applied, err
+			}
+		}
+	}
+	return applied, nil
+}
+
+// ApplyManifests decodes manifests (see Decode) and applies the objects in them.
+func (a *Applier) ApplyManifests(ctx context.Context, manifests []byte, opts ApplyOptions) ([]Applied, error) {
+	objs, err := Decode(manifests)
+	if err != nil {
+		return nil, err
+	}
+	return a.Apply(ctx, objs, opts)
+}
+
+// Delete deletes objects in the reverse of the order they are applied in, so
+// custom resources are deleted before their CustomResourceDefinition and
+// Namespaces last. Objects that don't exist, or whose kind no longer exists,
+// are skipped. Dependents are garbage collected in the background.
+func (a *Applier) Delete(ctx context.Context, objs []*unstructured.Unstructured) error {
+	sorted := Sort(objs)
+	slices.Reverse(sorted)
+
+	propagation := metav1.DeletePropagationBackground
+	var errs []error
+	for _, obj := range sorted {
+		obj = obj.DeepCopy()
+		client, _, err := a.ResourceClient(obj)
+		if meta.IsNoMatchError(err) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		err = client.Delete(ctx, obj.GetName(), metav1.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("failed to delete %s: %w", describe(obj), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RESTMapping resolves the resource of an object's kind. When the kind is not
+// found, cached discovery results are reset and the kind is looked up again,
+// since it may have been added by a CustomResourceDefinition since.
+func (a *Applier) RESTMapping(obj *unstructured.Unstructured) (*meta.RESTMapping, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := a.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		if r, ok := a.mapper.(meta.ResettableRESTMapper); ok {
+			r.Reset()
+			mapping, err = a.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve the resource of %s: %w", describe(obj), err)
+	}
+	return mapping, nil
+}
+
+// ResourceClient returns the client of an object's resource, for apps that apply
+// objects their own way. Namespaced objects without a namespace get the default
+// namespace, and the namespace of cluster-scoped objects is cleared, since the
+// API server would reject it.
+func (a *Applier) ResourceClient(obj *unstructured.Unstructured) (dynamic.ResourceInterface, *meta.RESTMapping, error) {
+	mapping, err := a.RESTMapping(obj)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		obj.SetNamespace("")
+		return a.dynamic.Resource(mapping.Resource), mapping, nil
+	}
+
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(a.defaultNamespace)
+	}
+	return a.dynamic.Resource(mapping.Resource).Namespace(obj.GetNamespace()), mapping, nil
+}
+
+// describe identifies an object in errors, e.g. "Deployment guestbook/guestbook-ui".
+func describe(obj *unstructured.Unstructured) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetKind() + " " + obj.GetName()
+	}
+	return obj.GetKind() + " " + obj.GetNamespace() + "/" + obj.GetName()
+}
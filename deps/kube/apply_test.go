@@ -0,0 +1,192 @@
+package kube
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+var (
+	namespaceGVR  = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	configMapGVR  = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	deploymentGVR = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	jobGVR        = schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}
+)
+
+// newFakeCluster returns a fake dynamic client holding objs, whose server-side
+// applies create or replace objects, and a RESTMapper of the kinds it serves.
+func newFakeCluster(objs ...runtime.Object) (*dfake.FakeDynamicClient, meta.RESTMapper) {
+	kinds := map[schema.GroupVersionResource]string{
+		namespaceGVR:  "Namespace",
+		configMapGVR:  "ConfigMap",
+		deploymentGVR: "Deployment",
+		jobGVR:        "Job",
+	}
+
+	listKinds := make(map[schema.GroupVersionResource]string, len(kinds))
+	mapper := meta.NewDefaultRESTMapper(nil)
+	for gvr, kind := range kinds {
+		listKinds[gvr] = kind + "List"
+		scope := meta.RESTScopeNamespace
+		if kind == "Namespace" {
+			scope = meta.RESTScopeRoot
+		}
+		mapper.Add(gvr.GroupVersion().WithKind(kind), scope)
+	}
+
+	dc := dfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, objs...)
+	dc.PrependReactor("patch", "*", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		patch, ok := action.(clienttesting.PatchActionImpl)
+		if !ok || patch.GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(patch.GetPatch()); err != nil {
+			return true, nil, k8serrors.NewBadRequest(err.Error())
+		}
+		if len(patch.PatchOptions.DryRun) > 0 {
+			return true, obj, nil
+		}
+
+		gvr, namespace := patch.GetResource(), patch.GetNamespace()
+		_, err := dc.Tracker().Get(gvr, namespace, patch.GetName())
+		if k8serrors.IsNotFound(err) {
+			return true, obj, dc.Tracker().Create(gvr, obj, namespace)
+		}
+		return true, obj, dc.Tracker().Update(gvr, obj, namespace)
+	})
+	return dc, mapper
+}
+
+// testObject returns an object of kind with a name, and a namespace unless it is empty.
+func testObject(apiVersion, kind, namespace, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+// patchedObjects returns the objects patched through dc, in order, as "resource namespace/name".
+func patchedObjects(dc *dfake.FakeDynamicClient) []string {
+	var out []string
+	for _, action := range dc.Actions() {
+		if patch, ok := action.(clienttesting.PatchActionImpl); ok {
+			out = append(out, patch.GetResource().Resource+" "+patch.GetNamespace()+"/"+patch.GetName())
+		}
+	}
+	return out
+}
+
+func TestApply(t *testing.T) {
+	dc, mapper := newFakeCluster()
+	applier := NewForClients(dc, mapper, WithFieldManager("test"), WithDefaultNamespace("guestbook"))
+
+	deployment := testObject("apps/v1", "Deployment", "", "guestbook-ui")
+	objs := []*unstructured.Unstructured{
+		deployment,
+		testObject("v1", "ConfigMap", "guestbook", "settings"),
+		testObject("v1", "Namespace", "ignored", "guestbook"), // Cluster-scoped, so the namespace is cleared
+	}
+	applied, err := applier.Apply(t.Context(), objs, ApplyOptions{Force: true})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	want := []string{"namespaces /guestbook", "configmaps guestbook/settings", "deployments guestbook/guestbook-ui"}
+	if got := patchedObjects(dc); !slices.Equal(got, want) {
+		t.Errorf("applied %v, want %v", got, want)
+	}
+	if len(applied) != 3 || applied[2].Resource != deploymentGVR || applied[2].Object.GetNamespace() != "guestbook" {
+		t.Errorf("Apply() = %v, want the Deployment last, in namespace guestbook", applied)
+	}
+	for _, action := range dc.Actions() {
+		opts := action.(clienttesting.PatchActionImpl).PatchOptions
+		if opts.FieldManager != "test" || opts.Force == nil || !*opts.Force {
+			t.Errorf("%s applied with %+v, want the test field manager and force", action.GetResource().Resource, opts)
+		}
+	}
+	if deployment.GetNamespace() != "" {
+		t.Error("Apply() modified the objects it was given")
+	}
+	if _, err := dc.Tracker().Get(deploymentGVR, "guestbook", "guestbook-ui"); err != nil {
+		t.Errorf("Deployment was not created: %v", err)
+	}
+}
+
+func TestApplyDryRun(t *testing.T) {
+	dc, mapper := newFakeCluster()
+	applier := NewForClients(dc, mapper)
+
+	if _, err := applier.Apply(t.Context(), []*unstructured.Unstructured{testObject("v1", "ConfigMap", "", "settings")}, ApplyOptions{DryRun: true}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if opts := dc.Actions()[0].(clienttesting.PatchActionImpl).PatchOptions; !slices.Equal(opts.DryRun, []string{"All"}) {
+		t.Errorf("applied with DryRun %v, want All", opts.DryRun)
+	}
+	if _, err := dc.Tracker().Get(configMapGVR, "default", "settings"); !k8serrors.IsNotFound(err) {
+		t.Errorf("dry run created the ConfigMap")
+	}
+}
+
+func TestApplyUnknownKind(t *testing.T) {
+	dc, mapper := newFakeCluster()
+	applier := NewForClients(dc, mapper)
+
+	// Custom resources are applied last, so the objects before them are returned to be deleted
+	applied, err := applier.Apply(t.Context(), []*unstructured.Unstructured{
+		testObject("example.com/v1", "Widget", "", "widget"),
+		testObject("v1", "ConfigMap", "", "settings"),
+	}, ApplyOptions{})
+	if err == nil || !strings.Contains(err.Error(), "failed to resolve the resource of Widget widget") {
+		t.Errorf("Apply() error = %v, want the Widget kind unresolved", err)
+	}
+	if len(applied) != 1 || applied[0].Object.GetName() != "settings" {
+		t.Errorf("Apply() = %v, want the ConfigMap applied before the error", applied)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	dc, mapper := newFakeCluster(
+		testObject("v1", "Namespace", "", "guestbook"),
+		testObject("v1", "ConfigMap", "guestbook", "settings"),
+		testObject("apps/v1", "Deployment", "guestbook", "guestbook-ui"),
+	)
+	applier := NewForClients(dc, mapper, WithDefaultNamespace("guestbook"))
+
+	// Objects that don't exist, or whose kind doesn't, are skipped
+	err := applier.Delete(t.Context(), []*unstructured.Unstructured{
+		testObject("v1", "Namespace", "", "guestbook"),
+		testObject("v1", "ConfigMap", "", "settings"),
+		testObject("v1", "ConfigMap", "", "missing"),
+		testObject("example.com/v1", "Widget", "", "widget"),
+		testObject("apps/v1", "Deployment", "", "guestbook-ui"),
+	})
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	var deleted []string
+	for _, action := range dc.Actions() {
+		if d, ok := action.(clienttesting.DeleteActionImpl); ok {
+			deleted = append(deleted, d.GetResource().Resource+" "+d.GetNamespace()+"/"+d.GetName())
+		}
+	}
+	want := []string{"deployments guestbook/guestbook-ui", "configmaps guestbook/missing", "configmaps guestbook/settings", "namespaces /guestbook"}
+	if !slices.Equal(deleted, want) {
+		t.Errorf("deleted %v, want %v", deleted, want)
+	}
+	if _, err := dc.Tracker().Get(deploymentGVR, "guestbook", "guestbook-ui"); !k8serrors.IsNotFound(err) {
+		t.Errorf("Deployment was not deleted: %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package kube
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// Decode parses manifests holding one or more YAML or JSON documents into objects.
+// Empty documents, e.g. from a trailing "---" or a template that rendered nothing,
+// are skipped, and List objects such as the output of "kubectl get -o yaml" are
+// expanded into their items.
+func Decode(manifests []byte) ([]*unstructured.Unstructured, error) {
+	dec := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifests), 4096)
+
+	var objs []*unstructured.Unstructured
+	for i := 1; ; i++ {
+		var doc map[string]any
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return objs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse document %d: %w", i, err)
+		}
+		if len(doc) == 0 {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{Object: doc}
+		if obj.IsList() {
+			list, err := obj.ToList()
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode list in document %d: %w", i, err)
+			}
+			for j := range list.Items {
+				objs = append(objs, &list.Items[j])
+			}
+			continue
+		}
+
+		if obj.GetAPIVersion() == "" || obj.GetKind() == "" {
+			return nil, fmt.Errorf("document %d has no apiVersion or kind", i)
+		}
+		if obj.GetName() == "" {
+			return nil, fmt.Errorf("document %d (%s) has no metadata.name", i, obj.GetKind())
+		}
+		objs = append(objs, obj)
+	}
+}
@@ -0,0 +1,75 @@
+package kube
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	manifests := `---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: guestbook
+---
+# A template that rendered nothing
+---
+apiVersion: v1
+kind: List
+items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: settings
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+      name: credentials
+---
+{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "guestbook-ui", "namespace": "guestbook"}}
+---
+`
+	objs, err := Decode([]byte(manifests))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	want := []string{"Namespace guestbook", "ConfigMap settings", "Secret credentials", "Deployment guestbook/guestbook-ui"}
+	if len(objs) != len(want) {
+		t.Fatalf("Decode returned %d objects, want %d", len(objs), len(want))
+	}
+	for i, obj := range objs {
+		if got := describe(obj); got != want[i] {
+			t.Errorf("object %d = %s, want %s", i, got, want[i])
+		}
+	}
+}
+
+func TestDecodeEmpty(t *testing.T) {
+	for _, manifests := range []string{"", "---\n", "---\n# nothing\n---\n"} {
+		objs, err := Decode([]byte(manifests))
+		if err != nil || len(objs) != 0 {
+			t.Errorf("Decode(%q) = %v, %v, want no objects", manifests, objs, err)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		manifests string
+		want      string
+	}{
+		{name: "invalid YAML", manifests: "kind: [ConfigMap", want: "failed to parse document 1"},
+		{name: "no kind", manifests: "apiVersion: v1\nmetadata:\n  name: a\n", want: "document 1 has no apiVersion or kind"},
+		{name: "no name", manifests: "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n---\napiVersion: v1\nkind: ConfigMap\n", want: "document 2 (ConfigMap) has no metadata.name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode([]byte(tt.manifests))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Decode() error = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
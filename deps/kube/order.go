@@ -0,0 +1,73 @@
+package kube
+
+import (
+	"slices"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// applyOrder lists kinds in the order they are applied, so objects are applied
+// after the objects they depend on: CustomResourceDefinitions before custom
+// resources, Namespaces before the objects in them, and Secrets, ConfigMaps and
+// RBAC before the workloads using them. Kinds not listed, such as custom
+// resources, are applied last. It is the order Helm installs charts in, except
+// that CustomResourceDefinitions come first.
+var applyOrder = []string{
+	"CustomResourceDefinition",
+	"PriorityClass",
+	"Namespace",
+	"NetworkPolicy",
+	"ResourceQuota",
+	"LimitRange",
+	"PodSecurityPolicy",
+	"PodDisruptionBudget",
+	"ServiceAccount",
+	"Secret",
+	"SecretList",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"ClusterRole",
+	"ClusterRoleList",
+	"ClusterRoleBinding",
+	"ClusterRoleBindingList",
+	"Role",
+	"RoleList",
+	"RoleBinding",
+	"RoleBindingList",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"HorizontalPodAutoscaler",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"IngressClass",
+	"Ingress",
+	"APIService",
+	"MutatingWebhookConfiguration",
+	"ValidatingWebhookConfiguration",
+}
+
+// kindRank returns the position of a kind in applyOrder, or len(applyOrder) for
+// kinds that are not listed.
+func kindRank(kind string) int {
+	if i := slices.Index(applyOrder, kind); i >= 0 {
+		return i
+	}
+	return len(applyOrder)
+}
+
+// Sort orders objects for applying, see applyOrder. Objects of the same rank keep
+// the order they were given in. Reverse the result to delete objects.
+func Sort(objs []*unstructured.Unstructured) []*unstructured.Unstructured {
+	sorted := slices.Clone(objs)
+	slices.SortStableFunc(sorted, func(a, b *unstructured.Unstructured) int {
+		return kindRank(a.GetKind()) - kindRank(b.GetKind())
+	})
+	return sorted
+}
@@ -0,0 +1,31 @@
+package kube
+
+import (
+	"slices"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSort(t *testing.T) {
+	var objs []*unstructured.Unstructured
+	for _, kind := range []string{"Deployment", "Certificate", "ConfigMap", "Service", "Namespace", "CustomResourceDefinition", "Secret", "Issuer"} {
+		obj := &unstructured.Unstructured{}
+		obj.SetKind(kind)
+		objs = append(objs, obj)
+	}
+
+	var kinds []string
+	for _, obj := range Sort(objs) {
+		kinds = append(kinds, obj.GetKind())
+	}
+
+	// Kinds that are not listed, like custom resources, come last in the order they were given in
+	want := []string{"CustomResourceDefinition", "Namespace", "Secret", "ConfigMap", "Service", "Deployment", "Certificate", "Issuer"}
+	if !slices.Equal(kinds, want) {
+		t.Errorf("Sort() kinds = %v, want %v", kinds, want)
+	}
+	if objs[0].GetKind() != "Deployment" {
+		t.Error("Sort() reordered its input")
+	}
+}
@@ -0,0 +1,256 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+)
+
+// ReadinessCheck reports whether an object is ready. It returns an error when the
+// object will never become ready, e.g. a failed Job, so waiting stops early.
+type ReadinessCheck func(obj *unstructured.Unstructured) (bool, error)
+
+// ErrNotReady is wrapped by the errors of ReadinessChecks that gave up on an object.
+var ErrNotReady = errors.New("object will not become ready")
+
+// DefaultReadinessChecks returns the readiness checks of the built-in kinds that
+// take time to become ready. Objects of other kinds are ready once applied.
+func DefaultReadinessChecks() map[schema.GroupKind]ReadinessCheck {
+	return map[schema.GroupKind]ReadinessCheck{
+		crdGroupKind:                                          crdReady,
+		{Kind: "Namespace"}:                                   namespaceReady,
+		{Kind: "PersistentVolumeClaim"}:                       pvcReady,
+		{Kind: "Pod"}:                                         podReady,
+		{Kind: "Service"}:                                     serviceReady,
+		{Group: "apps", Kind: "Deployment"}:                   deploymentReady,
+		{Group: "apps", Kind: "StatefulSet"}:                  statefulSetReady,
+		{Group: "apps", Kind: "DaemonSet"}:                    daemonSetReady,
+		{Group: "batch", Kind: "Job"}:                         jobReady,
+		{Group: "apiregistration.k8s.io", Kind: "APIService"}: apiServiceReady,
+	}
+}
+
+// Ready reports whether an object is ready, using the readiness check of its kind.
+// Objects of kinds without a check are always ready.
+func (a *Applier) Ready(obj *unstructured.Unstructured) (bool, error) {
+	check, ok := a.readiness[obj.GroupVersionKind().GroupKind()]
+	if !ok {
+		return true, nil
+	}
+	return check(obj)
+}
+
+// Wait watches applied objects until each is ready, or until ctx is done.
+// Use context.WithTimeout to bound how long to wait.
+func (a *Applier) Wait(ctx context.Context, applied []Applied) error {
+	for _, obj := range applied {
+		if _, ok := a.readiness[obj.Object.GroupVersionKind().GroupKind()]; !ok {
+			continue
+		}
+		if err := a.waitFor(ctx, obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitFor watches a single object until it is ready.
+func (a *Applier) waitFor(ctx context.Context, obj Applied) error {
+	namespace, name := obj.Object.GetNamespace(), obj.Object.GetName()
+	client := a.dynamic.Resource(obj.Resource).Namespace(namespace)
+	selector := fields.OneTermEqualSelector("metadata.name", name).String()
+	lw := cache.ToListWatcherWithWatchListSemantics(&cache.ListWatch{
+		ListWithContextFunc: func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return client.List(ctx, options)
+		},
+		WatchFuncWithContext: func(ctx context.Context, options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return client.Watch(ctx, options)
+		},
+	}, a.dynamic)
+
+	key := name
+	if namespace != "" {
+		key = namespace + "/" + name
+	}
+
+	check := func(o any) (bool, error) {
+		u, ok := o.(*unstructured.Unstructured)
+		if !ok || u.GetName() != name {
+			return false, nil
+		}
+		return a.Ready(u)
+	}
+
+	_, err := watchtools.UntilWithSync(ctx, lw, &unstructured.Unstructured{},
+		func(store cache.Store) (bool, error) {
+			o, exists, err := store.GetByKey(key)
+			if err != nil || !exists {
+				return false, err
+			}
+			return check(o)
+		},
+		func(event watch.Event) (bool, error) {
+			switch event.Type {
+			case watch.Deleted:
+				return false, fmt.Errorf("%w: %s was deleted", ErrNotReady, describe(obj.Object))
+			case watch.Added, watch.Modified:
+				return check(event.Object)
+			}
+			return false, nil
+		},
+	)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrNotReady):
+		return err
+	case ctx.Err() != nil:
+		return fmt.Errorf("%s did not become ready: %w", describe(obj.Object), context.Cause(ctx))
+	}
+	return fmt.Errorf("failed to watch %s: %w", describe(obj.Object), err)
+}
+
+// condition returns the status, reason and message of a condition in status.conditions.
+func condition(obj *unstructured.Unstructured, conditionType string) (status, reason, message string) {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		m, ok := c.(map[string]any)
+		if !ok || m["type"] != conditionType {
+			continue
+		}
+		status, _, _ = unstructured.NestedString(m, "status")
+		reason, _, _ = unstructured.NestedString(m, "reason")
+		message, _, _ = unstructured.NestedString(m, "message")
+		return status, reason, message
+	}
+	return "", "", ""
+}
+
+// observed reports whether the controller has seen the object's latest spec.
+func observed(obj *unstructured.Unstructured) bool {
+	generation, found, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	return found && generation >= obj.GetGeneration()
+}
+
+// notReady wraps ErrNotReady with the object and why it won't become ready.
+func notReady(obj *unstructured.Unstructured, format string, args ...any) error {
+	return fmt.Errorf("%w: %s: %s", ErrNotReady, describe(obj), fmt.Sprintf(format, args...))
+}
+
+func crdReady(obj *unstructured.Unstructured) (bool, error) {
+	if status, _, message := condition(obj, "NamesAccepted"); status == "False" {
+		return false, notReady(obj, "names not accepted: %s", message)
+	}
+	status, _, _ := condition(obj, "Established")
+	return status == "True", nil
+}
+
+func namespaceReady(obj *unstructured.Unstructured) (bool, error) {
+	phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+	if phase == "Terminating" {
+		return false, notReady(obj, "namespace is terminating")
+	}
+	return phase == "Active", nil
+}
+
+func pvcReady(obj *unstructured.Unstructured) (bool, error) {
+	phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+	if phase == "Lost" {
+		return false, notReady(obj, "claim lost its volume")
+	}
+	return phase == "Bound", nil
+}
+
+func podReady(obj *unstructured.Unstructured) (bool, error) {
+	phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+	switch phase {
+	case "Succeeded":
+		return true, nil
+	case "Failed":
+		message, _, _ := unstructured.NestedString(obj.Object, "status", "message")
+		return false, notReady(obj, "pod failed: %s", message)
+	}
+	status, _, _ := condition(obj, "Ready")
+	return status == "True", nil
+}
+
+// serviceReady waits for LoadBalancer Services to get an address.
+func serviceReady(obj *unstructured.Unstructured) (bool, error) {
+	serviceType, _, _ := unstructured.NestedString(obj.Object, "spec", "type")
+	if serviceType != "LoadBalancer" {
+		return true, nil
+	}
+	ingress, _, _ := unstructured.NestedSlice(obj.Object, "status", "loadBalancer", "ingress")
+	return len(ingress) > 0, nil
+}
+
+func deploymentReady(obj *unstructured.Unstructured) (bool, error) {
+	if _, reason, message := condition(obj, "Progressing"); reason == "ProgressDeadlineExceeded" {
+		return false, notReady(obj, "%s", message)
+	}
+	if !observed(obj) {
+		return false, nil
+	}
+
+	replicas := specReplicas(obj)
+	updated, _, _ := unstructured.NestedInt64(obj.Object, "status", "updatedReplicas")
+	available, _, _ := unstructured.NestedInt64(obj.Object, "status", "availableReplicas")
+	total, _, _ := unstructured.NestedInt64(obj.Object, "status", "replicas")
+	return updated >= replicas && available >= replicas && total == updated, nil
+}
+
+func statefulSetReady(obj *unstructured.Unstructured) (bool, error) {
+	if !observed(obj) {
+		return false, nil
+	}
+
+	replicas := specReplicas(obj)
+	ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+	current, _, _ := unstructured.NestedString(obj.Object, "status", "currentRevision")
+	update, _, _ := unstructured.NestedString(obj.Object, "status", "updateRevision")
+	return ready >= replicas && current == update, nil
+}
+
+func daemonSetReady(obj *unstructured.Unstructured) (bool, error) {
+	if !observed(obj) {
+		return false, nil
+	}
+
+	desired, _, _ := unstructured.NestedInt64(obj.Object, "status", "desiredNumberScheduled")
+	updated, _, _ := unstructured.NestedInt64(obj.Object, "status", "updatedNumberScheduled")
+	available, _, _ := unstructured.NestedInt64(obj.Object, "status", "numberAvailable")
+	return updated >= desired && available >= desired, nil
+}
+
+func jobReady(obj *unstructured.Unstructured) (bool, error) {
+	if status, _, message := condition(obj, "Failed"); status == "True" {
+		return false, notReady(obj, "job failed: %s", message)
+	}
+	status, _, _ := condition(obj, "Complete")
+	return status == "True", nil
+}
+
+func apiServiceReady(obj *unstructured.Unstructured) (bool, error) {
+	status, _, _ := condition(obj, "Available")
+	return status == "True", nil
+}
+
+// specReplicas returns spec.replicas, which defaults to 1.
+func specReplicas(obj *unstructured.Unstructured) int64 {
+	replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if !found {
+		return 1
+	}
+	return replicas
+}
@@ -0,0 +1,142 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// withStatus returns an object of kind with a generation, spec and status.
+func withStatus(apiVersion, kind string, generation int64, spec, status map[string]any) *unstructured.Unstructured {
+	obj := testObject(apiVersion, kind, "guestbook", "guestbook-ui")
+	obj.SetGeneration(generation)
+	if spec != nil {
+		obj.Object["spec"] = spec
+	}
+	obj.Object["status"] = status
+	return obj
+}
+
+// conditions returns status.conditions holding a condition of each type with its status.
+func conditions(typeStatus ...string) []any {
+	var out []any
+	for i := 0; i+1 < len(typeStatus); i += 2 {
+		out = append(out, map[string]any{"type": typeStatus[i], "status": typeStatus[i+1], "reason": "Test", "message": "test"})
+	}
+	return out
+}
+
+func TestReadinessChecks(t *testing.T) {
+	tests := []struct {
+		name    string
+		obj     *unstructured.Unstructured
+		want    bool
+		wantErr bool
+	}{
+		{name: "established CRD", obj: withStatus("apiextensions.k8s.io/v1", "CustomResourceDefinition", 1, nil, map[string]any{"conditions": conditions("Established", "True")}), want: true},
+		{name: "CRD with conflicting names", obj: withStatus("apiextensions.k8s.io/v1", "CustomResourceDefinition", 1, nil, map[string]any{"conditions": conditions("NamesAccepted", "False")}), wantErr: true},
+		{name: "active namespace", obj: withStatus("v1", "Namespace", 1, nil, map[string]any{"phase": "Active"}), want: true},
+		{name: "terminating namespace", obj: withStatus("v1", "Namespace", 1, nil, map[string]any{"phase": "Terminating"}), wantErr: true},
+		{name: "pending claim", obj: withStatus("v1", "PersistentVolumeClaim", 1, nil, map[string]any{"phase": "Pending"})},
+		{name: "lost claim", obj: withStatus("v1", "PersistentVolumeClaim", 1, nil, map[string]any{"phase": "Lost"}), wantErr: true},
+		{name: "ready pod", obj: withStatus("v1", "Pod", 1, nil, map[string]any{"phase": "Running", "conditions": conditions("Ready", "True")}), want: true},
+		{name: "failed pod", obj: withStatus("v1", "Pod", 1, nil, map[string]any{"phase": "Failed"}), wantErr: true},
+		{name: "ClusterIP service", obj: withStatus("v1", "Service", 1, map[string]any{"type": "ClusterIP"}, map[string]any{}), want: true},
+		{name: "load balancer without address", obj: withStatus("v1", "Service", 1, map[string]any{"type": "LoadBalancer"}, map[string]any{})},
+		{name: "rolled out deployment", obj: withStatus("apps/v1", "Deployment", 2, map[string]any{"replicas": int64(2)}, map[string]any{
+			"observedGeneration": int64(2), "replicas": int64(2), "updatedReplicas": int64(2), "availableReplicas": int64(2),
+		}), want: true},
+		{name: "deployment not observed", obj: withStatus("apps/v1", "Deployment", 3, map[string]any{"replicas": int64(2)}, map[string]any{
+			"observedGeneration": int64(2), "replicas": int64(2), "updatedReplicas": int64(2), "availableReplicas": int64(2),
+		})},
+		{name: "deployment with old replicas", obj: withStatus("apps/v1", "Deployment", 2, nil, map[string]any{
+			"observedGeneration": int64(2), "replicas": int64(2), "updatedReplicas": int64(1), "availableReplicas": int64(2),
+		})},
+		{name: "deployment past its deadline", obj: withStatus("apps/v1", "Deployment", 2, nil, map[string]any{
+			"conditions": []any{map[string]any{"type": "Progressing", "status": "False", "reason": "ProgressDeadlineExceeded"}},
+		}), wantErr: true},
+		{name: "rolled out stateful set", obj: withStatus("apps/v1", "StatefulSet", 1, nil, map[string]any{
+			"observedGeneration": int64(1), "readyReplicas": int64(1), "currentRevision": "r1", "updateRevision": "r1",
+		}), want: true},
+		{name: "stateful set rolling out", obj: withStatus("apps/v1", "StatefulSet", 1, nil, map[string]any{
+			"observedGeneration": int64(1), "readyReplicas": int64(1), "currentRevision": "r1", "updateRevision": "r2",
+		})},
+		{name: "rolled out daemon set", obj: withStatus("apps/v1", "DaemonSet", 1, nil, map[string]any{
+			"observedGeneration": int64(1), "desiredNumberScheduled": int64(3), "updatedNumberScheduled": int64(3), "numberAvailable": int64(3),
+		}), want: true},
+		{name: "complete job", obj: withStatus("batch/v1", "Job", 1, nil, map[string]any{"conditions": conditions("Complete", "True")}), want: true},
+		{name: "failed job", obj: withStatus("batch/v1", "Job", 1, nil, map[string]any{"conditions": conditions("Failed", "True")}), wantErr: true},
+		{name: "available API service", obj: withStatus("apiregistration.k8s.io/v1", "APIService", 1, nil, map[string]any{"conditions": conditions("Available", "True")}), want: true},
+		{name: "kind without check", obj: withStatus("v1", "ConfigMap", 1, nil, map[string]any{}), want: true},
+	}
+
+	applier := NewForClients(nil, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ready, err := applier.Ready(tt.obj)
+			if ready != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("Ready() = %v, %v, want %v, error %v", ready, err, tt.want, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrNotReady) {
+				t.Errorf("Ready() error = %v, want it to wrap ErrNotReady", err)
+			}
+		})
+	}
+}
+
+func TestWithReadinessCheck(t *testing.T) {
+	widget := schema.GroupKind{Group: "example.com", Kind: "Widget"}
+	applier := NewForClients(nil, nil, WithReadinessCheck(widget, func(obj *unstructured.Unstructured) (bool, error) {
+		ready, _, _ := unstructured.NestedBool(obj.Object, "status", "ready")
+		return ready, nil
+	}))
+
+	obj := withStatus("example.com/v1", "Widget", 1, nil, map[string]any{"ready": false})
+	if ready, _ := applier.Ready(obj); ready {
+		t.Error("Ready() = true, want the registered check to decide")
+	}
+}
+
+func TestWait(t *testing.T) {
+	job := withStatus("batch/v1", "Job", 1, nil, map[string]any{})
+	dc, mapper := newFakeCluster(job)
+	applier := NewForClients(dc, mapper)
+
+	// The Job completes once the watch has started, so Wait must see the change
+	complete := withStatus("batch/v1", "Job", 1, nil, map[string]any{"conditions": conditions("Complete", "True")})
+	dc.PrependWatchReactor("jobs", func(action clienttesting.Action) (bool, watch.Interface, error) {
+		w, err := dc.Tracker().Watch(jobGVR, action.GetNamespace())
+		if err != nil {
+			return true, nil, err
+		}
+		go func() {
+			_ = dc.Tracker().Update(jobGVR, complete, "guestbook")
+		}()
+		return true, w, nil
+	})
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	if err := applier.Wait(ctx, []Applied{{Object: job, Resource: jobGVR}}); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+}
+
+func TestWaitNotReady(t *testing.T) {
+	job := withStatus("batch/v1", "Job", 1, nil, map[string]any{"conditions": conditions("Failed", "True")})
+	dc, mapper := newFakeCluster(job)
+	applier := NewForClients(dc, mapper)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	err := applier.Wait(ctx, []Applied{{Object: job, Resource: jobGVR}})
+	if !errors.Is(err, ErrNotReady) {
+		t.Fatalf("Wait() error = %v, want ErrNotReady for a failed Job", err)
+	}
+}